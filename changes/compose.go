@@ -0,0 +1,153 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package changes
+
+import "reflect"
+
+// Compose returns a change that is equivalent to applying c1 followed
+// by c2 but which is usually smaller than ChangeSet{c1, c2}.
+//
+// ChangeSets are flattened and consecutive changes are combined where
+// possible:
+//
+//   - a change followed by its revert cancel out
+//   - consecutive Replace changes are folded into one
+//   - changes following a Replace are folded into its After value
+//   - a Replace after a Splice, Move or PathChange is folded
+//     into a single Replace
+//   - Splices at overlapping or adjacent offsets are combined
+//   - PathChanges with a common prefix are grouped together
+//
+// Custom changes are never combined except for the change+revert case
+// and the case where they follow a Replace. Meta changes are never
+// combined so that their data is not lost.
+func Compose(c1, c2 Change) Change {
+	var result []Change
+	for _, c := range flatten(ChangeSet{c1, c2}, nil) {
+		result = pushComposed(result, c)
+	}
+	return ChangeSet(result).Simplify()
+}
+
+func flatten(c Change, result []Change) []Change {
+	switch c := Simplify(c).(type) {
+	case nil:
+	case ChangeSet:
+		for _, cx := range c {
+			result = flatten(cx, result)
+		}
+	default:
+		result = append(result, c)
+	}
+	return result
+}
+
+func pushComposed(list []Change, c Change) []Change {
+	l := len(list) - 1
+	if l < 0 {
+		return append(list, c)
+	}
+
+	combined, ok := composePair(list[l], c)
+	switch {
+	case !ok:
+		return append(list, c)
+	case combined == nil:
+		return list[:l]
+	}
+	return pushComposed(list[:l], combined)
+}
+
+func composePair(c1, c2 Change) (Change, bool) {
+	if isMeta(c1) || isMeta(c2) {
+		return nil, false
+	}
+
+	if reflect.DeepEqual(Simplify(c1.Revert()), c2) {
+		return nil, true
+	}
+
+	if r, ok := c1.(Replace); ok {
+		r.After = r.After.Apply(nil, c2)
+		return r.noop(), true
+	}
+
+	switch c2 := c2.(type) {
+	case Replace:
+		switch c1.(type) {
+		case Splice, Move, PathChange:
+			c2.Before = c2.Before.Apply(nil, c1.Revert())
+			return c2.noop(), true
+		}
+	case Splice:
+		if s, ok := c1.(Splice); ok {
+			return s.compose(c2)
+		}
+	case PathChange:
+		if p, ok := c1.(PathChange); ok {
+			return p.compose(c2)
+		}
+	}
+	return nil, false
+}
+
+func isMeta(c Change) bool {
+	_, ok := c.(Meta)
+	return ok
+}
+
+func (s Replace) noop() Change {
+	if reflect.DeepEqual(s.Before, s.After) {
+		return nil
+	}
+	return s
+}
+
+// compose combines two splices if the second splice overlaps or is
+// adjacent to the region modified by the first.
+func (s Splice) compose(o Splice) (Change, bool) {
+	send := s.Offset + s.After.Count()
+	oend := o.Offset + o.Before.Count()
+	if o.Offset > send || oend < s.Offset {
+		return nil, false
+	}
+
+	// left and right are the parts of o.Before which are outside
+	// the region modified by s
+	start, before, mid := s.Offset, s.Before, s.After
+	if o.Offset < s.Offset {
+		left := o.Before.Slice(0, s.Offset-o.Offset)
+		before = s.insert(before, 0, left)
+		mid = s.insert(mid, 0, left)
+		start = o.Offset
+	}
+	if oend > send {
+		right := o.Before.Slice(send-o.Offset, oend-send)
+		before = s.insert(before, before.Count(), right)
+		mid = s.insert(mid, mid.Count(), right)
+	}
+
+	after := mid.ApplyCollection(nil, Splice{o.Offset - start, o.Before, o.After})
+	if reflect.DeepEqual(before, after) {
+		return nil, true
+	}
+	return Splice{start, before, after}, true
+}
+
+func (s Splice) insert(c Collection, offset int, insert Collection) Collection {
+	return c.ApplyCollection(nil, Splice{offset, c.Slice(0, 0), insert})
+}
+
+// compose groups two path changes with a common prefix.
+func (pc PathChange) compose(o PathChange) (Change, bool) {
+	l := pc.commonPrefixLen(pc.Path, o.Path)
+	if l == 0 {
+		return nil, false
+	}
+
+	inner1 := PathChange{pc.Path[l:], pc.Change}.Simplify()
+	inner2 := PathChange{o.Path[l:], o.Change}.Simplify()
+	return PathChange{pc.Path[:l:l], Compose(inner1, inner2)}.Simplify(), true
+}
@@ -0,0 +1,157 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package changes_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+)
+
+func TestComposeTable(t *testing.T) {
+	splice := func(offset int, before, after string) changes.Change {
+		return changes.Splice{Offset: offset, Before: S(before), After: S(after)}
+	}
+	replace := func(before, after string) changes.Change {
+		return changes.Replace{Before: S(before), After: S(after)}
+	}
+
+	tests := map[string]struct {
+		c1, c2, expected changes.Change
+	}{
+		"nils":           {nil, nil, nil},
+		"nil first":      {nil, splice(1, "", "x"), splice(1, "", "x")},
+		"nil second":     {splice(1, "", "x"), nil, splice(1, "", "x")},
+		"revert":         {splice(1, "a", "xy"), splice(1, "xy", "a"), nil},
+		"revert move":    {changes.Move{1, 2, 3}, changes.Move{4, 2, -3}, nil},
+		"typing":         {splice(1, "", "x"), splice(2, "", "y"), splice(1, "", "xy")},
+		"backspace":      {splice(3, "c", ""), splice(2, "b", ""), splice(2, "bc", "")},
+		"overlap left":   {splice(2, "c", "xy"), splice(1, "bx", "z"), splice(1, "bc", "zy")},
+		"overlap right":  {splice(2, "c", "xy"), splice(3, "yd", "z"), splice(2, "cd", "xz")},
+		"overlap both":   {splice(2, "c", "x"), splice(1, "bxd", ""), splice(1, "bcd", "")},
+		"restore":        {splice(2, "c", "x"), splice(2, "x", "c"), nil},
+		"not adjacent":   {splice(1, "", "x"), splice(3, "", "y"), changes.ChangeSet{splice(1, "", "x"), splice(3, "", "y")}},
+		"replace twice":  {replace("a", "b"), replace("b", "c"), replace("a", "c")},
+		"replace noop":   {replace("a", "b"), replace("b", "a"), nil},
+		"replace splice": {replace("a", "bc"), splice(1, "c", "d"), replace("a", "bd")},
+		"splice replace": {splice(0, "a", "b"), replace("bc", "d"), replace("ac", "d")},
+		"move replace":   {changes.Move{0, 1, 1}, replace("ba", "c"), replace("ab", "c")},
+		"same path": {
+			changes.PathChange{Path{1}, splice(0, "", "x")},
+			changes.PathChange{Path{1}, splice(1, "", "y")},
+			changes.PathChange{Path{1}, splice(0, "", "xy")},
+		},
+		"common prefix": {
+			changes.PathChange{Path{1, "a"}, splice(0, "", "x")},
+			changes.PathChange{Path{1, "b"}, splice(0, "", "y")},
+			changes.PathChange{Path{1}, changes.ChangeSet{
+				changes.PathChange{Path{"a"}, splice(0, "", "x")},
+				changes.PathChange{Path{"b"}, splice(0, "", "y")},
+			}},
+		},
+		"different paths": {
+			changes.PathChange{Path{1}, splice(0, "", "x")},
+			changes.PathChange{Path{2}, splice(0, "", "y")},
+			changes.ChangeSet{
+				changes.PathChange{Path{1}, splice(0, "", "x")},
+				changes.PathChange{Path{2}, splice(0, "", "y")},
+			},
+		},
+		"cascade": {
+			changes.ChangeSet{splice(0, "", "x"), changes.Move{0, 1, 2}},
+			changes.ChangeSet{changes.Move{2, 1, -2}, splice(0, "x", "")},
+			nil,
+		},
+		"meta": {
+			changes.Meta{Data: 1, Change: splice(0, "", "x")},
+			splice(1, "", "y"),
+			changes.ChangeSet{changes.Meta{Data: 1, Change: splice(0, "", "x")}, splice(1, "", "y")},
+		},
+		"replace meta": {
+			replace("a", "b"),
+			changes.Meta{Data: 1, Change: replace("b", "c")},
+			changes.ChangeSet{replace("a", "b"), changes.Meta{Data: 1, Change: replace("b", "c")}},
+		},
+		"meta revert": {
+			changes.Meta{Data: 1, Change: splice(0, "", "x")},
+			changes.Meta{Data: 1, Change: splice(0, "x", "")},
+			changes.ChangeSet{
+				changes.Meta{Data: 1, Change: splice(0, "", "x")},
+				changes.Meta{Data: 1, Change: splice(0, "x", "")},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := changes.Compose(test.c1, test.c2)
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Error("Unexpected compose", got)
+			}
+		})
+	}
+}
+
+func TestComposeCounter(t *testing.T) {
+	inc := func(n int32) changes.Change {
+		return changes.Splice{Offset: 0, Before: types.Counter(0), After: types.Counter(n)}
+	}
+	got := changes.Compose(inc(2), inc(5))
+	if got != inc(7) {
+		t.Error("Unexpected compose", got)
+	}
+	if got := changes.Compose(inc(2), inc(-2)); got != nil {
+		t.Error("Unexpected compose", got)
+	}
+}
+
+func TestComposeRandom(t *testing.T) {
+	for seed := int64(0); seed < 1000; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		initial := changes.Value(types.A{S("abc"), types.M{"x": S("de")}})
+		v := initial
+
+		var cx []changes.Change
+		for kk := r.Intn(8); kk >= 0; kk-- {
+			c := randComposeChange(r, v)
+			cx = append(cx, c)
+			v = v.Apply(nil, c)
+		}
+
+		var composed changes.Change
+		for _, c := range cx {
+			composed = changes.Compose(composed, c)
+		}
+
+		if got := initial.Apply(nil, composed); !reflect.DeepEqual(got, v) {
+			t.Fatal("Compose diverged", seed, got, v, cx, composed)
+		}
+	}
+}
+
+func randComposeChange(r *rand.Rand, v changes.Value) changes.Change {
+	switch v := v.(type) {
+	case S:
+		offset := r.Intn(len(v) + 1)
+		count := r.Intn(len(v) - offset + 1)
+		switch r.Intn(5) {
+		case 0:
+			return changes.Replace{Before: v, After: S("xyz"[:r.Intn(4)])}
+		case 1:
+			return changes.Move{Offset: offset, Count: count, Distance: -offset}
+		}
+		before := v.Slice(offset, count)
+		return changes.Splice{Offset: offset, Before: before, After: S("pq"[:r.Intn(3)])}
+	case types.M:
+		return changes.PathChange{Path: Path{"x"}, Change: randComposeChange(r, v["x"])}
+	case A:
+		idx := r.Intn(len(v))
+		return changes.PathChange{Path: Path{idx}, Change: randComposeChange(r, v[idx])}
+	}
+	panic("unexpected value")
+}
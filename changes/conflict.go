@@ -0,0 +1,141 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package changes
+
+import "reflect"
+
+// Conflict describes a merge where one of the changes was discarded
+// in favor of the other. Examples are two Replace changes of the same
+// value (the earlier one is lost) or an edit within a region that was
+// concurrently removed (the edit is lost).
+type Conflict struct {
+	// Path is the path at which both Left and Right apply
+	Path []interface{}
+
+	// Left and Right are the conflicting changes relative to
+	// Path, with Left being part of the first arg of
+	// MergeWithReport
+	Left, Right Change
+
+	// LeftX and RightX are the results of merging Left and Right
+	LeftX, RightX Change
+
+	// Lost is the change that was discarded: either Left or Right
+	Lost Change
+}
+
+// MergeWithReport is like Merge(c1, c2) but it also calls report for
+// every conflict where a change is discarded.
+//
+// The results are equivalent to those of Merge though ChangeSet,
+// Meta and PathChange are merged element by element, so the results
+// may be structured differently.
+//
+// Concurrent deletes of the same elements are not considered
+// conflicts. Neither are Replace changes with the same After value
+// or Replace changes reconciled via Merger.
+func MergeWithReport(c1, c2 Change, report func(Conflict)) (c1x, c2x Change) {
+	return reporter{report}.merge(nil, c1, c2)
+}
+
+type reporter struct {
+	report func(Conflict)
+}
+
+// merge is equivalent to Merge(c1, c2) but with ChangeSet, Meta and
+// PathChange unwrapped so that the leaf changes can be compared.
+func (r reporter) merge(path []interface{}, c1, c2 Change) (Change, Change) {
+	switch l := c1.(type) {
+	case nil:
+		return c2, nil
+	case ChangeSet:
+		results := ChangeSet{}
+		for _, elt := range l {
+			var eltx Change
+			c2, eltx = r.merge(path, elt, c2)
+			results = append(results, eltx)
+		}
+		return c2, results.Simplify()
+	case Meta:
+		c2, l.Change = r.merge(path, l.Change, c2)
+		return c2, l
+	}
+
+	switch rr := c2.(type) {
+	case nil:
+		return nil, c1
+	case ChangeSet:
+		results := ChangeSet{}
+		for _, elt := range rr {
+			var eltx Change
+			eltx, c1 = r.merge(path, c1, elt)
+			results = append(results, eltx)
+		}
+		return results.Simplify(), c1
+	case Meta:
+		rr.Change, c1 = r.merge(path, c1, rr.Change)
+		return rr, c1
+	}
+
+	p1, ok1 := c1.(PathChange)
+	p2, ok2 := c2.(PathChange)
+	switch {
+	case ok1 && len(p1.Path) == 0:
+		return r.merge(path, p1.Change, c2)
+	case ok2 && len(p2.Path) == 0:
+		return r.merge(path, c1, p2.Change)
+	case ok1 && ok2:
+		return r.mergePaths(path, p1, p2)
+	}
+
+	c2x, c1x := c1.Merge(c2)
+	if lost := r.lost(c1, c2, c1x, c2x); lost != nil {
+		r.report(Conflict{path, c1, c2, c1x, c2x, lost})
+	}
+	return c2x, c1x
+}
+
+func (r reporter) mergePaths(path []interface{}, p1, p2 PathChange) (Change, Change) {
+	l := p1.commonPrefixLen(p1.Path, p2.Path)
+	if l < len(p1.Path) && l < len(p2.Path) {
+		return p2, p1
+	}
+
+	prefix := p1.Path[:l:l]
+	inner1 := PathChange{p1.Path[l:], p1.Change}.Simplify()
+	inner2 := PathChange{p2.Path[l:], p2.Change}.Simplify()
+	c2x, c1x := r.merge(append(path[:len(path):len(path)], prefix...), inner1, inner2)
+	return PathChange{prefix, c2x}.Simplify(), PathChange{prefix, c1x}.Simplify()
+}
+
+func (r reporter) lost(c1, c2, c1x, c2x Change) Change {
+	switch {
+	case Simplify(c1) == nil || Simplify(c2) == nil:
+		return nil
+	case Simplify(c1x) == nil && Simplify(c2x) == nil:
+		return nil
+	case r.isSameReplace(c1, c2):
+		return nil
+	case Simplify(c1x) == nil && !r.isDelete(c1, c2):
+		return c1
+	case Simplify(c2x) == nil && !r.isDelete(c2, c1):
+		return c2
+	}
+	return nil
+}
+
+// isDelete checks if c is a pure delete that was absorbed by a
+// concurrent splice
+func (r reporter) isDelete(c, other Change) bool {
+	s, ok1 := c.(Splice)
+	_, ok2 := other.(Splice)
+	return ok1 && ok2 && s.After.Count() == 0
+}
+
+func (r reporter) isSameReplace(c1, c2 Change) bool {
+	r1, ok1 := c1.(Replace)
+	r2, ok2 := c2.(Replace)
+	return ok1 && ok2 && reflect.DeepEqual(r1.After, r2.After)
+}
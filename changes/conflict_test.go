@@ -0,0 +1,163 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package changes_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+)
+
+func TestMergeWithReport(t *testing.T) {
+	replace := func(before, after changes.Value) changes.Change {
+		return changes.Replace{Before: before, After: after}
+	}
+	splice := func(offset int, before, after string) changes.Change {
+		return changes.Splice{Offset: offset, Before: S(before), After: S(after)}
+	}
+	path := func(c changes.Change, p ...interface{}) changes.Change {
+		return changes.PathChange{Path: p, Change: c}
+	}
+	edit := path(splice(0, "", "x"), "list", 1, "name")
+	elt := types.M{"name": S("")}
+	removed := path(changes.Splice{Offset: 1, Before: A{elt, elt}, After: A{}}, "list")
+
+	tests := map[string]struct {
+		c1, c2 changes.Change
+		path   []interface{}
+		lost   changes.Change
+	}{
+		"replace vs replace": {
+			c1:   replace(S("a"), S("b")),
+			c2:   replace(S("a"), S("c")),
+			lost: replace(S("a"), S("b")),
+		},
+		"delete vs replace": {
+			c1:   path(replace(S("a"), changes.Nil), "x"),
+			c2:   path(replace(S("a"), S("c")), "x"),
+			path: []interface{}{"x"},
+			lost: replace(S("a"), changes.Nil),
+		},
+		"same replace":     {c1: replace(S("a"), S("b")), c2: replace(S("a"), S("b"))},
+		"delete vs delete": {c1: replace(S("a"), changes.Nil), c2: replace(S("a"), changes.Nil)},
+		"replace vs splice": {
+			c1:   path(replace(S("abc"), S("x")), "x"),
+			c2:   path(splice(1, "b", "q"), "x"),
+			path: []interface{}{"x"},
+			lost: splice(1, "b", "q"),
+		},
+		"splice vs replace": {
+			c1:   path(splice(1, "b", "q"), "x"),
+			c2:   path(replace(S("abc"), S("x")), "x"),
+			path: []interface{}{"x"},
+			lost: splice(1, "b", "q"),
+		},
+		"edit in removed": {
+			c1:   removed,
+			c2:   edit,
+			path: []interface{}{"list"},
+			lost: path(splice(0, "", "x"), 1, "name"),
+		},
+		"removed under edit": {
+			c1:   changes.ChangeSet{edit},
+			c2:   changes.Meta{Data: "user", Change: removed},
+			path: []interface{}{"list"},
+			lost: path(splice(0, "", "x"), 1, "name"),
+		},
+		"insert in removed": {
+			c1:   splice(1, "bcd", ""),
+			c2:   splice(2, "", "x"),
+			lost: splice(2, "", "x"),
+		},
+		"removed around insert": {
+			c1:   splice(2, "", "x"),
+			c2:   splice(1, "bcd", ""),
+			lost: splice(2, "", "x"),
+		},
+		"overlapping deletes": {c1: splice(1, "bcd", ""), c2: splice(2, "c", "")},
+		"different paths":     {c1: path(replace(S("a"), S("b")), "x"), c2: path(replace(S("a"), S("c")), "y")},
+		"move in removed": {
+			c1:   splice(0, "abcd", ""),
+			c2:   changes.Move{Offset: 1, Count: 1, Distance: 1},
+			lost: changes.Move{Offset: 1, Count: 1, Distance: 1},
+		},
+		"empty path": {
+			c1:   path(replace(S("a"), S("b"))),
+			c2:   path(replace(S("a"), S("c"))),
+			lost: replace(S("a"), S("b")),
+		},
+		"nil": {c1: nil, c2: replace(S("a"), S("c"))},
+		"merge3": {
+			c1: replace(changes.Atomic{Value: point{1, 1, 0}}, changes.Atomic{Value: point{2, 1, 0}}),
+			c2: replace(changes.Atomic{Value: point{1, 1, 0}}, changes.Atomic{Value: point{1, 2, 0}}),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var conflicts []changes.Conflict
+			c1x, c2x := changes.MergeWithReport(test.c1, test.c2, func(c changes.Conflict) {
+				conflicts = append(conflicts, c)
+			})
+
+			e1, e2 := changes.Merge(test.c1, test.c2)
+			e1, e2 = changes.Simplify(e1), changes.Simplify(e2)
+			if !reflect.DeepEqual(c1x, e1) || !reflect.DeepEqual(c2x, e2) {
+				t.Error("Unexpected merge", c1x, c2x)
+			}
+
+			if test.lost == nil {
+				if len(conflicts) != 0 {
+					t.Error("Unexpected conflicts", conflicts)
+				}
+				return
+			}
+
+			if len(conflicts) != 1 {
+				t.Fatal("Unexpected conflicts", conflicts)
+			}
+			c := conflicts[0]
+			if !reflect.DeepEqual(c.Path, test.path) || !reflect.DeepEqual(c.Lost, test.lost) {
+				t.Error("Unexpected conflict", c.Path, c.Lost)
+			}
+			if !reflect.DeepEqual(c.Lost, c.Left) && !reflect.DeepEqual(c.Lost, c.Right) {
+				t.Error("Lost is neither left nor right", c)
+			}
+		})
+	}
+}
+
+func TestMergeWithReportRandom(t *testing.T) {
+	initial := changes.Value(types.A{S("abc"), types.M{"x": S("de")}})
+	chain := func(r *rand.Rand) changes.Change {
+		v, result := initial, changes.ChangeSet{}
+		for kk := r.Intn(3); kk >= 0; kk-- {
+			c := randComposeChange(r, v)
+			result = append(result, c)
+			v = v.Apply(nil, c)
+		}
+		return result
+	}
+
+	for seed := int64(0); seed < 1000; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		c1, c2 := chain(r), chain(r)
+		c1x, c2x := changes.MergeWithReport(c1, c2, func(c changes.Conflict) {
+			if !reflect.DeepEqual(c.Lost, c.Left) && !reflect.DeepEqual(c.Lost, c.Right) {
+				t.Fatal("Lost is neither left nor right", seed, c)
+			}
+		})
+
+		e1, e2 := changes.Merge(c1, c2)
+		v1, v2 := initial.Apply(nil, c1), initial.Apply(nil, c2)
+		if !reflect.DeepEqual(v1.Apply(nil, c1x), v1.Apply(nil, e1)) ||
+			!reflect.DeepEqual(v2.Apply(nil, c2x), v2.Apply(nil, e2)) {
+			t.Fatal("Merge mismatch", seed)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package crdt
+
+import "reflect"
+
+// Register calls the provided function with all the value and change
+// types of this package.  This can be used to register the types
+// with a codec:
+//
+//	crdt.Register(nw.Register)
+//
+// The changes of this package also hold changes.Splice and
+// changes.Move values which must be registered separately (nw
+// registers them by default).
+func Register(register func(v interface{})) {
+	types := []interface{}{
+		Container{},
+		Dict{},
+		Seq{},
+		Text{},
+		&Rank{},
+		wrapper{},
+		setContainer{},
+		unsetContainer{},
+		delContainer(0),
+		updContainer{},
+		updateDict{},
+		updValueSeq{},
+		updOrdSeq{},
+		trimContainer{},
+		restoreContainer{},
+		remapOrds{},
+		pruneSpaces{},
+		updRunsText{},
+		updMovesText{},
+		updDeletesText{},
+		textRun{},
+		textSpan{},
+		textPos{},
+		textDigit{},
+		shift{},
+		span(0),
+	}
+	for _, v := range types {
+		register(v)
+	}
+}
+
+// Ranks interns ranks.
+//
+// Containers use rank pointers as keys, so values and changes that
+// have been decoded (such as from the network) must be interned
+// before use: this ensures that equal ranks share the same pointer.
+type Ranks map[Rank]*Rank
+
+// Intern returns a copy of v with all ranks replaced by their
+// canonical pointers.  Ranks seen for the first time become
+// canonical.
+func (r Ranks) Intern(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return r.intern(reflect.ValueOf(v)).Interface()
+}
+
+var rankType = reflect.TypeOf(&Rank{})
+
+func (r Ranks) intern(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.Type() != rankType || v.IsNil() {
+			return v
+		}
+		rank := v.Interface().(*Rank)
+		if existing, ok := r[*rank]; ok {
+			return reflect.ValueOf(existing)
+		}
+		r[*rank] = rank
+		return v
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		result := reflect.New(v.Type()).Elem()
+		result.Set(r.intern(v.Elem()))
+		return result
+	case reflect.Struct:
+		result := reflect.New(v.Type()).Elem()
+		result.Set(v)
+		for kk := 0; kk < v.NumField(); kk++ {
+			if result.Field(kk).CanSet() {
+				result.Field(kk).Set(r.intern(v.Field(kk)))
+			}
+		}
+		return result
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		result := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for kk := 0; kk < v.Len(); kk++ {
+			result.Index(kk).Set(r.intern(v.Index(kk)))
+		}
+		return result
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		result := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, key := range v.MapKeys() {
+			result.SetMapIndex(r.intern(key), r.intern(v.MapIndex(key)))
+		}
+		return result
+	}
+	return v
+}
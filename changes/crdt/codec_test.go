@@ -0,0 +1,81 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package crdt_test
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/crdt"
+	"github.com/dotchain/dot/ops/sjson"
+)
+
+func TestCodec(t *testing.T) {
+	codec := &sjson.Codec{}
+	crdt.Register(codec.Register)
+	codec.Register(changes.Splice{})
+	codec.Register(changes.Move{})
+
+	s := crdt.Text{}
+	c1, s1 := s.Splice(0, 0, "hello")
+	c2, s2 := s1.Move(1, 2, 1)
+	c3, _ := s2.Splice(0, 1, "")
+
+	ranks := crdt.Ranks{}
+	ranks.Intern(c1)
+	for _, c := range []changes.Change{c1, c2, c3} {
+		var buf bytes.Buffer
+		if err := codec.Encode(c, &buf); err != nil {
+			t.Fatal("encode", err)
+		}
+		var decoded changes.Change
+		if err := codec.Decode(&decoded, &buf); err != nil {
+			t.Fatal("decode", err, buf.String())
+		}
+		if !reflect.DeepEqual(decoded, c) {
+			t.Fatal("mismatch", decoded, c)
+		}
+		s = s.Apply(nil, ranks.Intern(decoded).(changes.Change)).(crdt.Text)
+	}
+	if x := s.String(); x != "lelo" {
+		t.Error("Unexpected value", x)
+	}
+}
+
+func TestCodecCompactedSeq(t *testing.T) {
+	codec := &sjson.Codec{}
+	crdt.Register(codec.Register)
+
+	s := crdt.Seq{}
+	_, s = s.Splice(0, 0, []interface{}{"a", "b"})
+	_, s = s.Splice(1, 0, []interface{}{"c"})
+	c, s := s.Compact(math.MaxInt64)
+
+	roundtrip := func(v interface{}) interface{} {
+		var buf bytes.Buffer
+		if err := codec.Encode(v, &buf); err != nil {
+			t.Fatal("encode", err)
+		}
+		var decoded interface{}
+		if err := codec.Decode(&decoded, &buf); err != nil {
+			t.Fatal("decode", err, buf.String())
+		}
+		return decoded
+	}
+
+	if decoded := roundtrip(c); !reflect.DeepEqual(decoded, c) {
+		t.Fatal("mismatch", decoded, c)
+	}
+
+	decoded := crdt.Ranks{}.Intern(roundtrip(s)).(crdt.Seq)
+	_, s1 := s.Splice(1, 0, []interface{}{"x"})
+	_, s2 := decoded.Splice(1, 0, []interface{}{"x"})
+	if !reflect.DeepEqual(s1.Items(), s2.Items()) {
+		t.Fatal("mismatch", s1.Items(), s2.Items())
+	}
+}
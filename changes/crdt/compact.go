@@ -0,0 +1,477 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package crdt
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/dotchain/dot/changes"
+)
+
+// Compaction
+//
+// Containers accumulate a rank for every update ever made and Seq
+// ords get longer with repeated inserts at the same spot.  The
+// Compact methods return changes which discard this history without
+// modifying the effective value.
+//
+// History is only discarded for ranks whose Epoch is older than the
+// provided cutoff.  The cutoff should be chosen so that all peers
+// are known to have seen changes older than it: undoing a change
+// older than the cutoff after compaction may no longer restore the
+// previous value.
+//
+// Compaction changes commute with concurrent changes like other crdt
+// changes. Rebalancing a Seq creates a new "ord space": ords are
+// tagged with the space they were created in and ords from older or
+// concurrent spaces are mapped into the current space whenever they
+// are applied.  Spaces created before the cutoff are no longer
+// needed for this mapping and are dropped by later compactions, so
+// only the spaces created after the cutoff are retained.
+
+// Compact removes all ranks older than the cutoff epoch except the
+// one holding the current value.  It returns a nil change if there
+// is nothing to compact.
+func (c Container) Compact(before int64) (changes.Change, Container) {
+	trim := c.history(before)
+	if trim.empty() {
+		return nil, c
+	}
+	cx := wrapper{trimContainer{trim}}
+	return cx, cx.ApplyTo(nil, c).(Container)
+}
+
+// history returns the part of the container that can be compacted
+func (c Container) history(before int64) Container {
+	live := c
+	live.Deleted = 0
+	winner, _ := live.Get()
+
+	result := Container{Entries: map[*Rank]interface{}{}, Undos: map[*Rank]int{}}
+	for r, v := range c.Entries {
+		if r != winner && r.Epoch < before {
+			result.Entries[r] = v
+			if u := c.Undos[r]; u != 0 {
+				result.Undos[r] = u
+			}
+		}
+	}
+	return result
+}
+
+// olderThan returns true if all the ranks are older than the cutoff
+func (c Container) olderThan(before int64) bool {
+	for r := range c.Entries {
+		if r.Epoch >= before {
+			return false
+		}
+	}
+	for r := range c.Undos {
+		if r.Epoch >= before {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Container) empty() bool {
+	for _, u := range c.Undos {
+		if u != 0 {
+			return false
+		}
+	}
+	return len(c.Entries) == 0 && c.Deleted == 0
+}
+
+// Compact compacts all the containers (see Container.Compact) and
+// removes deleted keys whose ranks are all older than the cutoff
+// epoch. It returns a nil change if there is nothing to compact.
+func (d Dict) Compact(before int64) (changes.Change, Dict) {
+	result := wrapper{}
+	for key, c := range d.Entries {
+		if c.Deleted > 0 && c.olderThan(before) {
+			result = append(result, updateDict{key, wrapper{trimContainer{c}}})
+		} else if cx, _ := c.Compact(before); cx != nil {
+			result = append(result, updateDict{key, cx})
+		}
+	}
+	if len(result) == 0 {
+		return nil, d
+	}
+	return result, result.ApplyTo(nil, d).(Dict)
+}
+
+// Compact rebalances the ords so that they are consecutive integers
+// and compacts the underlying values and ords (see Dict.Compact),
+// dropping deleted items and ord spaces older than the cutoff
+// epoch. It returns a nil change if there is nothing to compact.
+func (s Seq) Compact(before int64) (changes.Change, Seq) {
+	result := wrapper{}
+	if prune := s.prune(before); prune != nil {
+		result = append(result, *prune)
+	}
+	if remap := s.rebalance(); remap != nil {
+		result = append(result, *remap)
+	}
+
+	mapped := result.ApplyTo(nil, s).(Seq)
+	if cx, _ := mapped.Values.Compact(before); cx != nil {
+		result = append(result, updValueSeq{cx})
+	}
+	if cx, _ := mapped.Ords.Compact(before); cx != nil {
+		result = append(result, updOrdSeq{cx})
+	}
+
+	if len(result) == 0 {
+		return nil, s
+	}
+	return result, result.ApplyTo(nil, s).(Seq)
+}
+
+func (s Seq) rebalance() *remapOrds {
+	ords, _ := s.items()
+	rank := NewRank()
+	result := remapOrds{ID: rank.String(), Base: s.space(), Epoch: rank.Epoch}
+	identity := true
+	for _, ord := range ords {
+		if len(result.From) > 0 && result.From[len(result.From)-1] == ord {
+			continue
+		}
+		var k ordkey
+		k.r.SetInt64(int64(len(result.From) + 1))
+		result.From = append(result.From, ord)
+		result.To = append(result.To, k.toString())
+		identity = identity && ord == k.toString()
+	}
+	if identity {
+		return nil
+	}
+	return &result
+}
+
+// prune drops the ord spaces older than the cutoff. The last such
+// space in the active chain becomes the root of the chain: ords
+// tagged with it no longer need mapping and so its From and To are
+// discarded too.
+func (s Seq) prune(before int64) *pruneSpaces {
+	root := ""
+	for _, id := range s.spaces()[1:] {
+		if s.Spaces[id].Epoch < before {
+			root = id
+		}
+	}
+
+	result := pruneSpaces{Root: root, Spaces: map[string]ordSpace{}}
+	for id, sp := range s.Spaces {
+		switch {
+		case id == root && (sp.Base != "" || len(sp.From) > 0):
+			result.Spaces[id] = sp
+		case id != root && sp.Epoch < before:
+			result.Spaces[id] = sp
+		}
+	}
+	if len(result.Spaces) == 0 {
+		return nil
+	}
+	return &result
+}
+
+// trimContainer removes the history of a container. The entries are
+// deleted while the undo and delete counts are subtracted, so that
+// this commutes with concurrent changes to the same ranks.
+type trimContainer struct {
+	Container
+}
+
+func (tc trimContainer) Revert() crdtChange {
+	return restoreContainer(tc)
+}
+
+func (tc trimContainer) ApplyTo(ctx changes.Context, v changes.Value) changes.Value {
+	result := v.(Container).Clone()
+	for r := range tc.Entries {
+		delete(result.Entries, r)
+	}
+	for r, u := range tc.Undos {
+		if result.Undos[r] -= u; result.Undos[r] == 0 {
+			delete(result.Undos, r)
+		}
+	}
+	result.Deleted -= tc.Deleted
+	return result
+}
+
+// restoreContainer undoes a trimContainer
+type restoreContainer struct {
+	Container
+}
+
+func (rc restoreContainer) Revert() crdtChange {
+	return trimContainer(rc)
+}
+
+func (rc restoreContainer) ApplyTo(ctx changes.Context, v changes.Value) changes.Value {
+	result := v.(Container).Clone()
+	for r, val := range rc.Entries {
+		result.Entries[r] = val
+	}
+	for r, u := range rc.Undos {
+		if result.Undos[r] += u; result.Undos[r] == 0 {
+			delete(result.Undos, r)
+		}
+	}
+	result.Deleted += rc.Deleted
+	return result
+}
+
+// remapOrds records a rebalancing of the ords of a Seq, creating a
+// new ord space (see Seq.Compact).  Reverting it disables the space
+// rather than removing it, so that ords created in it can still be
+// mapped.
+type remapOrds struct {
+	ID, Base string
+	Epoch    int64
+	From, To []string
+	Undo     bool
+}
+
+func (ro remapOrds) Revert() crdtChange {
+	ro.Undo = !ro.Undo
+	return ro
+}
+
+func (ro remapOrds) ApplyTo(ctx changes.Context, v changes.Value) changes.Value {
+	result := v.(Seq)
+	spaces := map[string]ordSpace{}
+	for id, sp := range result.Spaces {
+		spaces[id] = sp
+	}
+	sp := ordSpace{ro.Base, ro.Epoch, ro.From, ro.To, spaces[ro.ID].Count}
+	if ro.Undo {
+		sp.Count--
+	} else {
+		sp.Count++
+	}
+	spaces[ro.ID] = sp
+	result.Spaces = spaces
+	return result.normalize()
+}
+
+// pruneSpaces removes ord spaces (see Seq.prune). Spaces holds the
+// original state of the removed spaces and of the Root space, so
+// that the change can be reverted.
+type pruneSpaces struct {
+	Root   string
+	Spaces map[string]ordSpace
+	Undo   bool
+}
+
+func (ps pruneSpaces) Revert() crdtChange {
+	ps.Undo = !ps.Undo
+	return ps
+}
+
+func (ps pruneSpaces) ApplyTo(ctx changes.Context, v changes.Value) changes.Value {
+	result := v.(Seq)
+	spaces := map[string]ordSpace{}
+	for id, sp := range result.Spaces {
+		spaces[id] = sp
+	}
+
+	for id, sp := range ps.Spaces {
+		current, ok := spaces[id]
+		switch {
+		case id != ps.Root && ps.Undo:
+			spaces[id] = sp
+		case id != ps.Root:
+			delete(spaces, id)
+		case ok && ps.Undo:
+			current.Base, current.From, current.To = sp.Base, sp.From, sp.To
+			spaces[id] = current
+		case ok:
+			current.Base, current.From, current.To = "", nil, nil
+			spaces[id] = current
+		}
+	}
+	result.Spaces = spaces
+	return result.normalize()
+}
+
+// ordSpace is a rebalancing of ords: ords of the Base space are
+// mapped via the monotonic piecewise linear function that takes
+// From[i] to To[i].  The space is active if Count is positive.
+type ordSpace struct {
+	Base     string
+	Epoch    int64
+	From, To []string
+	Count    int
+}
+
+// spaces returns the chain of active ord spaces. Concurrent
+// compactions of the same space are resolved by picking the
+// smallest ID.
+func (s Seq) spaces() []string {
+	chain := []string{""}
+	for {
+		last, next, found := chain[len(chain)-1], "", false
+		for id, sp := range s.Spaces {
+			if sp.Base == last && sp.Count > 0 && (!found || id < next) {
+				next, found = id, true
+			}
+		}
+		if !found {
+			return chain
+		}
+		chain = append(chain, next)
+	}
+}
+
+// space returns the current ord space
+func (s Seq) space() string {
+	chain := s.spaces()
+	return chain[len(chain)-1]
+}
+
+// normalize maps all ords into the current ord space
+func (s Seq) normalize() Seq {
+	keys := make([]interface{}, 0, len(s.Ords.Entries))
+	for key := range s.Ords.Entries {
+		keys = append(keys, key)
+	}
+	return s.normalizeKeys(keys)
+}
+
+// normalizeKeys maps the ords of the provided keys into the current
+// ord space.  The mapper is only built if some ord needs mapping.
+func (s Seq) normalizeKeys(keys []interface{}) Seq {
+	if len(s.Spaces) == 0 {
+		return s
+	}
+
+	current := s.space()
+	var ords Dict
+	var fn func(string) string
+	for _, key := range keys {
+		c, ok := s.Ords.Entries[key]
+		if !ok || c.inSpace(current) {
+			continue
+		}
+		if fn == nil {
+			ords, fn = s.Ords.Clone(), s.mapper()
+		}
+		ords.Entries[key] = mapContainer(c, fn)
+	}
+	if fn != nil {
+		s.Ords = ords
+	}
+	return s
+}
+
+// inSpace returns true if all the ords in the container belong to
+// the provided space
+func (c Container) inSpace(space string) bool {
+	for _, v := range c.Entries {
+		if s, _ := splitOrd(v.(string)); s != space {
+			return false
+		}
+	}
+	return true
+}
+
+// mapper returns a function that maps ords into the current ord
+// space.  Ords are first mapped back to the closest active space
+// and then forward through the active chain.  All mappings are
+// exact, so the result does not depend on the order in which
+// compactions and other changes are applied.
+func (s Seq) mapper() func(string) string {
+	chain := s.spaces()
+	pos := map[string]int{}
+	forward := make([]func(string) string, len(chain))
+	for kk, id := range chain {
+		pos[id] = kk
+		if sp := s.Spaces[id]; kk > 0 {
+			forward[kk] = linearMap(sp.From, sp.To)
+		}
+	}
+	backward := map[string]func(string) string{}
+
+	return func(tagged string) string {
+		space, ord := splitOrd(tagged)
+		for {
+			if idx, ok := pos[space]; ok {
+				for _, fn := range forward[idx+1:] {
+					ord = fn(ord)
+				}
+				return joinOrd(chain[len(chain)-1], ord)
+			}
+			sp, ok := s.Spaces[space]
+			if !ok {
+				return tagged
+			}
+			if backward[space] == nil {
+				backward[space] = linearMap(sp.To, sp.From)
+			}
+			ord, space = backward[space](ord), sp.Base
+		}
+	}
+}
+
+// joinOrd tags an ord with the space it belongs to
+func joinOrd(space, ord string) string {
+	if space == "" {
+		return ord
+	}
+	return space + ":" + ord
+}
+
+func splitOrd(tagged string) (space, ord string) {
+	if idx := strings.LastIndex(tagged, ":"); idx >= 0 {
+		return tagged[:idx], tagged[idx+1:]
+	}
+	return "", tagged
+}
+
+// linearMap returns the monotonic piecewise linear function which
+// maps from[i] to to[i]
+func linearMap(fromOrds, toOrds []string) func(string) string {
+	from, to := make([]*ordkey, len(fromOrds)), make([]*ordkey, len(toOrds))
+	for kk := range fromOrds {
+		from[kk], to[kk] = fromString(fromOrds[kk]), fromString(toOrds[kk])
+	}
+
+	return func(ord string) string {
+		x, n := fromString(ord), len(from)
+		if n == 0 {
+			return ord
+		}
+
+		idx := sort.Search(n, func(i int) bool { return !from[i].less(x) })
+		var result ordkey
+		switch {
+		case idx < n && !x.less(from[idx]):
+			return toOrds[idx]
+		case idx == 0:
+			result.r.Sub(&x.r, &from[0].r).Add(&result.r, &to[0].r)
+		case idx == n:
+			result.r.Sub(&x.r, &from[n-1].r).Add(&result.r, &to[n-1].r)
+		default:
+			var scale, width ordkey
+			width.r.Sub(&from[idx].r, &from[idx-1].r)
+			scale.r.Sub(&to[idx].r, &to[idx-1].r).Quo(&scale.r, &width.r)
+			result.r.Sub(&x.r, &from[idx-1].r).Mul(&result.r, &scale.r)
+			result.r.Add(&result.r, &to[idx-1].r)
+		}
+		return result.toString()
+	}
+}
+
+func mapContainer(c Container, fn func(string) string) Container {
+	result := c.Clone()
+	for r, v := range result.Entries {
+		result.Entries[r] = fn(v.(string))
+	}
+	return result
+}
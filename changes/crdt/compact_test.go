@@ -0,0 +1,287 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package crdt_test
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/crdt"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/test/fuzztest"
+)
+
+func TestContainerCompact(t *testing.T) {
+	c := crdt.Container{}
+	_, c = c.Set(&crdt.Rank{Epoch: 1}, "one")
+	_, c = c.Set(&crdt.Rank{Epoch: 2}, "two")
+	c3, c := c.Set(&crdt.Rank{Epoch: 3}, "three")
+	_, c = c.Set(&crdt.Rank{Epoch: 4}, "four")
+	_, c = c.Apply(nil, c3.Revert()).(crdt.Container).Set(&crdt.Rank{Epoch: 5}, "five")
+
+	if cx, c2 := c.Compact(0); cx != nil || !reflect.DeepEqual(c2, c) {
+		t.Fatal("Unexpected compaction", cx)
+	}
+
+	cx, compacted := c.Compact(5)
+	if len(compacted.Entries) != 1 || len(compacted.Undos) != 0 {
+		t.Fatal("Unexpected compaction", compacted)
+	}
+	if _, v := compacted.Get(); v != "five" {
+		t.Fatal("Unexpected value", v)
+	}
+	if x := compacted.Apply(nil, cx.Revert()); !reflect.DeepEqual(x, c) {
+		t.Fatal("Revert failed", x)
+	}
+	if cx, _ := compacted.Compact(5); cx != nil {
+		t.Fatal("Unexpected compaction", cx)
+	}
+
+	// the current value is retained even if it is old
+	cx, compacted = c.Compact(100)
+	if _, v := compacted.Get(); len(compacted.Entries) != 1 || v != "five" {
+		t.Fatal("Unexpected compaction", compacted)
+	}
+
+	// concurrent updates to the current value survive compaction
+	_, c = c.Set(&crdt.Rank{Epoch: 6}, types.S8("hello"))
+	update, _ := c.Update(changes.Splice{Before: types.S8("h"), After: types.S8("H")})
+	cx, compacted = c.Compact(7)
+	x1 := compacted.Apply(nil, update).(crdt.Container)
+	x2 := c.Apply(nil, update).Apply(nil, cx)
+	if _, v := x1.Get(); v != types.S8("Hello") || !reflect.DeepEqual(x1, x2) {
+		t.Fatal("Diverged", x1, x2)
+	}
+}
+
+func TestDictCompact(t *testing.T) {
+	d := crdt.Dict{}
+	_, d = d.Set("old", "value")
+	_, d = d.Set("deleted", "value")
+	del, d := d.Delete("deleted")
+	_, d = d.Set("old", "value2")
+
+	cx, compacted := d.Compact(math.MaxInt64)
+	if _, ok := compacted.Entries["deleted"]; ok {
+		t.Fatal("Deleted key was not removed", compacted)
+	}
+	if _, v := compacted.Get("old"); v != "value2" || len(compacted.Entries["old"].Entries) != 1 {
+		t.Fatal("Unexpected compaction", compacted)
+	}
+	if x := compacted.Apply(nil, cx.Revert()); !reflect.DeepEqual(x, d) {
+		t.Fatal("Revert failed", x)
+	}
+
+	// concurrent undelete converges though the key stays deleted
+	undel := del.Revert()
+	x1 := compacted.Apply(nil, undel)
+	x2 := d.Apply(nil, undel).Apply(nil, cx)
+	if !reflect.DeepEqual(x1, x2) {
+		t.Fatal("Diverged", x1, x2)
+	}
+
+	if cx, _ := d.Compact(0); cx != nil {
+		t.Fatal("Unexpected compaction", cx)
+	}
+}
+
+func TestSeqCompact(t *testing.T) {
+	s := crdt.Seq{}
+	_, s = s.Splice(0, 0, []interface{}{"first", "last"})
+	for kk := 0; kk < 100; kk++ {
+		_, s = s.Splice(1, 0, []interface{}{kk})
+	}
+	_, s = s.Splice(10, 10, nil)
+
+	cx, compacted := s.Compact(math.MaxInt64)
+	if !reflect.DeepEqual(compacted.Items(), s.Items()) {
+		t.Fatal("Compaction changed items", compacted.Items())
+	}
+	if n := len(compacted.Ords.Entries); n != len(s.Items()) {
+		t.Fatal("Deleted ords not removed", n)
+	}
+	for _, c := range compacted.Ords.Entries {
+		_, ord := c.Get()
+		if parts := strings.Split(ord.(string), ":"); len(parts[len(parts)-1]) > 4 {
+			t.Fatal("Ord not rebalanced", ord)
+		}
+	}
+	x := compacted.Apply(nil, cx.Revert()).(crdt.Seq)
+	if !reflect.DeepEqual(x.Values, s.Values) || !reflect.DeepEqual(x.Ords, s.Ords) {
+		t.Fatal("Revert failed")
+	}
+	// compacting again only drops the ord space of the first
+	// compaction as it is now older than the cutoff
+	cx, compacted2 := compacted.Compact(math.MaxInt64)
+	if cx == nil || !reflect.DeepEqual(compacted2.Items(), s.Items()) {
+		t.Fatal("Unexpected compaction", cx)
+	}
+	if !reflect.DeepEqual(compacted2.Ords, compacted.Ords) {
+		t.Fatal("Unexpected ords change")
+	}
+	if cx, _ := compacted2.Compact(math.MaxInt64); cx != nil {
+		t.Fatal("Unexpected compaction", cx)
+	}
+
+	// concurrent edits
+	compact, _ := s.Compact(math.MaxInt64)
+	insert, _ := s.Splice(5, 0, []interface{}{"x", "y"})
+	move, _ := s.Move(2, 3, 10)
+	for _, c := range []changes.Change{insert, move, compact} {
+		cx, compactx := compact.Merge(c)
+		x1 := s.Apply(nil, compact).Apply(nil, cx).(crdt.Seq)
+		x2 := s.Apply(nil, c).Apply(nil, compactx).(crdt.Seq)
+		if !reflect.DeepEqual(x1, x2) {
+			t.Error("Diverged", x1.Items(), x2.Items())
+		}
+		if expected := s.Apply(nil, c).(crdt.Seq).Items(); !reflect.DeepEqual(x1.Items(), expected) {
+			t.Error("Unexpected items", x1.Items(), expected)
+		}
+	}
+}
+
+func TestSeqCompactCommutes(t *testing.T) {
+	s := crdt.Seq{}
+	_, s = s.Splice(0, 0, []interface{}{"first", "last"})
+	for kk := 0; kk < 10; kk++ {
+		_, s = s.Splice(1, 0, []interface{}{kk})
+	}
+
+	compact1, _ := s.Compact(math.MaxInt64)
+	compact2, _ := s.Compact(math.MaxInt64)
+	insert, _ := s.Splice(5, 0, []interface{}{"x"})
+	move, _ := s.Move(2, 3, 4)
+	all := []changes.Change{compact1, compact2, insert, move}
+	for _, c1 := range all {
+		for _, c2 := range all {
+			x1 := s.Apply(nil, c1).Apply(nil, c2).(crdt.Seq)
+			x2 := s.Apply(nil, c2).Apply(nil, c1).(crdt.Seq)
+			if !reflect.DeepEqual(x1, x2) {
+				t.Error("Diverged", c1, c2, x1.Items(), x2.Items())
+			}
+		}
+	}
+
+	// compacting on top of a concurrent compaction
+	_, s1 := s.Apply(nil, compact1).(crdt.Seq).Splice(3, 0, []interface{}{"y"})
+	compact3, _ := s1.Compact(math.MaxInt64)
+	x1 := s.Apply(nil, compact1).Apply(nil, compact2).Apply(nil, compact3).(crdt.Seq)
+	x2 := s.Apply(nil, compact2).Apply(nil, compact1).Apply(nil, compact3).(crdt.Seq)
+	if !reflect.DeepEqual(x1.Items(), x2.Items()) || !reflect.DeepEqual(x1.Ords, x2.Ords) {
+		t.Error("Diverged", x1.Items(), x2.Items())
+	}
+}
+
+func TestSeqCompactNested(t *testing.T) {
+	inner := crdt.Seq{}
+	_, inner = inner.Splice(0, 0, []interface{}{"a", "b"})
+	for kk := 0; kk < 10; kk++ {
+		_, inner = inner.Splice(1, 0, []interface{}{kk})
+	}
+	d := crdt.Dict{}
+	_, d = d.Set("seq", inner)
+
+	c1, _ := inner.Compact(math.MaxInt64)
+	c1, _ = d.Update("seq", c1)
+	c2, _ := inner.Splice(1, 0, []interface{}{"x"})
+	c2, _ = d.Update("seq", c2)
+
+	c2x, c1x := c1.Merge(c2)
+	x1 := d.Apply(nil, c1).Apply(nil, c2x).(crdt.Dict)
+	x2 := d.Apply(nil, c2).Apply(nil, c1x).(crdt.Dict)
+	_, s1 := x1.Get("seq")
+	_, s2 := x2.Get("seq")
+	if !reflect.DeepEqual(s1, s2) {
+		t.Fatal("Diverged", s1.(crdt.Seq).Items(), s2.(crdt.Seq).Items())
+	}
+	if x := s1.(crdt.Seq).Items(); x[1] != "x" {
+		t.Fatal("Unexpected items", x)
+	}
+}
+
+func TestSeqCompactBounded(t *testing.T) {
+	s := crdt.Seq{}
+	before := int64(0)
+	for round := 0; round < 6; round++ {
+		for kk := 0; kk < 20; kk++ {
+			_, s = s.Splice(kk, 0, []interface{}{kk})
+		}
+		_, s = s.Compact(before)
+		before = time.Now().UnixNano()
+	}
+
+	if n := len(s.Spaces); n > 2 {
+		t.Fatal("Unexpected number of spaces", n)
+	}
+	size := 0
+	for _, sp := range s.Spaces {
+		size += len(sp.From)
+	}
+	if n := len(s.Items()); size > n {
+		t.Fatal("Spaces not pruned", size, n)
+	}
+}
+
+func TestSeqCompactPruneRevert(t *testing.T) {
+	s := crdt.Seq{}
+	_, s = s.Splice(0, 0, []interface{}{"a", "b", "c"})
+	_, s = s.Compact(0)
+	_, s = s.Splice(1, 0, []interface{}{"x"})
+
+	cx, compacted := s.Compact(math.MaxInt64)
+	x := compacted.Apply(nil, cx.Revert()).(crdt.Seq)
+	if !reflect.DeepEqual(x.Values, s.Values) || !reflect.DeepEqual(x.Ords, s.Ords) {
+		t.Fatal("Revert failed", x.Items(), s.Items())
+	}
+}
+
+func TestSeqCompactConvergence(t *testing.T) {
+	// horizon is the cutoff for compaction: all the concurrent
+	// changes are made after the initial value is created.
+	var horizon int64
+	model := fuzztest.Model{
+		Value: func(r *rand.Rand) changes.Value {
+			s := crdt.Seq{}
+			for kk := r.Intn(5); kk >= 0; kk-- {
+				_, s = s.Splice(r.Intn(len(s.Items())+1), 0, []interface{}{kk})
+				if r.Intn(3) == 0 {
+					_, s = s.Compact(math.MaxInt64)
+				}
+			}
+			horizon = time.Now().UnixNano()
+			return s
+		},
+		Change: func(r *rand.Rand, v changes.Value) changes.Change {
+			s := v.(crdt.Seq)
+			n := len(s.Items())
+			var c changes.Change
+			switch r.Intn(4) {
+			case 0:
+				c, _ = s.Splice(r.Intn(n+1), 0, []interface{}{r.Intn(100)})
+			case 1:
+				if n > 0 {
+					c, _ = s.Splice(r.Intn(n), 1, nil)
+				}
+			case 2:
+				if n > 1 {
+					offset := r.Intn(n - 1)
+					c, _ = s.Move(offset, 1, r.Intn(n-offset-1)+1)
+				}
+			default:
+				c, _ = s.Compact(horizon)
+			}
+			return c
+		},
+		Equal: func(v1, v2 changes.Value) bool {
+			return reflect.DeepEqual(v1.(crdt.Seq).Items(), v2.(crdt.Seq).Items())
+		},
+	}
+	model.Check(t, 500)
+}
@@ -36,8 +36,10 @@ func (c Container) Delete() (changes.Change, Container) {
 }
 
 // Update wraps a change meant for the value within the container.
+// Deleted containers can still be updated so that the update is not
+// lost if the delete is undone.
 func (c Container) Update(inner changes.Change) (changes.Change, Container) {
-	r, _ := c.Get()
+	r, _ := c.latest()
 	cx := wrapper{updContainer{r, inner}}
 	return cx, cx.ApplyTo(nil, c).(Container)
 }
@@ -47,7 +49,10 @@ func (c Container) Get() (*Rank, interface{}) {
 	if c.Deleted > 0 {
 		return nil, nil
 	}
+	return c.latest()
+}
 
+func (c Container) latest() (*Rank, interface{}) {
 	var r *Rank
 	var result interface{}
 	for rank, val := range c.Entries {
@@ -74,8 +79,18 @@ func (c Container) Clone() Container {
 	return Container{Entries: entries, Undos: undos, Deleted: c.Deleted}
 }
 
-// Apply implements changes.Value
+// Apply implements changes.Value. Containers do not add to paths,
+// so a PathChange is applied to the current value.
 func (c Container) Apply(ctx changes.Context, cx changes.Change) changes.Value {
+	switch x := cx.(type) {
+	case nil:
+		return c
+	case changes.PathChange:
+		if len(x.Path) == 0 {
+			return c.Apply(ctx, x.Change)
+		}
+		cx, _ = c.Update(x)
+	}
 	return cx.(changes.Custom).ApplyTo(ctx, c)
 }
 
@@ -132,6 +147,9 @@ func (uc updContainer) Revert() crdtChange {
 
 func (uc updContainer) ApplyTo(ctx changes.Context, v changes.Value) changes.Value {
 	result := v.(Container).Clone()
-	result.Entries[uc.Rank] = result.Entries[uc.Rank].(changes.Value).Apply(ctx, uc.Change)
+	if val, ok := result.Entries[uc.Rank]; ok {
+		// the rank may have been compacted away concurrently
+		result.Entries[uc.Rank] = val.(changes.Value).Apply(ctx, uc.Change)
+	}
 	return result
 }
@@ -5,7 +5,15 @@
 // Package crdt implements CRDT types and associated changes
 //
 // The main CRDT types are Dict and Seq which implement map-like and
-// list-like container types.
+// list-like container types. Text implements a string type with
+// UTF16 offsets.
 //
 // The Container type can hold any mutable value.
+//
+// The Compact methods on these types discard older history so that
+// long-lived values stay small.
+//
+// The changes implement refs.PathMerger, so refs and substreams work
+// with crdt values embedded within other values. Paths into a Seq
+// use the item key (see Seq.Key) instead of the index.
 package crdt
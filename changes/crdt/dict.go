@@ -31,7 +31,9 @@ func (d Dict) Get(key interface{}) (*Rank, interface{}) {
 }
 
 // Update takes a change meant for the value at the provided key
-// and wraps it so that it can applied on the dict
+// and wraps it so that it can applied on the dict.  The result only
+// commutes with concurrent changes if inner is a crdt change (see
+// Commutes).
 func (d Dict) Update(key interface{}, inner changes.Change) (changes.Change, Dict) {
 	c, _ := d.Entries[key].Update(inner)
 	c = wrapper{updateDict{key, c}}
@@ -49,6 +51,15 @@ func (d Dict) Clone() Dict {
 
 // Apply implments changes.Value
 func (d Dict) Apply(ctx changes.Context, c changes.Change) changes.Value {
+	switch c := c.(type) {
+	case nil:
+		return d
+	case changes.PathChange:
+		return applyPath(ctx, d, c, func(key interface{}, inner changes.Change) changes.Change {
+			cx, _ := d.Update(key, inner)
+			return cx
+		})
+	}
 	return c.(changes.Custom).ApplyTo(ctx, d)
 }
 
@@ -64,5 +75,8 @@ func (ud updateDict) Revert() crdtChange {
 func (ud updateDict) ApplyTo(ctx changes.Context, v changes.Value) changes.Value {
 	result := v.(Dict).Clone()
 	result.Entries[ud.Key] = result.Entries[ud.Key].Apply(ctx, ud.Change).(Container)
+	if result.Entries[ud.Key].empty() {
+		delete(result.Entries, ud.Key)
+	}
 	return result
 }
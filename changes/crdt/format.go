@@ -0,0 +1,145 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package crdt
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/format"
+)
+
+// The String methods here render the crdt changes in the same
+// notation as https://godoc.org/github.com/dotchain/dot/changes/format
+// with container changes prefixed by "crdt" to keep them distinct
+// from the standard types.  These are only meant for debugging:
+// format.ParseChange does not parse them back.
+
+// String returns the epoch and nonce of the rank in base 36
+func (r *Rank) String() string {
+	if r == nil {
+		return "nil"
+	}
+	result := strconv.FormatInt(r.Epoch, 36)
+	for _, n := range r.Nonce {
+		result += "." + strconv.FormatInt(n, 36)
+	}
+	return result
+}
+
+func (w wrapper) String() string {
+	result := make([]string, len(w))
+	for kk, cx := range w {
+		result[kk] = fmt.Sprint(cx)
+	}
+	return "crdt(" + strings.Join(result, ", ") + ")"
+}
+
+func (sc setContainer) String() string {
+	return "crdtset(" + sc.Rank.String() + ": " + formatValue(sc.Value) + ")"
+}
+
+func (uc unsetContainer) String() string {
+	return "crdtunset(" + uc.Rank.String() + ": " + strconv.Itoa(uc.UndoCount) + ")"
+}
+
+func (dc delContainer) String() string {
+	return "crdtdelete(" + strconv.Itoa(int(dc)) + ")"
+}
+
+func (uc updContainer) String() string {
+	return "crdtupdate(" + uc.Rank.String() + ": " + format.Change(uc.Change) + ")"
+}
+
+func (ud updateDict) String() string {
+	return "dict(" + formatValue(ud.Key) + ": " + format.Change(ud.Change) + ")"
+}
+
+func (u updValueSeq) String() string {
+	return "values(" + format.Change(u.Change) + ")"
+}
+
+func (u updOrdSeq) String() string {
+	return "ords(" + format.Change(u.Change) + ")"
+}
+
+func (u updRunsText) String() string {
+	return "runs(" + format.Change(u.Change) + ")"
+}
+
+func (u updMovesText) String() string {
+	return "moves(" + format.Change(u.Change) + ")"
+}
+
+func (u updDeletesText) String() string {
+	return "deletes(" + format.Change(u.Change) + ")"
+}
+
+func (tc trimContainer) String() string {
+	return "trim(" + formatContainer(tc.Container) + ")"
+}
+
+func (rc restoreContainer) String() string {
+	return "restore(" + formatContainer(rc.Container) + ")"
+}
+
+func (ro remapOrds) String() string {
+	result := make([]string, len(ro.From))
+	for kk := range ro.From {
+		result[kk] = strconv.Quote(ro.From[kk]) + ": " + strconv.Quote(ro.To[kk])
+	}
+	name := "remap("
+	if ro.Undo {
+		name = "unremap("
+	}
+	return name + ro.ID + ": " + strings.Join(result, ", ") + ")"
+}
+
+func (ps pruneSpaces) String() string {
+	ids := make([]string, 0, len(ps.Spaces))
+	for id := range ps.Spaces {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	name := "prune("
+	if ps.Undo {
+		name = "unprune("
+	}
+	return name + ps.Root + ": " + strings.Join(ids, ", ") + ")"
+}
+
+func (s shift) String() string {
+	return "shift(" + format.Change(s.Change) + ")"
+}
+
+func formatContainer(c Container) string {
+	ranks := []*Rank{}
+	for r := range c.Entries {
+		ranks = append(ranks, r)
+	}
+	sort.Slice(ranks, func(i, j int) bool { return ranks[i].Less(ranks[j]) })
+
+	result := make([]string, len(ranks))
+	for kk, r := range ranks {
+		result[kk] = r.String() + ": " + formatValue(c.Entries[r])
+		if u := c.Undos[r]; u != 0 {
+			result[kk] += " -" + strconv.Itoa(u)
+		}
+	}
+	if c.Deleted != 0 {
+		result = append(result, "deleted: "+strconv.Itoa(c.Deleted))
+	}
+	return strings.Join(result, ", ")
+}
+
+func formatValue(v interface{}) string {
+	if val, ok := v.(changes.Value); ok {
+		return format.Value(val)
+	}
+	return fmt.Sprintf("%#v", v)
+}
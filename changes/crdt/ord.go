@@ -38,16 +38,26 @@ func BetweenOrd(a, b string, count int) []string {
 	return bk.between(ak, count)
 }
 
+// Ords are rationals encoded as "log2(denominator)+1,numerator" for
+// the common case where the denominator is a power of two and
+// "numerator/denominator" otherwise.  The numbers use base 62.
 func fromString(s string) *ordkey {
 	if s == "" {
 		return &ordkey{}
 	}
 
-	parts := strings.Split(s, ",")
 	var k ordkey
-	k.r.Num().SetString(parts[1], 62)
+	if parts := strings.Split(s, "/"); len(parts) == 2 {
+		num, _ := new(big.Int).SetString(parts[0], 62)
+		denom, _ := new(big.Int).SetString(parts[1], 62)
+		k.r.SetFrac(num, denom)
+		return &k
+	}
+
+	parts := strings.Split(s, ",")
+	num, _ := new(big.Int).SetString(parts[1], 62)
 	logd, _ := strconv.Atoi(parts[0])
-	k.r.Denom().Lsh(one, uint(logd-1))
+	k.r.SetFrac(num, new(big.Int).Lsh(one, uint(logd-1)))
 	return &k
 }
 
@@ -60,6 +70,9 @@ func (k ordkey) toString() string {
 	if d.Cmp(one) == 0 && n.BitLen() == 0 {
 		return ""
 	}
+	if new(big.Int).Lsh(one, uint(d.BitLen()-1)).Cmp(d) != 0 {
+		return n.Text(62) + "/" + d.Text(62)
+	}
 	return strconv.Itoa(d.BitLen()) + "," + n.Text(62)
 }
 
@@ -84,18 +97,36 @@ func (k *ordkey) next() *ordkey {
 var one = big.NewInt(1)
 
 func (k *ordkey) between(o *ordkey, count int) []string {
+	first, step := k.steps(o, count)
 	result := []string{}
-	inc := big.NewRat(int64(1), int64(count+1))
-	var diff big.Rat
-	diff.Sub(&k.r, &o.r).Abs(&diff).Mul(&diff, inc)
-
-	var last ordkey
-	last.r.Set(&k.r)
-
-	for count > 0 {
-		last.r.Add(&last.r, &diff)
+	for last := first; count > 0; count-- {
 		result = append(result, last.toString())
-		count--
+		last = last.add(step, 1)
 	}
 	return result
 }
+
+// steps returns the first of count evenly spaced keys between k and o
+// along with the step size
+func (k *ordkey) steps(o *ordkey, count int) (first, step *ordkey) {
+	// use a power of two for the step size to keep the keys short
+	steps := int64(2)
+	for steps < int64(count+1) {
+		steps *= 2
+	}
+
+	var diff ordkey
+	diff.r.Sub(&k.r, &o.r).Abs(&diff.r).Mul(&diff.r, big.NewRat(1, steps))
+	if o.less(k) {
+		k = o
+	}
+	return k.add(&diff, 1), &diff
+}
+
+// add returns k + step * n
+func (k *ordkey) add(step *ordkey, n int) *ordkey {
+	var result ordkey
+	result.r.SetInt64(int64(n))
+	result.r.Mul(&result.r, &step.r).Add(&result.r, &k.r)
+	return &result
+}
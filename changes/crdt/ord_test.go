@@ -112,3 +112,30 @@ func TestBetweenNegPositive(t *testing.T) {
 		left = mid
 	}
 }
+
+func TestBetweenCounts(t *testing.T) {
+	left, right := "", crdt.NextOrd("")
+	for count := 1; count < 10; count++ {
+		last := left
+		for _, mid := range crdt.BetweenOrd(left, right, count) {
+			if !crdt.LessOrd(last, mid) || !crdt.LessOrd(mid, right) {
+				t.Fatal("Between failed", count, last, mid, right)
+			}
+			last = mid
+		}
+	}
+}
+
+func TestRationalOrds(t *testing.T) {
+	third, half := "1/3", crdt.BetweenOrd("", "1,1", 1)[0]
+	if !crdt.LessOrd("", third) || !crdt.LessOrd(third, half) {
+		t.Error("Less failed", third, half)
+	}
+	if x := crdt.NextOrd(third); x != "1,1" {
+		t.Error("Unexpected next", x)
+	}
+	mid := crdt.BetweenOrd(third, half, 1)[0]
+	if !crdt.LessOrd(third, mid) || !crdt.LessOrd(mid, half) {
+		t.Error("Between failed", mid)
+	}
+}
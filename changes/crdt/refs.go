@@ -0,0 +1,136 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package crdt
+
+import (
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/refs"
+)
+
+// Paths
+//
+// Paths into a Dict use the dict key while paths into a Seq use the
+// key of the item (see Seq.Key) rather than the index: unlike
+// indices, these keys are not affected by concurrent inserts or
+// moves.  Containers are transparent and do not add any element to
+// the path.
+//
+// Paths (and so refs.Caret, refs.Range and streams.Substream) are
+// invalidated when the value they refer to is deleted or replaced.
+
+// MergePath implements refs.PathMerger
+func (w wrapper) MergePath(p []interface{}) *refs.MergeResult {
+	result := &refs.MergeResult{P: p}
+	var affected, unaffected wrapper
+	var scoped changes.ChangeSet
+	for _, cx := range w {
+		path, c, ok := mergePath(result.P, cx)
+		if !ok {
+			return nil
+		}
+		result.P = path
+		if c == nil {
+			unaffected = append(unaffected, cx)
+		} else {
+			affected = append(affected, cx)
+			scoped = append(scoped, c)
+		}
+	}
+
+	if len(scoped) == 1 {
+		result.Scoped = scoped[0]
+	} else if len(scoped) > 1 {
+		result.Scoped = scoped
+	}
+	if len(affected) > 0 {
+		result.Affected = affected
+	}
+	if len(unaffected) > 0 {
+		result.Unaffected = unaffected
+	}
+	return result
+}
+
+// MergeCaret implements the caret merger used by refs.Caret.
+//
+// Carets directly within a Seq or Text are updated using the shifts
+// recorded by Splice and Move (see shift).  Other structural changes
+// invalidate the caret.
+func (w wrapper) MergeCaret(caret refs.Caret) refs.Ref {
+	if s := w.shifts(); s != nil {
+		ref, _ := refs.Caret{Index: caret.Index, IsLeft: caret.IsLeft}.Merge(s)
+		if x, ok := ref.(refs.Caret); ok {
+			x.Path = caret.Path
+			return x
+		}
+		return ref
+	}
+	if w.structural() {
+		return refs.InvalidRef
+	}
+	return caret
+}
+
+// mergePath returns the updated path and the change scoped to
+// that path. It returns false if the path is no longer valid.
+func mergePath(p []interface{}, cx crdtChange) ([]interface{}, changes.Change, bool) {
+	switch cx := cx.(type) {
+	case setContainer, unsetContainer, delContainer:
+		return nil, nil, false
+	case updContainer:
+		return mergeResult(refs.Merge(p, cx.Change))
+	case updValueSeq:
+		if w, ok := cx.Change.(wrapper); ok {
+			return mergeResult(w.MergePath(p))
+		}
+	case updateDict:
+		w, ok := cx.Change.(wrapper)
+		if !ok || len(p) == 0 || p[0] != cx.Key {
+			break
+		}
+		path, scoped, ok := mergeResult(w.MergePath(p[1:]))
+		return append([]interface{}{p[0]}, path...), scoped, ok
+	}
+	return p, nil, true
+}
+
+func mergeResult(r *refs.MergeResult) ([]interface{}, changes.Change, bool) {
+	if r == nil {
+		return nil, nil, false
+	}
+	return r.P, r.Scoped, true
+}
+
+// structural returns true if the change could affect positions
+// within the value it applies to
+func (w wrapper) structural() bool {
+	for _, cx := range w {
+		var inner changes.Change
+		switch cx := cx.(type) {
+		case updContainer, trimContainer, restoreContainer, remapOrds, pruneSpaces, shift:
+			continue
+		case updValueSeq:
+			inner = cx.Change
+		case updateDict:
+			inner = cx.Change
+		default:
+			return true
+		}
+		if iw, ok := inner.(wrapper); !ok || iw.structural() {
+			return true
+		}
+	}
+	return false
+}
+
+// applyPath applies a PathChange using the provided update function
+// for the first element of the path
+func applyPath(ctx changes.Context, v changes.Value, pc changes.PathChange, update func(key interface{}, inner changes.Change) changes.Change) changes.Value {
+	if len(pc.Path) == 0 {
+		return v.Apply(ctx, pc.Change)
+	}
+	inner := changes.PathChange{Path: pc.Path[1:], Change: pc.Change}
+	return v.Apply(ctx, update(pc.Path[0], inner))
+}
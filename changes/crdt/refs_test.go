@@ -0,0 +1,139 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package crdt_test
+
+import (
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/crdt"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/refs"
+	"github.com/dotchain/dot/streams"
+)
+
+func TestSeqCaret(t *testing.T) {
+	s := crdt.Seq{}
+	_, s = s.Splice(0, 0, []interface{}{types.S16("hello"), types.S16("world")})
+	caret := refs.Caret{Path: refs.Path{s.Key(1)}, Index: 2}
+
+	// inserts and moves do not affect the path
+	insert, s2 := s.Splice(0, 0, []interface{}{types.S16("first")})
+	move, _ := s2.Move(0, 1, 2)
+	for _, c := range []changes.Change{insert, move} {
+		if x, _ := caret.Merge(c); !x.Equal(caret) {
+			t.Error("Unexpected merge", x)
+		}
+	}
+
+	// updates of the item adjust the index
+	splice := changes.Splice{Offset: 0, Before: types.S16(""), After: types.S16("ab")}
+	update, _ := s.Update(1, splice)
+	expected := refs.Caret{Path: caret.Path, Index: 4}
+	if x, _ := caret.Merge(update); !x.Equal(expected) {
+		t.Error("Unexpected merge", x)
+	}
+	update, _ = s.Update(0, splice)
+	if x, _ := caret.Merge(update); !x.Equal(caret) {
+		t.Error("Unexpected merge", x)
+	}
+
+	// deleting the item invalidates the caret
+	del, _ := s.Splice(1, 1, nil)
+	if x, _ := caret.Merge(del); x != refs.InvalidRef {
+		t.Error("Unexpected merge", x)
+	}
+
+	// carets directly in the seq are mapped through splices and moves
+	caret = refs.Caret{Index: 1}
+	if x, _ := caret.Merge(update); !x.Equal(caret) {
+		t.Error("Unexpected merge", x)
+	}
+	if x, _ := caret.Merge(insert); !x.Equal(refs.Caret{Index: 2}) {
+		t.Error("Unexpected merge", x)
+	}
+	if x, _ := caret.Merge(del); !x.Equal(caret) {
+		t.Error("Unexpected merge", x)
+	}
+}
+
+func TestDictRange(t *testing.T) {
+	inner := crdt.Seq{}
+	_, inner = inner.Splice(0, 0, []interface{}{types.S16("hello")})
+	d := crdt.Dict{}
+	_, d = d.Set("list", inner)
+
+	path := refs.Path{"list", inner.Key(0)}
+	r := refs.Range{
+		Start: refs.Caret{Path: path, Index: 1},
+		End:   refs.Caret{Path: path, Index: 3},
+	}
+
+	splice := changes.Splice{Offset: 2, Before: types.S16("l"), After: types.S16("LLL")}
+	c, _ := inner.Update(0, splice)
+	c, _ = d.Update("list", c)
+	expected := refs.Range{
+		Start: refs.Caret{Path: path, Index: 1},
+		End:   refs.Caret{Path: path, Index: 5},
+	}
+	if x, _ := r.Merge(c); !x.Equal(expected) {
+		t.Error("Unexpected merge", x)
+	}
+
+	// replacing the list invalidates the range
+	c, _ = d.Set("list", crdt.Seq{})
+	if x, _ := r.Merge(c); x != refs.InvalidRef {
+		t.Error("Unexpected merge", x)
+	}
+
+	// other keys do not affect the range
+	c, _ = d.Set("other", crdt.Seq{})
+	if x, _ := r.Merge(c); !x.Equal(r) {
+		t.Error("Unexpected merge", x)
+	}
+}
+
+func TestSubstream(t *testing.T) {
+	s := crdt.Seq{}
+	_, s = s.Splice(0, 0, []interface{}{types.S16("hello")})
+	key := s.Key(0)
+
+	initial := streams.New()
+	sub := streams.Substream(initial, key)
+
+	insert, _ := s.Splice(0, 0, []interface{}{types.S16("first")})
+	initial.Append(insert)
+	splice := changes.Splice{Offset: 5, Before: types.S16(""), After: types.S16(" world")}
+	sub.Append(splice)
+
+	var v changes.Value = s
+	for next, c := initial.Next(); next != nil; next, c = next.Next() {
+		v = v.Apply(nil, c)
+	}
+	x := v.(crdt.Seq).Items()
+	if len(x) != 2 || x[0] != types.S16("first") || x[1] != types.S16("hello world") {
+		t.Error("Unexpected value", x)
+	}
+
+	// substream changes are dropped if the item is deleted
+	initial = streams.New()
+	sub = streams.Substream(initial, key)
+	del, _ := s.Splice(0, 1, nil)
+	initial.Append(del)
+	sub.Append(splice)
+
+	v = s
+	for next, c := initial.Next(); next != nil; next, c = next.Next() {
+		v = v.Apply(nil, c)
+	}
+	if x := v.(crdt.Seq).Items(); len(x) != 0 {
+		t.Error("Unexpected value", x)
+	}
+	if next, _ := sub.Next(); next != nil {
+		if next, _ = next.Next(); next != nil {
+			t.Error("Substream not invalidated")
+		}
+	}
+}
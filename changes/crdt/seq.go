@@ -14,6 +14,7 @@ import (
 type Seq struct {
 	Values Dict
 	Ords   Dict
+	Spaces map[string]ordSpace
 }
 
 // Items returns the seq as an array
@@ -38,14 +39,19 @@ func (s Seq) Splice(offset, remove int, replacement []interface{}) (changes.Chan
 	}
 
 	newOrds := s.between(ords, offset, remove, len(replacement))
+	space := s.space()
 	for kk, ord := range newOrds {
 		key := NewRank()
-		inner, _ := s.Ords.Set(key, ord)
+		inner, _ := s.Ords.Set(key, joinOrd(space, ord))
 		result = append(result, updOrdSeq{inner})
 		inner, _ = s.Values.Set(key, replacement[kk])
 		result = append(result, updValueSeq{inner})
 	}
 
+	if remove > 0 || len(replacement) > 0 {
+		splice := changes.Splice{Offset: offset, Before: span(remove), After: span(len(replacement))}
+		result = append(result, shift{splice})
+	}
 	return result, result.ApplyTo(nil, s).(Seq)
 }
 
@@ -57,16 +63,21 @@ func (s Seq) Move(offset, count, distance int) (changes.Change, Seq) {
 	} else {
 		ords = s.between(ords, offset+distance, 0, count)
 	}
-	result := wrapper{}
+	result, space := wrapper{}, s.space()
 	for kk := 0; kk < count; kk++ {
-		inner, _ := s.Ords.Set(keys[offset+kk], ords[kk])
+		inner, _ := s.Ords.Set(keys[offset+kk], joinOrd(space, ords[kk]))
 		result = append(result, updOrdSeq{inner})
 	}
+	if count > 0 && distance != 0 {
+		result = append(result, shift{changes.Move{Offset: offset, Count: count, Distance: distance}})
+	}
 	return result, result.ApplyTo(nil, s).(Seq)
 }
 
 // Update takes a change meant for the value at a specific index
-// and wraps it so that it can applied on the Seq
+// and wraps it so that it can applied on the Seq.  The result only
+// commutes with concurrent changes if inner is a crdt change (see
+// Commutes).
 func (s Seq) Update(idx int, inner changes.Change) (changes.Change, Seq) {
 	_, keys := s.items()
 	c, _ := s.Values.Update(keys[idx], inner)
@@ -74,8 +85,37 @@ func (s Seq) Update(idx int, inner changes.Change) (changes.Change, Seq) {
 	return c, c.(changes.Custom).ApplyTo(nil, s).(Seq)
 }
 
+// Key returns the key of the item at the provided index. Unlike the
+// index, the key of an item does not change with inserts or moves
+// and so paths into a Seq use this key.
+func (s Seq) Key(idx int) interface{} {
+	_, keys := s.items()
+	return keys[idx]
+}
+
+// Index returns the current index of the item with the provided
+// key or -1 if there is no such item.
+func (s Seq) Index(key interface{}) int {
+	_, keys := s.items()
+	for kk, k := range keys {
+		if k == key {
+			return kk
+		}
+	}
+	return -1
+}
+
 // Apply implements changes.Value
 func (s Seq) Apply(ctx changes.Context, c changes.Change) changes.Value {
+	switch c := c.(type) {
+	case nil:
+		return s
+	case changes.PathChange:
+		return applyPath(ctx, s, c, func(key interface{}, inner changes.Change) changes.Change {
+			cx, _ := s.Values.Update(key, inner)
+			return wrapper{updValueSeq{cx}}
+		})
+	}
 	return c.(changes.Custom).ApplyTo(ctx, s)
 }
 
@@ -99,29 +139,47 @@ func (s Seq) between(ords []string, offset, remove, count int) []string {
 	return BetweenOrd(start, end, count)
 }
 
+// items returns the sorted ords (without the space tag) and
+// keys. Concurrent inserts at the same position can end up with the
+// same ords, so ties are broken using the keys.
 func (s Seq) items() ([]string, []interface{}) {
-	ords := []string{}
-	keysmap := map[string]interface{}{}
+	ords, keys := []string{}, []interface{}{}
 	for key, container := range s.Ords.Entries {
 		if x, _ := s.Values.Get(key); x == nil {
 			continue
 		}
 
 		if r, val := container.Get(); r != nil {
-			ords = append(ords, val.(string))
-			keysmap[val.(string)] = key
+			_, ord := splitOrd(val.(string))
+			ords = append(ords, ord)
+			keys = append(keys, key)
 		}
 	}
-	sort.Slice(ords, func(i, j int) bool {
-		return LessOrd(ords[i], ords[j])
-	})
-	keys := make([]interface{}, len(ords))
-	for kk, o := range ords {
-		keys[kk] = keysmap[o]
-	}
+	sort.Sort(byOrd{ords, keys})
 	return ords, keys
 }
 
+type byOrd struct {
+	ords []string
+	keys []interface{}
+}
+
+func (b byOrd) Len() int {
+	return len(b.ords)
+}
+
+func (b byOrd) Less(i, j int) bool {
+	if b.ords[i] != b.ords[j] {
+		return LessOrd(b.ords[i], b.ords[j])
+	}
+	return b.keys[i].(*Rank).Less(b.keys[j].(*Rank))
+}
+
+func (b byOrd) Swap(i, j int) {
+	b.ords[i], b.ords[j] = b.ords[j], b.ords[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
+
 type updValueSeq struct {
 	changes.Change
 }
@@ -147,5 +205,21 @@ func (u updOrdSeq) Revert() crdtChange {
 func (u updOrdSeq) ApplyTo(ctx changes.Context, v changes.Value) changes.Value {
 	result := v.(Seq)
 	result.Ords = result.Ords.Apply(ctx, u.Change).(Dict)
-	return result
+	if keys, ok := dictKeys(u.Change, nil); ok {
+		return result.normalizeKeys(keys)
+	}
+	return result.normalize()
+}
+
+// dictKeys returns the keys modified by a Dict change. It returns
+// false if the keys cannot be determined.
+func dictKeys(c changes.Change, keys []interface{}) ([]interface{}, bool) {
+	w, ok := c.(wrapper)
+	for kk := 0; ok && kk < len(w); kk++ {
+		var ud updateDict
+		if ud, ok = w[kk].(updateDict); ok {
+			keys = append(keys, ud.Key)
+		}
+	}
+	return keys, ok
 }
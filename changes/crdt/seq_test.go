@@ -97,15 +97,31 @@ func TestSeqUpdate(t *testing.T) {
 		t.Error("Apply(revert) diverged", x)
 	}
 
-	// test merges of c1 with c1.revert
-	c2 := c1.Revert()
+	// concurrent updates of the same item converge
+	c2, _ := s.Update(0, changes.Splice{Offset: 5, Before: types.S16(""), After: types.S16("!")})
 	x1, x2 := c1.Merge(c2)
-	if !reflect.DeepEqual(x2, c1) || !reflect.DeepEqual(x1, c2) {
-		t.Error("Merge had unexpected behavior", c1, c2)
+	v1 := s.Apply(nil, c1).Apply(nil, x1).(crdt.Seq)
+	v2 := s.Apply(nil, c2).Apply(nil, x2).(crdt.Seq)
+	if x := v1.Items(); !reflect.DeepEqual(x, v2.Items()) || x[0] != types.S16("Hello!") {
+		t.Error("Merge diverged", x, v2.Items())
 	}
 
-	x1, x2 = c1.(changes.Custom).ReverseMerge(c2)
-	if !reflect.DeepEqual(x2, c1) || !reflect.DeepEqual(x1, c2) {
-		t.Error("Merge had unexpected behavior", c1, c2)
+	x2, x1 = c2.(changes.Custom).ReverseMerge(c1)
+	v1 = s.Apply(nil, c1).Apply(nil, x1).(crdt.Seq)
+	v2 = s.Apply(nil, c2).Apply(nil, x2).(crdt.Seq)
+	if x := v1.Items(); !reflect.DeepEqual(x, v2.Items()) || x[0] != types.S16("Hello!") {
+		t.Error("ReverseMerge diverged", x, v2.Items())
+	}
+}
+
+func TestSeqSameOrds(t *testing.T) {
+	s := crdt.Seq{}
+	c1, _ := s.Splice(0, 0, []interface{}{"a"})
+	c2, _ := s.Splice(0, 0, []interface{}{"b"})
+
+	s1 := s.Apply(nil, c1).Apply(nil, c2).(crdt.Seq)
+	s2 := s.Apply(nil, c2).Apply(nil, c1).(crdt.Seq)
+	if x := s1.Items(); len(x) != 2 || !reflect.DeepEqual(x, s2.Items()) {
+		t.Fatal("Concurrent inserts diverged", x, s2.Items())
 	}
 }
@@ -0,0 +1,87 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package crdt
+
+import "github.com/dotchain/dot/changes"
+
+// shift records the effect of a Splice or Move of a Seq or Text on
+// indices as a changes.Splice or changes.Move of spans.  It does not
+// modify the value but it is transformed by Merge like regular
+// changes, so that carets can be updated without the value (see
+// MergeCaret).
+type shift struct {
+	changes.Change
+}
+
+func (s shift) Revert() crdtChange {
+	return shift{s.Change.Revert()}
+}
+
+func (s shift) ApplyTo(ctx changes.Context, v changes.Value) changes.Value {
+	return v
+}
+
+// shifts returns all the shifts in w as a single change
+func (w wrapper) shifts() changes.Change {
+	var result changes.ChangeSet
+	for _, cx := range w {
+		if s, ok := cx.(shift); ok {
+			result = append(result, s.Change)
+		}
+	}
+	if len(result) == 1 {
+		return result[0]
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// withShifts replaces all shifts in w with the provided change
+func (w wrapper) withShifts(c changes.Change) wrapper {
+	result := wrapper{}
+	for _, cx := range w {
+		if _, ok := cx.(shift); !ok {
+			result = append(result, cx)
+		}
+	}
+	if c != nil {
+		result = append(result, shift{c})
+	}
+	return result
+}
+
+// span is a collection of the given size with no actual items.
+type span int
+
+func (s span) Count() int {
+	return int(s)
+}
+
+func (s span) Slice(offset, count int) changes.Collection {
+	return span(count)
+}
+
+func (s span) Apply(ctx changes.Context, c changes.Change) changes.Value {
+	return s.ApplyCollection(ctx, c)
+}
+
+func (s span) ApplyCollection(ctx changes.Context, c changes.Change) changes.Collection {
+	switch c := c.(type) {
+	case nil, changes.Move:
+		return s
+	case changes.Splice:
+		return s + span(c.After.Count()-c.Before.Count())
+	case changes.ChangeSet:
+		for _, cx := range c {
+			s = s.ApplyCollection(ctx, cx).(span)
+		}
+		return s
+	case changes.Replace:
+		return c.After.(changes.Collection)
+	}
+	return c.(changes.Custom).ApplyTo(ctx, s).(changes.Collection)
+}
@@ -0,0 +1,441 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package crdt
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/dotchain/dot/changes"
+)
+
+// Text implements a CRDT-style string. All offsets and counts are in
+// UTF16 units to match Javascript strings.
+//
+// Inserted text is stored as runs.  Each run has a position which
+// is a list of digits (see textPos) and the characters of the run
+// are ordered by their index within it.  Concurrent inserts at the
+// same spot get different ranks and so stay contiguous rather than
+// interleaving.  Deletes and moves are tracked as spans of runs, so
+// a contiguous edit only adds a single entry regardless of the
+// number of characters.
+//
+// Text is plain text: it does not hold any formatting.
+//
+// The sorted order of the characters is cached (see textOrders) and
+// Splice updates the cache directly, so consecutive local edits do
+// not need to sort all the characters.
+type Text struct {
+	// Runs maps unique keys to the inserted runs
+	Runs Dict
+
+	// Moves maps spans of runs to their new positions
+	Moves Dict
+
+	// Deletes tracks the deleted spans of runs
+	Deletes Dict
+}
+
+// String returns the current value of the text
+func (t Text) String() string {
+	chars := t.chars()
+	units := make([]uint16, len(chars))
+	for kk, ch := range chars {
+		units[kk] = ch.unit
+	}
+	return string(utf16.Decode(units))
+}
+
+// Count returns the number of UTF16 units in the text
+func (t Text) Count() int {
+	return len(t.chars())
+}
+
+// Splice replaces t[offset:offset+count] with the provided insert
+// string
+func (t Text) Splice(offset, count int, insert string) (changes.Change, Text) {
+	chars := t.chars()
+	result := wrapper{}
+	for _, span := range textSpans(chars[offset : offset+count]) {
+		inner, _ := t.Deletes.Delete(span)
+		result = append(result, updDeletesText{inner})
+	}
+
+	n, key := 0, (*Rank)(nil)
+	if insert != "" {
+		key = NewRank()
+		run := textRun{t.between(chars, offset, count, key), insert}
+		inner, _ := t.Runs.Set(key, run)
+		result = append(result, updRunsText{inner})
+		n = len(utf16.Encode([]rune(insert)))
+	}
+
+	if count > 0 || n > 0 {
+		splice := changes.Splice{Offset: offset, Before: span(count), After: span(n)}
+		result = append(result, shift{splice})
+	}
+
+	updated := result.ApplyTo(nil, t).(Text)
+	sorted := make([]textChar, 0, len(chars)-count+n)
+	sorted = append(sorted, chars[:offset]...)
+	if n > 0 {
+		_, v := updated.Runs.Get(key)
+		sorted = append(sorted, t.runChars(key, v.(textRun), nil, nil)...)
+	}
+	sorted = append(sorted, chars[offset+count:]...)
+	textOrders.store(updated, sorted)
+	return result, updated
+}
+
+// Move shifts t[offset:offset+count] by the provided distance to the
+// right (or to the left if distance is negative)
+func (t Text) Move(offset, count, distance int) (changes.Change, Text) {
+	chars := t.chars()
+	var pos textPos
+	if distance > 0 {
+		pos = t.between(chars, offset+count+distance, 0, NewRank())
+	} else {
+		pos = t.between(chars, offset+distance, 0, NewRank())
+	}
+
+	result := wrapper{}
+	moved := 0
+	for _, span := range textSpans(chars[offset : offset+count]) {
+		spanPos := append(textPos(nil), pos...)
+		spanPos[len(pos)-1].Index = moved
+		inner, _ := t.Moves.Set(span, spanPos)
+		result = append(result, updMovesText{inner})
+		moved += span.Count
+	}
+	if count > 0 && distance != 0 {
+		result = append(result, shift{changes.Move{Offset: offset, Count: count, Distance: distance}})
+	}
+	return result, result.ApplyTo(nil, t).(Text)
+}
+
+// Apply implements changes.Value
+func (t Text) Apply(ctx changes.Context, c changes.Change) changes.Value {
+	switch c := c.(type) {
+	case nil:
+		return t
+	case changes.Replace:
+		if c.IsDelete() {
+			return changes.Nil
+		}
+		return c.After
+	}
+	return c.(changes.Custom).ApplyTo(ctx, t)
+}
+
+// between returns a position for the characters that replace
+// chars[offset:offset+remove]
+func (t Text) between(chars []textChar, offset, remove int, rank *Rank) textPos {
+	var left, right *textKey
+	if offset > 0 {
+		left = &chars[offset-1].key
+	}
+	if offset+remove < len(chars) {
+		right = &chars[offset+remove].key
+	}
+
+	// keep the digits of left until there is a gap
+	result := textPos{}
+	for kk := 0; ; kk++ {
+		var lo, hi *ordkey
+		if left != nil && kk < len(left.pos) {
+			lo = left.ords[kk]
+		}
+		if right != nil && kk < len(right.pos) {
+			hi = right.ords[kk]
+		}
+
+		var ord *ordkey
+		switch {
+		case lo == nil && hi == nil:
+			ord = &ordkey{}
+		case lo == nil:
+			ord = hi.prev()
+		case hi == nil:
+			ord = lo.next()
+		case lo.less(hi):
+			ord, _ = lo.steps(hi, 1)
+		default:
+			if left.compare(*right, kk) != 0 {
+				right = nil
+			}
+			result = append(result, left.digit(kk))
+			continue
+		}
+		return append(result, textDigit{ord.toString(), rank, 0})
+	}
+}
+
+// chars returns the visible characters sorted by their positions.
+// The result is shared with the cache and must not be modified.
+func (t Text) chars() []textChar {
+	if result, ok := textOrders.load(t); ok {
+		return result
+	}
+
+	result := t.visible()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].key.less(result[j].key)
+	})
+	textOrders.store(t, result)
+	return result
+}
+
+// textOrders caches the sorted characters of the most recently used
+// Text values.
+//
+// Every change to a Text replaces the maps of the Dicts it modifies,
+// so the maps identify the state of a Text.  Entries hold on to the
+// maps, so their addresses cannot be reused while they are cached.
+var textOrders = &textOrderCache{}
+
+type textOrderCache struct {
+	sync.Mutex
+	entries [16]textOrder
+	next    int
+}
+
+type textOrder struct {
+	runs, moves, deletes map[interface{}]Container
+	chars                []textChar
+}
+
+func (t Text) sameMaps(o textOrder) bool {
+	same := func(m1, m2 map[interface{}]Container) bool {
+		return reflect.ValueOf(m1).Pointer() == reflect.ValueOf(m2).Pointer()
+	}
+	return o.chars != nil &&
+		same(t.Runs.Entries, o.runs) &&
+		same(t.Moves.Entries, o.moves) &&
+		same(t.Deletes.Entries, o.deletes)
+}
+
+func (c *textOrderCache) load(t Text) ([]textChar, bool) {
+	c.Lock()
+	defer c.Unlock()
+	for _, o := range c.entries {
+		if t.sameMaps(o) {
+			return o.chars, true
+		}
+	}
+	return nil, false
+}
+
+func (c *textOrderCache) store(t Text, chars []textChar) {
+	if chars == nil {
+		chars = []textChar{}
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.entries[c.next] = textOrder{t.Runs.Entries, t.Moves.Entries, t.Deletes.Entries, chars}
+	c.next = (c.next + 1) % len(c.entries)
+}
+
+// visible returns the visible characters in no particular order
+func (t Text) visible() []textChar {
+	deletes := map[*Rank][]textSpan{}
+	for key, c := range t.Deletes.Entries {
+		if span := key.(textSpan); c.Deleted > 0 {
+			deletes[span.Run] = append(deletes[span.Run], span)
+		}
+	}
+
+	moves := map[*Rank][]textSpan{}
+	for key := range t.Moves.Entries {
+		span := key.(textSpan)
+		moves[span.Run] = append(moves[span.Run], span)
+	}
+
+	result := []textChar{}
+	for key, c := range t.Runs.Entries {
+		r, v := c.Get()
+		if r == nil {
+			continue
+		}
+		result = append(result, t.runChars(key.(*Rank), v.(textRun), deletes[key.(*Rank)], moves[key.(*Rank)])...)
+	}
+	return result
+}
+
+func (t Text) runChars(key *Rank, run textRun, deletes, moves []textSpan) []textChar {
+	units := utf16.Encode([]rune(run.Text))
+	chars := make([]textChar, len(units))
+	ords := run.Pos.ords()
+	for kk, unit := range units {
+		chars[kk] = textChar{key, kk, unit, textKey{run.Pos, ords, kk}}
+	}
+
+	ranks := make([]*Rank, len(units))
+	for _, span := range moves {
+		r, v := t.Moves.Get(span)
+		if r == nil {
+			continue
+		}
+		pos := v.(textPos)
+		ords := pos.ords()
+		for kk := span.Start; kk < span.Start+span.Count; kk++ {
+			if ranks[kk] == nil || ranks[kk].Less(r) {
+				ranks[kk] = r
+				chars[kk].key = textKey{pos, ords, kk - span.Start}
+			}
+		}
+	}
+
+	deleted := make([]bool, len(units))
+	for _, span := range deletes {
+		for kk := span.Start; kk < span.Start+span.Count; kk++ {
+			deleted[kk] = true
+		}
+	}
+
+	result := chars[:0]
+	for kk, ch := range chars {
+		if !deleted[kk] {
+			result = append(result, ch)
+		}
+	}
+	return result
+}
+
+// textSpans groups consecutive characters of the same run
+func textSpans(chars []textChar) []textSpan {
+	result := []textSpan{}
+	for _, ch := range chars {
+		if l := len(result) - 1; l >= 0 && result[l].Run == ch.run && result[l].Start+result[l].Count == ch.idx {
+			result[l].Count++
+		} else {
+			result = append(result, textSpan{ch.run, ch.idx, 1})
+		}
+	}
+	return result
+}
+
+type textChar struct {
+	run  *Rank
+	idx  int
+	unit uint16
+	key  textKey
+}
+
+// textKey is the position of a character: the position of its run
+// (or of the move) with idx added to the index of the last digit.
+// The ords of the digits are parsed once per run.
+type textKey struct {
+	pos  textPos
+	ords []*ordkey
+	idx  int
+}
+
+func (k textKey) digit(n int) textDigit {
+	d := k.pos[n]
+	if n == len(k.pos)-1 {
+		d.Index += k.idx
+	}
+	return d
+}
+
+// compare compares the n'th digits of the two keys
+func (k textKey) compare(o textKey, n int) int {
+	if c := k.ords[n].r.Cmp(&o.ords[n].r); c != 0 {
+		return c
+	}
+	d, od := k.digit(n), o.digit(n)
+	switch {
+	case d.Rank.Less(od.Rank):
+		return -1
+	case od.Rank.Less(d.Rank):
+		return 1
+	}
+	return d.Index - od.Index
+}
+
+// less compares the keys digit by digit
+func (k textKey) less(o textKey) bool {
+	for kk := 0; kk < len(k.pos) && kk < len(o.pos); kk++ {
+		if c := k.compare(o, kk); c != 0 {
+			return c < 0
+		}
+	}
+	return len(k.pos) < len(o.pos)
+}
+
+type textRun struct {
+	Pos  textPos
+	Text string
+}
+
+type textSpan struct {
+	Run          *Rank
+	Start, Count int
+}
+
+// textPos is a list of digits which are compared in order. Inserts
+// between two adjacent characters with equal ords (such as the
+// characters of a single run) keep the digits of the left character
+// and add a new digit.
+type textPos []textDigit
+
+type textDigit struct {
+	Ord   string
+	Rank  *Rank
+	Index int
+}
+
+func (p textPos) ords() []*ordkey {
+	result := make([]*ordkey, len(p))
+	for kk, d := range p {
+		result[kk] = fromString(d.Ord)
+	}
+	return result
+}
+
+type updRunsText struct {
+	changes.Change
+}
+
+func (u updRunsText) Revert() crdtChange {
+	return updRunsText{u.Change.Revert()}
+}
+
+func (u updRunsText) ApplyTo(ctx changes.Context, v changes.Value) changes.Value {
+	result := v.(Text)
+	result.Runs = result.Runs.Apply(ctx, u.Change).(Dict)
+	return result
+}
+
+type updMovesText struct {
+	changes.Change
+}
+
+func (u updMovesText) Revert() crdtChange {
+	return updMovesText{u.Change.Revert()}
+}
+
+func (u updMovesText) ApplyTo(ctx changes.Context, v changes.Value) changes.Value {
+	result := v.(Text)
+	result.Moves = result.Moves.Apply(ctx, u.Change).(Dict)
+	return result
+}
+
+type updDeletesText struct {
+	changes.Change
+}
+
+func (u updDeletesText) Revert() crdtChange {
+	return updDeletesText{u.Change.Revert()}
+}
+
+func (u updDeletesText) ApplyTo(ctx changes.Context, v changes.Value) changes.Value {
+	result := v.(Text)
+	result.Deletes = result.Deletes.Apply(ctx, u.Change).(Dict)
+	return result
+}
@@ -0,0 +1,176 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package crdt_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/crdt"
+	"github.com/dotchain/dot/test/fuzztest"
+)
+
+func TestTextSplice(t *testing.T) {
+	s := crdt.Text{}
+	_, s = s.Splice(0, 0, "hello world")
+	if x := s.String(); x != "hello world" {
+		t.Fatal("Splice failed", x)
+	}
+
+	c1, s1 := s.Splice(0, 5, "Hello")
+	if x := s1.String(); x != "Hello world" {
+		t.Fatal("Splice failed", x)
+	}
+	if x := s1.Apply(nil, c1.Revert()).(crdt.Text).String(); x != "hello world" {
+		t.Fatal("Undo Splice failed", x)
+	}
+
+	_, s2 := s.Splice(5, 1, ", new ")
+	if x := s2.String(); x != "hello, new world" {
+		t.Fatal("Splice failed", x)
+	}
+
+	_, s2 = s2.Splice(4, 3, "")
+	if x := s2.String(); x != "hellnew world" {
+		t.Fatal("Splice failed", x)
+	}
+
+	if n := len(s2.Runs.Entries) + len(s2.Deletes.Entries); n != 5 {
+		t.Error("Unexpected number of entries", n)
+	}
+}
+
+func TestTextCachedOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	s := crdt.Text{}
+	for kk := 0; kk < 200; kk++ {
+		n := s.Count()
+		offset := r.Intn(n + 1)
+		if n > 0 && r.Intn(3) == 0 {
+			_, s = s.Splice(offset, r.Intn(n-offset+1), "")
+		} else {
+			_, s = s.Splice(offset, 0, strings.Repeat(string(rune('a'+kk%26)), r.Intn(3)+1))
+		}
+
+		// clone the dicts so that the order is not cached
+		fresh := crdt.Text{Runs: s.Runs.Clone(), Moves: s.Moves.Clone(), Deletes: s.Deletes.Clone()}
+		if x, y := s.String(), fresh.String(); x != y || s.Count() != fresh.Count() {
+			t.Fatal("Cached order differs", x, y)
+		}
+	}
+}
+
+func TestTextUTF16(t *testing.T) {
+	s := crdt.Text{}
+	_, s = s.Splice(0, 0, "a😀b")
+	if n := s.Count(); n != 4 {
+		t.Fatal("Unexpected count", n)
+	}
+
+	_, s = s.Splice(3, 1, "c")
+	if x := s.String(); x != "a😀c" {
+		t.Fatal("Unexpected splice", x)
+	}
+
+	_, s = s.Move(1, 2, 1)
+	if x := s.String(); x != "ac😀" {
+		t.Fatal("Unexpected move", x)
+	}
+}
+
+func TestTextMove(t *testing.T) {
+	s := crdt.Text{}
+	_, s = s.Splice(0, 0, "abcdef")
+
+	c1, s1 := s.Move(1, 2, 2)
+	if x := s1.String(); x != "adebcf" {
+		t.Fatal("Move failed", x)
+	}
+	if x := s1.Apply(nil, c1.Revert()).(crdt.Text).String(); x != "abcdef" {
+		t.Fatal("Undo Move failed", x)
+	}
+
+	_, s2 := s1.Move(3, 2, -3)
+	if x := s2.String(); x != "bcadef" {
+		t.Fatal("Move failed", x)
+	}
+
+	_, s2 = s2.Move(1, 3, 1)
+	if x := s2.String(); x != "becadf" {
+		t.Fatal("Move failed", x)
+	}
+}
+
+func TestTextConcurrent(t *testing.T) {
+	s := crdt.Text{}
+	_, s = s.Splice(0, 0, "hello world")
+
+	c1, _ := s.Splice(5, 0, ",")
+	c2, _ := s.Splice(6, 5, "there")
+	c3, _ := s.Move(0, 5, 6)
+
+	v := s.Apply(nil, c2).Apply(nil, c1).(crdt.Text)
+	if x := v.String(); x != "hello, there" {
+		t.Error("Unexpected merge", x)
+	}
+
+	v1 := v.Apply(nil, c3).(crdt.Text)
+	v2 := s.Apply(nil, c3).Apply(nil, c2).Apply(nil, c1).(crdt.Text)
+	if v1.String() != v2.String() {
+		t.Fatal("Diverged", v1.String(), v2.String())
+	}
+}
+
+func TestTextConcurrentContiguous(t *testing.T) {
+	_, s := crdt.Text{}.Splice(0, 0, "[]")
+	c1, _ := s.Splice(1, 0, "abc")
+	c2, _ := s.Splice(1, 0, "wxyz")
+
+	s1 := s.Apply(nil, c1).Apply(nil, c2).(crdt.Text)
+	s2 := s.Apply(nil, c2).Apply(nil, c1).(crdt.Text)
+	if x := s1.String(); x != s2.String() || x != "[abcwxyz]" && x != "[wxyzabc]" {
+		t.Fatal("Concurrent inserts interleaved", x, s2.String())
+	}
+
+	// inserts between and within the concurrent runs
+	str := s1.String()
+	idx := strings.Index(str, "c") + 1
+	c3, _ := s1.Splice(idx, 0, "-")
+	c4, _ := s1.Splice(2, 0, "+")
+	x1 := s1.Apply(nil, c3).Apply(nil, c4).(crdt.Text).String()
+	x2 := s1.Apply(nil, c4).Apply(nil, c3).(crdt.Text).String()
+	expected := str[:2] + "+" + str[2:idx] + "-" + str[idx:]
+	if x1 != x2 || x1 != expected {
+		t.Fatal("Unexpected inserts", x1, x2, expected)
+	}
+}
+
+func TestTextConvergence(t *testing.T) {
+	model := fuzztest.Model{
+		Value: func(r *rand.Rand) changes.Value {
+			_, s := crdt.Text{}.Splice(0, 0, "hello")
+			return s
+		},
+		Change: func(r *rand.Rand, v changes.Value) changes.Change {
+			s := v.(crdt.Text)
+			n := s.Count()
+			offset := r.Intn(n + 1)
+			count := r.Intn(n - offset + 1)
+			var c changes.Change
+			if r.Intn(2) == 0 || count == 0 || offset+count == n {
+				c, _ = s.Splice(offset, count, []string{"", "x", "yz"}[r.Intn(3)])
+			} else {
+				c, _ = s.Move(offset, count, r.Intn(n-offset-count)+1)
+			}
+			return c
+		},
+		Equal: func(v1, v2 changes.Value) bool {
+			return v1.(crdt.Text).String() == v2.(crdt.Text).String()
+		},
+	}
+	model.Check(t, 500)
+}
@@ -4,7 +4,11 @@
 
 package crdt
 
-import "github.com/dotchain/dot/changes"
+import (
+	"reflect"
+
+	"github.com/dotchain/dot/changes"
+)
 
 type crdtChange interface {
 	ApplyTo(ctx changes.Context, v changes.Value) changes.Value
@@ -13,14 +17,248 @@ type crdtChange interface {
 
 type wrapper []crdtChange
 
+// Commutes returns true if c only holds crdt changes.  Such changes
+// commute with all concurrent crdt changes and so can be applied in
+// any order.
+//
+// Changes to nested values that are not crdt values (such as a
+// changes.Splice passed to Dict.Update) do not commute: they must be
+// merged with concurrent changes (see Merge) before being applied.
+func Commutes(c changes.Change) bool {
+	switch c := c.(type) {
+	case nil:
+		return true
+	case changes.ChangeSet:
+		for _, cx := range c {
+			if !Commutes(cx) {
+				return false
+			}
+		}
+		return true
+	case wrapper:
+		for _, cx := range c {
+			var inner changes.Change
+			switch x := cx.(type) {
+			case updContainer:
+				inner = x.Change
+			case updateDict:
+				inner = x.Change
+			case updValueSeq:
+				inner = x.Change
+			case updOrdSeq:
+				inner = x.Change
+			case updRunsText:
+				inner = x.Change
+			case updMovesText:
+				inner = x.Change
+			case updDeletesText:
+				inner = x.Change
+			}
+			if !Commutes(inner) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Merge is mostly a no-op as crdt changes commute.  The exceptions
+// are:
+//
+// 1. Changes to nested values that are not crdt values (such as a
+// changes.Splice of a string held in a Dict) are merged with
+// concurrent changes to the same value.
+//
+// 2. Changes via PathChange are dropped if the nested value is
+// concurrently replaced.
+//
+// 3. Shifts are merged with concurrent shifts (see shift).
 func (w wrapper) Merge(o changes.Change) (otherx, cx changes.Change) {
-	return o, w
+	return w.merge(o, false)
 }
 
+// ReverseMerge is like Merge but with the receiver and the argument
+// interchanged when merging nested values.
 func (w wrapper) ReverseMerge(o changes.Change) (otherx, cx changes.Change) {
+	return w.merge(o, true)
+}
+
+func (w wrapper) merge(o changes.Change, reverse bool) (otherx, cx changes.Change) {
+	switch o := o.(type) {
+	case wrapper:
+		return w.mergeWrapper(o, reverse)
+	case changes.ChangeSet, changes.Meta:
+		cx, otherx = o.Merge(w)
+		return otherx, cx
+	case changes.PathChange:
+		if len(o.Path) == 0 {
+			return w.merge(o.Change, reverse)
+		}
+		// paths are mostly stable, except when the value
+		// being referred to is replaced
+		if w.replaces(o.Path) {
+			return nil, w
+		}
+		p, c, wx := w.mergeAt(o.Path, nil, o.Change, reverse)
+		if c == nil {
+			return nil, wx
+		}
+		return changes.PathChange{Path: p, Change: c}, wx
+	}
 	return o, w
 }
 
+// mergeWrapper merges the shifts and then each nested change of o.
+// Ranks are unique, so an identical change is the same change
+// delivered twice and is dropped.
+func (w wrapper) mergeWrapper(o wrapper, reverse bool) (otherx, cx changes.Change) {
+	if reflect.DeepEqual(w, o) {
+		return nil, nil
+	}
+
+	if ws, os := w.shifts(), o.shifts(); ws != nil && os != nil {
+		var wsx, osx changes.Change
+		if reverse {
+			wsx, osx = os.Merge(ws)
+		} else {
+			osx, wsx = ws.Merge(os)
+		}
+		w, o = w.withShifts(wsx), o.withShifts(osx)
+	}
+
+	cx = w
+	otherx = o.mapNested(nil, func(p []interface{}, r *Rank, c changes.Change) changes.Change {
+		_, c, cx = cx.(wrapper).mergeAt(p, r, c, reverse)
+		return c
+	})
+	return otherx, cx
+}
+
+// mapNested calls fn with the path, rank and the change for every
+// nested value updated by w. The nested changes are replaced with
+// the result of fn.
+func (w wrapper) mapNested(p []interface{}, fn func([]interface{}, *Rank, changes.Change) changes.Change) wrapper {
+	result := wrapper{}
+	for _, cx := range w {
+		switch x := cx.(type) {
+		case updValueSeq:
+			if iw, ok := x.Change.(wrapper); ok {
+				cx = updValueSeq{iw.mapNested(p, fn)}
+			}
+		case updateDict:
+			if iw, ok := x.Change.(wrapper); ok {
+				path := append(append([]interface{}(nil), p...), x.Key)
+				cx = updateDict{x.Key, iw.mapNested(path, fn)}
+			}
+		case updContainer:
+			if x.Change = fn(p, x.Rank, x.Change); x.Change == nil {
+				continue
+			}
+			cx = x
+		}
+		result = append(result, cx)
+	}
+	return result
+}
+
+// mergeAt merges w with a concurrent change c of the value at path
+// p. If w updates a Container and p is empty, only the entry with the
+// provided rank is considered (all entries if rank is nil).  It
+// returns the path and the change to apply after w along with the
+// version of w to apply after c.
+func (w wrapper) mergeAt(p []interface{}, rank *Rank, c changes.Change, reverse bool) ([]interface{}, changes.Change, changes.Change) {
+	if len(p) == 0 && !w.updatesContainer() {
+		cx, wx := w.merge(c, reverse)
+		return nil, cx, wx
+	}
+
+	result := wrapper{}
+	for _, cx := range w {
+		switch x := cx.(type) {
+		case updValueSeq:
+			if iw, ok := x.Change.(wrapper); ok && c != nil {
+				p, c, x.Change = iw.mergeAt(p, rank, c, reverse)
+				cx = x
+			}
+		case updateDict:
+			if iw, ok := x.Change.(wrapper); ok && c != nil && len(p) > 0 && p[0] == x.Key {
+				var sub []interface{}
+				sub, c, x.Change = iw.mergeAt(p[1:], rank, c, reverse)
+				p, cx = append([]interface{}{x.Key}, sub...), x
+			}
+		case updContainer:
+			if c == nil || len(p) == 0 && rank != nil && rank != x.Rank {
+				break
+			}
+			if p, c, x.Change = mergeNested(x.Change, p, rank, c, reverse); x.Change == nil {
+				continue
+			}
+			cx = x
+		}
+		result = append(result, cx)
+	}
+	return p, c, result
+}
+
+// mergeNested merges the change to the value of a Container entry
+// with a concurrent change c at path p within that value
+func mergeNested(inner changes.Change, p []interface{}, rank *Rank, c changes.Change, reverse bool) ([]interface{}, changes.Change, changes.Change) {
+	switch inner := inner.(type) {
+	case nil:
+		return p, c, nil
+	case wrapper:
+		return inner.mergeAt(p, rank, c, reverse)
+	}
+
+	if len(p) > 0 {
+		c = changes.PathChange{Path: p, Change: c}
+	}
+	var cx, innerx changes.Change
+	if reverse {
+		innerx, cx = c.Merge(inner)
+	} else {
+		cx, innerx = inner.Merge(c)
+	}
+	return nil, changes.Simplify(cx), changes.Simplify(innerx)
+}
+
+// replaces returns true if w sets or unsets the value at path p or
+// any of its ancestors
+func (w wrapper) replaces(p []interface{}) bool {
+	for _, cx := range w {
+		var inner changes.Change
+		switch x := cx.(type) {
+		case setContainer, unsetContainer:
+			return true
+		case updContainer:
+			inner = x.Change
+		case updValueSeq:
+			inner = x.Change
+		case updateDict:
+			if len(p) == 0 || p[0] != x.Key {
+				continue
+			}
+			inner, p = x.Change, p[1:]
+		}
+		if iw, ok := inner.(wrapper); ok && iw.replaces(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// updatesContainer returns true if w is a change to a Container
+func (w wrapper) updatesContainer() bool {
+	for _, cx := range w {
+		switch cx.(type) {
+		case setContainer, unsetContainer, delContainer, updContainer, trimContainer, restoreContainer:
+			return true
+		}
+	}
+	return false
+}
+
 func (w wrapper) Revert() changes.Change {
 	result := make(wrapper, len(w))
 	for kk, cx := range w {
@@ -0,0 +1,143 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package crdt_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/crdt"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/refs"
+	"github.com/dotchain/dot/test/fuzztest"
+)
+
+func TestWrapperMergeNested(t *testing.T) {
+	_, d := crdt.Dict{}.Set("k", types.S8("abc"))
+	insert := changes.Splice{Offset: 0, Before: types.S8(""), After: types.S8("x")}
+	remove := changes.Splice{Offset: 1, Before: types.S8("b"), After: types.S8("")}
+
+	c1, _ := d.Update("k", insert)
+	c2 := changes.PathChange{Path: []interface{}{"k"}, Change: remove}
+
+	get := func(c1, c2 changes.Change) interface{} {
+		_, v := d.Apply(nil, c1).Apply(nil, c2).(crdt.Dict).Get("k")
+		return v
+	}
+
+	c2x, c1x := c1.Merge(c2)
+	if v1, v2 := get(c1, c2x), get(c2, c1x); v1 != types.S8("xac") || v2 != v1 {
+		t.Error("Merge diverged", v1, v2)
+	}
+
+	c1x, c2x = c2.Merge(c1)
+	if v1, v2 := get(c1, c2x), get(c2, c1x); v1 != types.S8("xac") || v2 != v1 {
+		t.Error("Merge diverged", v1, v2)
+	}
+
+	c2x, c1x = c1.(changes.Custom).ReverseMerge(c2)
+	if v1, v2 := get(c1, c2x), get(c2, c1x); v1 != types.S8("xac") || v2 != v1 {
+		t.Error("ReverseMerge diverged", v1, v2)
+	}
+}
+
+func TestCommutes(t *testing.T) {
+	_, d := crdt.Dict{}.Set("k", types.S8("abc"))
+	insert := changes.Splice{Offset: 0, Before: types.S8(""), After: types.S8("x")}
+	splice, _ := d.Update("k", insert)
+	set, _ := d.Set("k", crdt.Seq{})
+	_, inner := crdt.Seq{}.Splice(0, 0, []interface{}{"a"})
+	seqSplice, _ := inner.Splice(0, 1, nil)
+	nested, _ := crdt.Dict{}.Update("k", seqSplice)
+
+	if !crdt.Commutes(nil) || !crdt.Commutes(set) || !crdt.Commutes(nested) {
+		t.Error("Unexpected non-commuting change")
+	}
+	if !crdt.Commutes(changes.ChangeSet{set, nested}) {
+		t.Error("Unexpected non-commuting change set")
+	}
+
+	if crdt.Commutes(splice) || crdt.Commutes(insert) || crdt.Commutes(changes.ChangeSet{set, splice}) {
+		t.Error("Unexpected commuting change")
+	}
+}
+
+func TestWrapperMergeFuzz(t *testing.T) {
+	keys := []string{"a", "b"}
+	randS8 := func(r *rand.Rand) types.S8 {
+		return types.S8("xyz"[:r.Intn(4)])
+	}
+
+	model := fuzztest.Model{
+		Value: func(r *rand.Rand) changes.Value {
+			d := crdt.Dict{}
+			for _, key := range keys {
+				_, d = d.Set(key, types.S8("abc"))
+			}
+			return d
+		},
+		Change: func(r *rand.Rand, v changes.Value) changes.Change {
+			d := v.(crdt.Dict)
+			key := keys[r.Intn(len(keys))]
+			_, val := d.Get(key)
+			if val == nil {
+				return nil
+			}
+
+			s := val.(types.S8)
+			offset := r.Intn(len(s) + 1)
+			count := r.Intn(len(s) - offset + 1)
+			inner := changes.Splice{Offset: offset, Before: s.Slice(offset, count), After: randS8(r)}
+			switch r.Intn(5) {
+			case 0:
+				c, _ := d.Delete(key)
+				return c
+			case 1, 2:
+				c, _ := d.Update(key, inner)
+				return c
+			}
+			return changes.PathChange{Path: []interface{}{key}, Change: inner}
+		},
+		Equal: func(v1, v2 changes.Value) bool {
+			for _, key := range keys {
+				_, x1 := v1.(crdt.Dict).Get(key)
+				_, x2 := v2.(crdt.Dict).Get(key)
+				if x1 != x2 {
+					return false
+				}
+			}
+			return true
+		},
+	}
+	model.Check(t, 500)
+}
+
+func TestWrapperMergeCaret(t *testing.T) {
+	_, s := crdt.Text{}.Splice(0, 0, "hello")
+	caret := refs.Caret{Index: 3}
+
+	insert, _ := s.Splice(1, 0, "ab")
+	if x, _ := caret.Merge(insert); !x.Equal(refs.Caret{Index: 5}) {
+		t.Error("Unexpected merge", x)
+	}
+
+	remove, _ := s.Splice(0, 2, "")
+	if x, _ := caret.Merge(remove); !x.Equal(refs.Caret{Index: 1}) {
+		t.Error("Unexpected merge", x)
+	}
+
+	move, _ := s.Move(0, 1, 4)
+	if x, _ := caret.Merge(move); !x.Equal(refs.Caret{Index: 2}) {
+		t.Error("Unexpected merge", x)
+	}
+
+	// carets are mapped through concurrent splices
+	after, _ := s.Splice(4, 0, "!")
+	insertx, _ := after.Merge(insert)
+	if x, _ := caret.Merge(changes.ChangeSet{after, insertx}); !x.Equal(refs.Caret{Index: 5}) {
+		t.Error("Unexpected merge", x)
+	}
+}
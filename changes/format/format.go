@@ -0,0 +1,202 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+// Package format implements a compact human readable form for
+// changes and values.
+//
+// This is mainly meant for debugging and for tests: Change and Value
+// render the standard types while ParseChange and ParseValue convert
+// the same textual form back.
+//
+// Values
+//
+//	nil                        changes.Nil
+//	"hello"                    types.S8
+//	s16"hello"                 types.S16
+//	[v1, v2]                   types.A
+//	{"key": v1, 5: v2}         types.M
+//	counter(5)                 types.Counter
+//	counter64(5)               types.Counter64
+//	floatcounter(1.5)          types.FloatCounter
+//	set(k1, k2)                types.Set
+//	atomic(5)                  changes.Atomic
+//
+// Atomic values (and Meta data) must be ints, floats, strings, bools
+// or nil to be parseable.
+//
+// Changes
+//
+//	nil
+//	replace(before -> after)
+//	splice(offset: before -> after)
+//	move(offset, count, distance)
+//	path(key1, key2: change)
+//	changeset(change1, change2)
+//	meta(data: change)
+//	update(key: before -> after)       refs.Update
+//	run(offset, count: change)         run.Run
+//	setadd(k1, k2: existing1)          types.SetAdd
+//	setremove(k1, k2)                  types.SetRemove
+//
+// The refs used by refs.Update are represented as:
+//
+//	nil
+//	invalid                            refs.InvalidRef
+//	ref(key1, key2)                    refs.Path
+//	caret(ref(key1), index)            refs.Caret
+//	caret(ref(key1), index, left)      refs.Caret{IsLeft: true}
+//	range(caret(...), caret(...))      refs.Range
+//
+// Any other change or value is formatted using its String method if
+// it implements fmt.Stringer. Such values cannot be parsed back.
+package format
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/run"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/refs"
+)
+
+// Change returns the human readable form of a change
+func Change(c changes.Change) string {
+	switch c := c.(type) {
+	case nil:
+		return "nil"
+	case changes.Replace:
+		return "replace(" + Value(c.Before) + " -> " + Value(c.After) + ")"
+	case changes.Splice:
+		before, after := Value(c.Before), Value(c.After)
+		return "splice(" + strconv.Itoa(c.Offset) + ": " + before + " -> " + after + ")"
+	case changes.Move:
+		return fmt.Sprintf("move(%d, %d, %d)", c.Offset, c.Count, c.Distance)
+	case changes.PathChange:
+		return "path(" + keys(c.Path) + ": " + Change(c.Change) + ")"
+	case changes.ChangeSet:
+		result := make([]string, len(c))
+		for kk, cx := range c {
+			result[kk] = Change(cx)
+		}
+		return "changeset(" + strings.Join(result, ", ") + ")"
+	case changes.Meta:
+		return "meta(" + literal(c.Data) + ": " + Change(c.Change) + ")"
+	case refs.Update:
+		before, after := Ref(c.Before), Ref(c.After)
+		return "update(" + literal(c.Key) + ": " + before + " -> " + after + ")"
+	case run.Run:
+		return fmt.Sprintf("run(%d, %d: %s)", c.Offset, c.Count, Change(c.Change))
+	case types.SetAdd:
+		if len(c.Existing) == 0 {
+			return "setadd(" + keys(c.Keys) + ")"
+		}
+		return "setadd(" + keys(c.Keys) + ": " + keys(c.Existing) + ")"
+	case types.SetRemove:
+		return "setremove(" + keys(c.Keys) + ")"
+	case fmt.Stringer:
+		return c.String()
+	}
+	return fmt.Sprintf("%#v", c)
+}
+
+// Value returns the human readable form of a value
+func Value(v changes.Value) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case types.S8:
+		return strconv.Quote(string(v))
+	case types.S16:
+		return "s16" + strconv.Quote(string(v))
+	case types.A:
+		result := make([]string, len(v))
+		for kk, elt := range v {
+			result[kk] = Value(elt)
+		}
+		return "[" + strings.Join(result, ", ") + "]"
+	case types.M:
+		result := make([]string, 0, len(v))
+		for key, elt := range v {
+			result = append(result, literal(key)+": "+Value(elt))
+		}
+		sort.Strings(result)
+		return "{" + strings.Join(result, ", ") + "}"
+	case types.Counter:
+		return "counter(" + strconv.Itoa(int(v)) + ")"
+	case types.Counter64:
+		return "counter64(" + strconv.FormatInt(int64(v), 10) + ")"
+	case types.FloatCounter:
+		return "floatcounter(" + literal(float64(v)) + ")"
+	case types.Set:
+		result := make([]string, 0, len(v))
+		for key := range v {
+			result = append(result, literal(key))
+		}
+		sort.Strings(result)
+		return "set(" + strings.Join(result, ", ") + ")"
+	case changes.Atomic:
+		return "atomic(" + literal(v.Value) + ")"
+	case fmt.Stringer:
+		return v.String()
+	}
+	if v == changes.Nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%#v", v)
+}
+
+// Ref returns the human readable form of a refs.Ref
+func Ref(r refs.Ref) string {
+	switch r := r.(type) {
+	case nil:
+		return "nil"
+	case refs.Path:
+		return "ref(" + keys(r) + ")"
+	case refs.Caret:
+		if r.IsLeft {
+			return fmt.Sprintf("caret(%s, %d, left)", Ref(r.Path), r.Index)
+		}
+		return fmt.Sprintf("caret(%s, %d)", Ref(r.Path), r.Index)
+	case refs.Range:
+		return "range(" + Ref(r.Start) + ", " + Ref(r.End) + ")"
+	case fmt.Stringer:
+		return r.String()
+	}
+	if r == refs.InvalidRef {
+		return "invalid"
+	}
+	return fmt.Sprintf("%#v", r)
+}
+
+func keys(path []interface{}) string {
+	result := make([]string, len(path))
+	for kk, key := range path {
+		result[kk] = literal(key)
+	}
+	return strings.Join(result, ", ")
+}
+
+func literal(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(v)
+	case int, bool:
+		return fmt.Sprint(v)
+	case float64:
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eEnN") {
+			s += ".0"
+		}
+		return s
+	case changes.Value:
+		return Value(v)
+	}
+	return fmt.Sprintf("%#v", v)
+}
@@ -0,0 +1,139 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package format_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/crdt"
+	"github.com/dotchain/dot/changes/format"
+	"github.com/dotchain/dot/changes/run"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/refs"
+)
+
+var caret = refs.Caret{Path: refs.Path{"Value", 2}, Index: 5}
+
+var formatTests = map[string]changes.Change{
+	"nil":                                                    nil,
+	`replace(nil -> "hello")`:                                changes.Replace{Before: changes.Nil, After: types.S8("hello")},
+	`replace(s16"a\n" -> nil)`:                               changes.Replace{Before: types.S16("a\n"), After: changes.Nil},
+	`splice(5: "ab" -> "")`:                                  changes.Splice{Offset: 5, Before: types.S8("ab"), After: types.S8("")},
+	"splice(0: [] -> [nil, [atomic(1)]])":                    changes.Splice{Before: types.A{}, After: types.A{changes.Nil, types.A{changes.Atomic{Value: 1}}}},
+	"move(1, 2, -3)":                                         changes.Move{Offset: 1, Count: 2, Distance: -3},
+	`path("x", 2: move(1, 2, 3))`:                            changes.PathChange{Path: []interface{}{"x", 2}, Change: changes.Move{Offset: 1, Count: 2, Distance: 3}},
+	"changeset(nil, move(0, 1, 1))":                          changes.ChangeSet{nil, changes.Move{Offset: 0, Count: 1, Distance: 1}},
+	`meta("user": nil)`:                                      changes.Meta{Data: "user"},
+	"run(2, 3: move(0, 1, 1))":                               run.Run{Offset: 2, Count: 3, Change: changes.Move{Offset: 0, Count: 1, Distance: 1}},
+	"splice(0: counter(0) -> counter(-5))":                   changes.Splice{Before: types.Counter(0), After: types.Counter(-5)},
+	`replace(set("a", 1) -> set())`:                          changes.Replace{Before: types.Set{"a": true, 1: true}, After: types.Set{}},
+	`setadd("a", 1)`:                                         types.SetAdd{Keys: []interface{}{"a", 1}},
+	`setadd("a", 1: 1)`:                                      types.SetAdd{Keys: []interface{}{"a", 1}, Existing: []interface{}{1}},
+	"replace(counter64(1099511627776) -> floatcounter(2.0))": changes.Replace{Before: types.Counter64(1 << 40), After: types.FloatCounter(2)},
+	`setremove(2)`:                                           types.SetRemove{Keys: []interface{}{2}},
+	`replace({"a": atomic(true), 5: atomic(1.5)} -> atomic(nil))`: changes.Replace{
+		Before: types.M{"a": changes.Atomic{Value: true}, 5: changes.Atomic{Value: 1.5}},
+		After:  changes.Atomic{},
+	},
+	`update("k": nil -> ref("Value", 1))`: refs.Update{Key: "k", After: refs.Path{"Value", 1}},
+	`update(1: caret(ref("Value", 2), 5) -> invalid)`: refs.Update{
+		Key:    1,
+		Before: caret,
+		After:  refs.InvalidRef,
+	},
+	`update(1: nil -> range(caret(ref("Value", 2), 5), caret(ref("Value", 2), 5, left)))`: refs.Update{
+		Key:   1,
+		After: refs.Range{Start: caret, End: refs.Caret{Path: caret.Path, Index: 5, IsLeft: true}},
+	},
+}
+
+func TestFormatAndParse(t *testing.T) {
+	for expected, c := range formatTests {
+		if s := format.Change(c); s != expected {
+			t.Error("Unexpected format", s, expected)
+		}
+
+		if parsed := format.MustParse(expected); !reflect.DeepEqual(parsed, c) {
+			t.Errorf("Unexpected parse %s %#v", expected, parsed)
+		}
+	}
+}
+
+func TestFormatCustom(t *testing.T) {
+	type custom struct{ changes.Change }
+	if s := format.Change(custom{}); s != "format_test.custom{Change:changes.Change(nil)}" {
+		t.Error("Unexpected custom format", s)
+	}
+
+	type value struct{ changes.Value }
+	if s := format.Value(value{}); s != "format_test.value{Value:changes.Value(nil)}" {
+		t.Error("Unexpected custom value format", s)
+	}
+
+	type ref struct{ refs.Ref }
+	if s := format.Ref(ref{}); s != "format_test.ref{Ref:refs.Ref(nil)}" {
+		t.Error("Unexpected custom ref format", s)
+	}
+
+	if s := format.Change(changes.Meta{Data: []int{1}}); s != "meta([]int{1}: nil)" {
+		t.Error("Unexpected meta format", s)
+	}
+
+	if s := format.Value(nil); s != "nil" {
+		t.Error("Unexpected nil value format", s)
+	}
+
+	if s := format.Ref(nil); s != "nil" {
+		t.Error("Unexpected nil ref format", s)
+	}
+
+	if s := format.Value(changes.Atomic{Value: 1e6}); s != "atomic(1e+06)" {
+		t.Error("Unexpected float format", s)
+	}
+
+	if s := format.Value(changes.Atomic{Value: types.S16("x")}); s != `atomic(s16"x")` {
+		t.Error("Unexpected nested value format", s)
+	}
+}
+
+func TestFormatCRDT(t *testing.T) {
+	c, d := crdt.Dict{}.Set("hello", types.S8("world"))
+	rank, _ := d.Get("hello")
+	if s := (&crdt.Rank{Epoch: 36, Nonce: [4]int64{1, 2, 3, -4}}).String(); s != "10.1.2.3.-4" {
+		t.Error("Unexpected rank format", s)
+	}
+
+	expected := `crdt(dict("hello": crdt(crdtset(` + rank.String() + `: "world"))))`
+	if s := format.Change(c); s != expected {
+		t.Error("Unexpected crdt format", s)
+	}
+
+	expected = `crdt(dict("hello": crdt(crdtunset(` + rank.String() + `: 1))))`
+	if s := format.Change(c.Revert()); s != expected {
+		t.Error("Unexpected crdt format", s)
+	}
+
+	c, _ = d.Delete("hello")
+	if s := format.Change(c); s != `crdt(dict("hello": crdt(crdtdelete(1))))` {
+		t.Error("Unexpected crdt format", s)
+	}
+
+	c, _ = d.Update("hello", changes.Splice{Before: types.S8(""), After: types.S8("a")})
+	expected = `crdt(dict("hello": crdt(crdtupdate(` + rank.String() + `: splice(0: "" -> "a")))))`
+	if s := format.Change(c); s != expected {
+		t.Error("Unexpected crdt format", s)
+	}
+
+	c, _ = crdt.Seq{}.Splice(0, 0, []interface{}{5})
+	if s := format.Change(c); len(s) < 17 || s[:17] != "crdt(ords(crdt(di" {
+		t.Error("Unexpected crdt format", s)
+	}
+
+	if s := (*crdt.Rank)(nil).String(); s != "nil" {
+		t.Error("Unexpected nil rank format", s)
+	}
+}
@@ -0,0 +1,397 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package format
+
+import (
+	"strconv"
+	"strings"
+	"text/scanner"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/run"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/refs"
+)
+
+// ParseError captures the location and reason for a parse failure
+type ParseError struct {
+	Offset  int
+	Message string
+}
+
+// Error implements the Error interface
+func (p ParseError) Error() string {
+	return strconv.Itoa(p.Offset) + ": " + p.Message
+}
+
+// ParseChange converts the output of Change back into a change.
+func ParseChange(s string) (c changes.Change, err error) {
+	err = parse(s, func(p *parser) { c = p.change() })
+	return c, err
+}
+
+// ParseValue converts the output of Value back into a value
+func ParseValue(s string) (v changes.Value, err error) {
+	err = parse(s, func(p *parser) { v = p.value() })
+	return v, err
+}
+
+// MustParse is like ParseChange but panics on errors. This is
+// convenient for tests.
+func MustParse(s string) changes.Change {
+	c, err := ParseChange(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func parse(s string, fn func(p *parser)) (err error) {
+	p := &parser{}
+	p.Init(strings.NewReader(s))
+	p.Mode = scanner.ScanIdents | scanner.ScanInts | scanner.ScanFloats | scanner.ScanStrings
+	p.Error = func(_ *scanner.Scanner, msg string) {
+		p.fail(msg)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(ParseError)
+			if !ok {
+				panic(r)
+			}
+			err = perr
+		}
+	}()
+
+	p.next()
+	fn(p)
+	if p.tok != scanner.EOF {
+		p.fail("unexpected " + p.text)
+	}
+	return nil
+}
+
+type parser struct {
+	scanner.Scanner
+	tok    rune
+	text   string
+	offset int
+}
+
+func (p *parser) next() {
+	p.tok = p.Scan()
+	p.text, p.offset = p.TokenText(), p.Position.Offset
+}
+
+func (p *parser) fail(msg string) {
+	panic(ParseError{p.offset, msg})
+}
+
+func (p *parser) expect(s string) {
+	if p.text != s || p.tok == scanner.String {
+		p.fail("expected " + s)
+	}
+	p.next()
+}
+
+func (p *parser) accept(s string) bool {
+	if p.text != s || p.tok == scanner.String {
+		return false
+	}
+	p.next()
+	return true
+}
+
+func (p *parser) ident() string {
+	if p.tok != scanner.Ident {
+		p.fail("expected name")
+	}
+	name := p.text
+	p.next()
+	return name
+}
+
+func (p *parser) arrow() {
+	p.expect("-")
+	p.expect(">")
+}
+
+func (p *parser) integer() int {
+	n, ok := p.number().(int)
+	if !ok {
+		p.fail("expected integer")
+	}
+	return n
+}
+
+func (p *parser) number() interface{} {
+	sign := ""
+	if p.accept("-") {
+		sign = "-"
+	}
+
+	var result interface{}
+	var err error
+	switch p.tok {
+	case scanner.Int:
+		result, err = strconv.Atoi(sign + p.text)
+	case scanner.Float:
+		result, err = strconv.ParseFloat(sign+p.text, 64)
+	default:
+		p.fail("expected number")
+	}
+	if err != nil {
+		p.fail(err.Error())
+	}
+	p.next()
+	return result
+}
+
+func (p *parser) change() changes.Change {
+	name := p.ident()
+	if name == "nil" {
+		return nil
+	}
+
+	p.expect("(")
+	result := p.changeArgs(name)
+	p.expect(")")
+	return result
+}
+
+func (p *parser) changeArgs(name string) changes.Change {
+	switch name {
+	case "replace":
+		before := p.value()
+		p.arrow()
+		return changes.Replace{Before: before, After: p.value()}
+	case "splice":
+		offset := p.integer()
+		p.expect(":")
+		before := p.collection()
+		p.arrow()
+		return changes.Splice{Offset: offset, Before: before, After: p.collection()}
+	case "move":
+		offset := p.integer()
+		p.expect(",")
+		count := p.integer()
+		p.expect(",")
+		return changes.Move{Offset: offset, Count: count, Distance: p.integer()}
+	case "path":
+		path := p.keys(":")
+		p.expect(":")
+		return changes.PathChange{Path: path, Change: p.change()}
+	case "changeset":
+		result := changes.ChangeSet{}
+		for p.text != ")" {
+			if len(result) > 0 {
+				p.expect(",")
+			}
+			result = append(result, p.change())
+		}
+		return result
+	case "meta":
+		data := p.literal()
+		p.expect(":")
+		return changes.Meta{Data: data, Change: p.change()}
+	case "update":
+		key := p.literal()
+		p.expect(":")
+		before := p.ref()
+		p.arrow()
+		return refs.Update{Key: key, Before: before, After: p.ref()}
+	case "run":
+		offset := p.integer()
+		p.expect(",")
+		count := p.integer()
+		p.expect(":")
+		return run.Run{Offset: offset, Count: count, Change: p.change()}
+	case "setadd":
+		add := types.SetAdd{Keys: p.keys(":", ")")}
+		if p.accept(":") {
+			add.Existing = p.keys(")")
+		}
+		return add
+	case "setremove":
+		return types.SetRemove{Keys: p.keys(")")}
+	}
+	p.fail("unknown change " + name)
+	return nil
+}
+
+func (p *parser) collection() changes.Collection {
+	v := p.value()
+	if c, ok := v.(changes.Collection); ok {
+		return c
+	}
+	p.fail("expected collection")
+	return nil
+}
+
+func (p *parser) value() changes.Value {
+	switch {
+	case p.tok == scanner.String:
+		return types.S8(p.str())
+	case p.accept("["):
+		result := types.A{}
+		for !p.accept("]") {
+			if len(result) > 0 {
+				p.expect(",")
+			}
+			result = append(result, p.value())
+		}
+		return result
+	case p.accept("{"):
+		result := types.M{}
+		for !p.accept("}") {
+			if len(result) > 0 {
+				p.expect(",")
+			}
+			key := p.literal()
+			p.expect(":")
+			result[key] = p.value()
+		}
+		return result
+	}
+
+	switch name := p.ident(); name {
+	case "nil":
+		return changes.Nil
+	case "s16":
+		return types.S16(p.str())
+	case "counter":
+		p.expect("(")
+		result := types.Counter(p.integer())
+		p.expect(")")
+		return result
+	case "counter64":
+		p.expect("(")
+		result := types.Counter64(p.integer())
+		p.expect(")")
+		return result
+	case "floatcounter":
+		p.expect("(")
+		var result types.FloatCounter
+		switch n := p.number().(type) {
+		case int:
+			result = types.FloatCounter(n)
+		case float64:
+			result = types.FloatCounter(n)
+		}
+		p.expect(")")
+		return result
+	case "atomic":
+		p.expect("(")
+		result := changes.Atomic{Value: p.literal()}
+		p.expect(")")
+		return result
+	case "set":
+		p.expect("(")
+		result := types.Set{}
+		for _, key := range p.keys(")") {
+			result[key] = true
+		}
+		p.expect(")")
+		return result
+	}
+	p.fail("unknown value")
+	return nil
+}
+
+func (p *parser) str() string {
+	if p.tok != scanner.String {
+		p.fail("expected string")
+	}
+	s, err := strconv.Unquote(p.text)
+	if err != nil {
+		p.fail(err.Error())
+	}
+	p.next()
+	return s
+}
+
+func (p *parser) literal() interface{} {
+	switch {
+	case p.tok == scanner.String:
+		return p.str()
+	case p.tok == scanner.Int || p.tok == scanner.Float || p.text == "-":
+		return p.number()
+	case p.accept("true"):
+		return true
+	case p.accept("false"):
+		return false
+	case p.accept("nil"):
+		return nil
+	}
+	return p.value()
+}
+
+func (p *parser) keys(ends ...string) []interface{} {
+	var result []interface{}
+	for !p.at(ends) {
+		if len(result) > 0 {
+			p.expect(",")
+		}
+		result = append(result, p.literal())
+	}
+	return result
+}
+
+func (p *parser) at(texts []string) bool {
+	for _, text := range texts {
+		if p.text == text {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) ref() refs.Ref {
+	name := p.ident()
+	switch name {
+	case "nil":
+		return nil
+	case "invalid":
+		return refs.InvalidRef
+	}
+
+	p.expect("(")
+	result := p.refArgs(name)
+	p.expect(")")
+	return result
+}
+
+func (p *parser) refArgs(name string) refs.Ref {
+	switch name {
+	case "ref":
+		return refs.Path(p.keys(")"))
+	case "caret":
+		path, ok := p.ref().(refs.Path)
+		if !ok {
+			p.fail("expected ref")
+		}
+		p.expect(",")
+		index := p.integer()
+		isLeft := false
+		if p.accept(",") {
+			if p.ident() != "left" {
+				p.fail("expected left")
+			}
+			isLeft = true
+		}
+		return refs.Caret{Path: path, Index: index, IsLeft: isLeft}
+	case "range":
+		start, ok1 := p.ref().(refs.Caret)
+		p.expect(",")
+		end, ok2 := p.ref().(refs.Caret)
+		if !ok1 || !ok2 {
+			p.fail("expected caret")
+		}
+		return refs.Range{Start: start, End: end}
+	}
+	p.fail("unknown ref " + name)
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package format_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/format"
+	"github.com/dotchain/dot/changes/types"
+)
+
+func TestParseValue(t *testing.T) {
+	v, err := format.ParseValue(`[ "a", s16"b", {"x": counter(2)}, atomic(-2.5), atomic("s"), nil ]`)
+	expected := types.A{
+		types.S8("a"),
+		types.S16("b"),
+		types.M{"x": types.Counter(2)},
+		changes.Atomic{Value: -2.5},
+		changes.Atomic{Value: "s"},
+		changes.Nil,
+	}
+	if err != nil || !reflect.DeepEqual(v, expected) {
+		t.Error("Unexpected parse", v, err)
+	}
+
+	v, err = format.ParseValue(`floatcounter(-3)`)
+	if err != nil || v != types.FloatCounter(-3) {
+		t.Error("Unexpected parse", v, err)
+	}
+
+	v, err = format.ParseValue(`{false: atomic(true), -1: "x"}`)
+	expected2 := types.M{false: changes.Atomic{Value: true}, -1: types.S8("x")}
+	if err != nil || !reflect.DeepEqual(v.(types.M)[false], expected2[false]) || v.(types.M)[-1] != expected2[-1] {
+		t.Error("Unexpected parse", v, err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	errors := []string{
+		"",
+		"boo",
+		"replace(nil)",
+		"replace(nil -> nil",
+		"replace(nil -> nil) x",
+		"splice(1: atomic(1) -> nil)",
+		"splice(x: nil -> nil)",
+		"splice(1.5: nil -> nil)",
+		"move(1, 2)",
+		"path(1, 2 move(1, 2, 3))",
+		"changeset(nil nil)",
+		"update(1: boo -> nil)",
+		"update(1: caret(nil, 5) -> nil)",
+		"update(1: caret(ref(), 5, right) -> nil)",
+		"update(1: range(ref(), ref()) -> nil)",
+		"replace(boo -> nil)",
+		`replace(s16 5 -> nil)`,
+		`replace("\x" -> nil)`,
+		"replace(counter(100000000000000000000) -> nil)",
+		"replace(atomic(-x) -> nil)",
+		"replace(@ -> nil)",
+	}
+
+	for _, s := range errors {
+		if c, err := format.ParseChange(s); err == nil {
+			t.Error("Unexpected success", s, c)
+		}
+	}
+
+	if _, err := format.ParseValue(`"x`); err == nil {
+		t.Error("Unexpected success")
+	}
+}
+
+func TestMustParsePanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Failed to panic")
+		}
+	}()
+	format.MustParse("boo")
+}
+
+func TestParseErrorMessage(t *testing.T) {
+	_, err := format.ParseChange("move(1, x, 2)")
+	if err == nil || err.Error() != "8: expected number" {
+		t.Error("Unexpected error", err)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package changes
+
+import "reflect"
+
+// Merger is an optional interface for values which can reconcile
+// concurrent Replace changes.
+//
+// By default, two concurrent Replace changes are resolved by "last
+// writer wins".  If the After value of the later Replace implements
+// Merger, Merge3 is called on it with the common Before value (base)
+// and the After value of the other Replace (left). The result is
+// used as the final value for both sides.
+//
+// Deletes and creates are never merged this way.
+type Merger interface {
+	Merge3(base, left Value) Value
+}
+
+// AtomicMerger is the equivalent of Merger for the raw values
+// wrapped by Atomic.  Atomic values are only merged if the base and
+// the left values are also Atomic.
+type AtomicMerger interface {
+	Merge3(base, left interface{}) interface{}
+}
+
+// merge3 returns the reconciled value if right supports it
+func merge3(base, left, right Value) (Value, bool) {
+	if m, ok := right.(Merger); ok {
+		return m.Merge3(base, left), true
+	}
+
+	r, ok1 := right.(Atomic)
+	b, ok2 := base.(Atomic)
+	l, ok3 := left.(Atomic)
+	if m, ok := r.Value.(AtomicMerger); ok && ok1 && ok2 && ok3 {
+		return Atomic{m.Merge3(b.Value, l.Value)}, true
+	}
+	return nil, false
+}
+
+// MergeFields implements a field-wise three-way merge of structs.
+// Each exported field takes the left value if the right value is
+// unchanged from base and the right value otherwise.  Unexported
+// fields always take the right value.
+//
+// If the args are not structs of the same type, right is returned.
+//
+// This is meant to be used by Merger or AtomicMerger implementations:
+//
+//	func (p Point) Merge3(base, left interface{}) interface{} {
+//	        return changes.MergeFields(base, left, p)
+//	}
+func MergeFields(base, left, right interface{}) interface{} {
+	b, l, r := reflect.ValueOf(base), reflect.ValueOf(left), reflect.ValueOf(right)
+	if r.Kind() != reflect.Struct || !b.IsValid() || !l.IsValid() || b.Type() != r.Type() || l.Type() != r.Type() {
+		return right
+	}
+
+	result := reflect.New(r.Type()).Elem()
+	result.Set(r)
+	for kk := 0; kk < r.NumField(); kk++ {
+		if !result.Field(kk).CanSet() {
+			continue
+		}
+		if reflect.DeepEqual(b.Field(kk).Interface(), r.Field(kk).Interface()) {
+			result.Field(kk).Set(l.Field(kk))
+		}
+	}
+	return result.Interface()
+}
@@ -0,0 +1,112 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package changes_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/test/fuzztest"
+)
+
+type point struct {
+	X, Y   int
+	hidden int
+}
+
+func (p point) Merge3(base, left interface{}) interface{} {
+	return changes.MergeFields(base, left, p)
+}
+
+// pointValue implements changes.Merger directly
+type pointValue point
+
+func (p pointValue) Apply(ctx changes.Context, c changes.Change) changes.Value {
+	return changes.Atomic{Value: point(p)}.Apply(ctx, c)
+}
+
+func (p pointValue) Merge3(base, left changes.Value) changes.Value {
+	b, l := base.(pointValue), left.(pointValue)
+	return pointValue(changes.MergeFields(point(b), point(l), point(p)).(point))
+}
+
+func TestMerge3Atomic(t *testing.T) {
+	base := changes.Atomic{Value: point{X: 1, Y: 1}}
+	left := changes.Replace{Before: base, After: changes.Atomic{Value: point{X: 2, Y: 1}}}
+	right := changes.Replace{Before: base, After: changes.Atomic{Value: point{X: 1, Y: 3}}}
+
+	rightx, leftx := left.Merge(right)
+	expected := changes.Atomic{Value: point{X: 2, Y: 3}}
+	if v := base.Apply(nil, left).Apply(nil, rightx); v != expected {
+		t.Error("Unexpected merge", v)
+	}
+	if v := base.Apply(nil, right).Apply(nil, leftx); v != expected {
+		t.Error("Unexpected merge", v)
+	}
+}
+
+func TestMerge3Value(t *testing.T) {
+	base := pointValue{X: 1, Y: 1}
+	left := changes.Replace{Before: base, After: pointValue{X: 2, Y: 2}}
+	right := changes.Replace{Before: base, After: pointValue{X: 1, Y: 3}}
+
+	rightx, leftx := left.Merge(right)
+	expected := pointValue{X: 2, Y: 3}
+	if rightx != (changes.Replace{Before: left.After, After: expected}) {
+		t.Error("Unexpected merge", rightx)
+	}
+	if leftx != (changes.Replace{Before: right.After, After: expected}) {
+		t.Error("Unexpected merge", leftx)
+	}
+}
+
+func TestMerge3SkipsDeletes(t *testing.T) {
+	base := changes.Atomic{Value: point{X: 1, Y: 1}}
+	left := changes.Replace{Before: base, After: changes.Nil}
+	right := changes.Replace{Before: base, After: changes.Atomic{Value: point{X: 1, Y: 3}}}
+
+	rightx, leftx := left.Merge(right)
+	if leftx != nil || rightx != (changes.Replace{Before: changes.Nil, After: right.After}) {
+		t.Error("Unexpected merge", rightx, leftx)
+	}
+
+	rightx, leftx = right.Merge(left)
+	if leftx != nil || rightx != (changes.Replace{Before: right.After, After: changes.Nil}) {
+		t.Error("Unexpected merge", rightx, leftx)
+	}
+}
+
+func TestMergeFields(t *testing.T) {
+	base, left, right := point{1, 1, 1}, point{2, 1, 2}, point{1, 1, 3}
+	if x := changes.MergeFields(base, left, right); x != (point{2, 1, 3}) {
+		t.Error("Unexpected merge", x)
+	}
+
+	if x := changes.MergeFields(nil, left, right); x != right {
+		t.Error("Unexpected merge", x)
+	}
+
+	if x := changes.MergeFields(base, "left", right); x != right {
+		t.Error("Unexpected merge", x)
+	}
+
+	if x := changes.MergeFields(1, 2, 3); x != 3 {
+		t.Error("Unexpected merge", x)
+	}
+}
+
+func TestMerge3Convergence(t *testing.T) {
+	randPoint := func(r *rand.Rand) changes.Value {
+		return changes.Atomic{Value: point{X: r.Intn(3), Y: r.Intn(3)}}
+	}
+	model := fuzztest.Model{
+		Value: randPoint,
+		Change: func(r *rand.Rand, v changes.Value) changes.Change {
+			return changes.Replace{Before: v, After: randPoint(r)}
+		},
+	}
+	model.Check(t, 500)
+}
@@ -0,0 +1,242 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+// Package pointer implements path helpers for changes.PathChange.
+//
+// Paths can be built from RFC 6901 JSON pointer strings:
+//
+//	path, err := pointer.Parse(todos, "/Todos/3/Description")
+//	c, err := pointer.Change(todos, "/Todos/3/Description", splice)
+//
+// Or via the typed Builder:
+//
+//	c := pointer.Builder{}.Key("Todos").Index(3).Change(splice)
+//
+// Get resolves a path against types.A, types.M, values that
+// implement a "Get(key interface{}) changes.Value" method and values
+// built on types.Generic, such as dotc generated structs and slices.
+//
+// Pointer tokens are converted to int indices when the value being
+// indexed is a changes.Collection and left as string keys
+// otherwise. This means types.M entries with non-string keys cannot
+// be addressed with a pointer though Get can still be called with
+// such keys.  Invalid paths are reported as errors
+// rather than panics.
+package pointer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+)
+
+// Error is returned when a path or pointer is invalid
+type Error struct {
+	// Path is the portion of the path up to and including the
+	// element that failed
+	Path    []interface{}
+	Message string
+}
+
+// Error implements the error interface
+func (e Error) Error() string {
+	return Format(e.Path) + ": " + e.Message
+}
+
+// Get returns the value at the provided path. An error is returned
+// if the path does not exist.
+func Get(v changes.Value, path []interface{}) (changes.Value, error) {
+	for kk, key := range path {
+		var err error
+		if v, err = child(v, key); err != nil {
+			return nil, Error{path[: kk+1 : kk+1], err.Error()}
+		}
+		if v == changes.Nil {
+			return nil, Error{path[: kk+1 : kk+1], "no value"}
+		}
+	}
+	return v, nil
+}
+
+// Parse converts a JSON pointer to a path within the value v. The
+// last element of the path need not exist (such as a new map key)
+// but its container must.
+func Parse(v changes.Value, pointer string) ([]interface{}, error) {
+	tokens, err := tokenize(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	var path []interface{}
+	for kk, token := range tokens {
+		key, err := keyOf(v, token)
+		path = append(path, key)
+		if err != nil {
+			return nil, Error{path, err.Error()}
+		}
+		if v, err = child(v, key); err != nil {
+			return nil, Error{path, err.Error()}
+		}
+		if v == changes.Nil && kk < len(tokens)-1 {
+			return nil, Error{path, "no value"}
+		}
+	}
+	return path, nil
+}
+
+// Change returns a PathChange for the JSON pointer within v
+func Change(v changes.Value, pointer string, c changes.Change) (changes.Change, error) {
+	path, err := Parse(v, pointer)
+	if err != nil {
+		return nil, err
+	}
+	return changes.PathChange{Path: path, Change: c}, nil
+}
+
+// Format converts a path to a JSON pointer
+func Format(path []interface{}) string {
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	result := ""
+	for _, key := range path {
+		result += "/" + escaper.Replace(fmt.Sprint(key))
+	}
+	return result
+}
+
+// Builder builds paths using typed keys.  The zero value is the
+// empty path
+type Builder []interface{}
+
+// Key returns a new path with the string key appended
+func (b Builder) Key(key string) Builder {
+	return append(b[:len(b):len(b)], key)
+}
+
+// Index returns a new path with the array index appended
+func (b Builder) Index(idx int) Builder {
+	return append(b[:len(b):len(b)], idx)
+}
+
+// Pointer returns the JSON pointer for the path
+func (b Builder) Pointer() string {
+	return Format(b)
+}
+
+// Get returns the value at the path
+func (b Builder) Get(v changes.Value) (changes.Value, error) {
+	return Get(v, b)
+}
+
+// Change wraps the change with the path
+func (b Builder) Change(c changes.Change) changes.Change {
+	return changes.PathChange{Path: []interface{}(b), Change: c}
+}
+
+func tokenize(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, Error{nil, "pointer must start with /"}
+	}
+
+	unescaper := strings.NewReplacer("~1", "/", "~0", "~")
+	tokens := strings.Split(pointer[1:], "/")
+	for kk, token := range tokens {
+		if strings.Count(token, "~") != strings.Count(token, "~0")+strings.Count(token, "~1") {
+			return nil, Error{nil, "invalid escape in " + strconv.Quote(token)}
+		}
+		tokens[kk] = unescaper.Replace(token)
+	}
+	return tokens, nil
+}
+
+func keyOf(v changes.Value, token string) (interface{}, error) {
+	c, ok := v.(changes.Collection)
+	if !ok {
+		return token, nil
+	}
+
+	idx, err := strconv.Atoi(token)
+	switch {
+	case err != nil || strconv.Itoa(idx) != token:
+		return token, errors.New("invalid index")
+	case idx < 0 || idx >= c.Count():
+		return idx, errors.New("index out of range")
+	}
+	return idx, nil
+}
+
+func child(v changes.Value, key interface{}) (changes.Value, error) {
+	if c, ok := v.(changes.Collection); ok {
+		if idx, ok := key.(int); !ok || idx < 0 || idx >= c.Count() {
+			return nil, errors.New("invalid index")
+		}
+	}
+
+	switch v := v.(type) {
+	case types.A:
+		return v[key.(int)], nil
+	case types.M:
+		if x, ok := v[key]; ok {
+			return x, nil
+		}
+		return changes.Nil, nil
+	case getter:
+		return v.Get(key), nil
+	case types.S8, types.S16, changes.Atomic:
+		return nil, errors.New("invalid key")
+	}
+	return applyChild(v, key)
+}
+
+// getter is implemented by values that can look up their children
+// directly
+type getter interface {
+	Get(key interface{}) changes.Value
+}
+
+// applyChild finds the child by applying a change to it. This works
+// with types.Generic based values (such as dotc generated structs)
+// which panic with the key itself when the key is not valid. Other
+// panics are not recovered.
+func applyChild(v changes.Value, key interface{}) (result changes.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if r != key {
+				panic(r)
+			}
+			err = errors.New("invalid key")
+		}
+	}()
+
+	v.Apply(nil, changes.PathChange{Path: []interface{}{key}, Change: capture{&result}})
+	return result, nil
+}
+
+// capture is a change which captures the value it is applied to
+type capture struct {
+	v *changes.Value
+}
+
+func (c capture) ApplyTo(ctx changes.Context, v changes.Value) changes.Value {
+	*c.v = v
+	return v
+}
+
+func (c capture) Revert() changes.Change {
+	return c
+}
+
+func (c capture) Merge(o changes.Change) (changes.Change, changes.Change) {
+	return o, c
+}
+
+func (c capture) ReverseMerge(o changes.Change) (changes.Change, changes.Change) {
+	return o, c
+}
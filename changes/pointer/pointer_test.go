@@ -0,0 +1,221 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package pointer_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/pointer"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/x/dotc/testdata/myslice"
+)
+
+// todo mimics a dotc generated struct
+type todo struct {
+	Description string
+	Done        bool
+}
+
+func (t todo) get(key interface{}) changes.Value {
+	switch key {
+	case "Description":
+		return types.S16(t.Description)
+	case "Done":
+		return changes.Atomic{Value: t.Done}
+	}
+	panic(key)
+}
+
+func (t todo) set(key interface{}, v changes.Value) changes.Value {
+	switch key {
+	case "Description":
+		t.Description = string(v.(types.S16))
+	case "Done":
+		t.Done = v.(changes.Atomic).Value.(bool)
+	}
+	return t
+}
+
+func (t todo) Apply(ctx changes.Context, c changes.Change) changes.Value {
+	return (types.Generic{Get: t.get, Set: t.set}).Apply(ctx, c, t)
+}
+
+var root = types.M{
+	"Todos": types.A{
+		todo{Description: "one"},
+		todo{Description: "two", Done: true},
+	},
+	"a/b~c": types.S8("escaped"),
+	"count": types.Counter(0),
+}
+
+func TestGet(t *testing.T) {
+	v, err := pointer.Get(root, []interface{}{"Todos", 1, "Description"})
+	if err != nil || v != types.S16("two") {
+		t.Error("Unexpected get", v, err)
+	}
+
+	v, err = pointer.Builder{}.Key("Todos").Index(0).Get(root)
+	if err != nil || v != (todo{Description: "one"}) {
+		t.Error("Unexpected get", v, err)
+	}
+
+	if v, err = pointer.Get(root, nil); err != nil || !reflect.DeepEqual(v, root) {
+		t.Error("Unexpected get", v, err)
+	}
+}
+
+func TestGetNonStringKeys(t *testing.T) {
+	m := types.M{5: types.S8("five")}
+	if v, err := pointer.Get(m, []interface{}{5}); err != nil || v != types.S8("five") {
+		t.Error("Unexpected get", v, err)
+	}
+}
+
+func TestGetGenericSlice(t *testing.T) {
+	slice := myslice.MySlice{true, false}
+	v, err := pointer.Get(slice, []interface{}{1})
+	if err != nil || v != (changes.Atomic{Value: false}) {
+		t.Error("Unexpected get", v, err)
+	}
+
+	p, err := pointer.Parse(slice, "/1")
+	if err != nil || !reflect.DeepEqual(p, []interface{}{1}) {
+		t.Error("Unexpected parse", p, err)
+	}
+
+	if v, err = pointer.Get(slice, []interface{}{2}); err == nil {
+		t.Error("Unexpected get", v, err)
+	}
+}
+
+// lookup implements the Get(key) method used by pointer.Get
+type lookup map[interface{}]changes.Value
+
+func (l lookup) Get(key interface{}) changes.Value {
+	if v, ok := l[key]; ok {
+		return v
+	}
+	return changes.Nil
+}
+
+func (l lookup) Apply(ctx changes.Context, c changes.Change) changes.Value {
+	panic("unexpected apply")
+}
+
+func TestGetter(t *testing.T) {
+	v, err := pointer.Get(lookup{"x": types.S8("y")}, []interface{}{"x"})
+	if err != nil || v != types.S8("y") {
+		t.Error("Unexpected get", v, err)
+	}
+
+	if v, err = pointer.Get(lookup{}, []interface{}{"x"}); err == nil {
+		t.Error("Unexpected get", v, err)
+	}
+}
+
+// broken panics on every apply
+type broken struct{}
+
+func (b broken) Apply(ctx changes.Context, c changes.Change) changes.Value {
+	panic("broken")
+}
+
+func TestGetPropagatesPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "broken" {
+			t.Error("Unexpected recover", r)
+		}
+	}()
+	_, _ = pointer.Get(broken{}, []interface{}{"x"})
+}
+
+func TestGetErrors(t *testing.T) {
+	errors := map[string][]interface{}{
+		"/Todos/5: invalid index":             {"Todos", 5},
+		"/Todos/x: invalid index":             {"Todos", "x"},
+		"/Todos/0/Title: invalid key":         {"Todos", 0, "Title"},
+		"/Missing: no value":                  {"Missing", 2},
+		"/Todos/0/Done/x: invalid key":        {"Todos", 0, "Done", "x"},
+		"/Todos/1/Description/0: invalid key": {"Todos", 1, "Description", 0},
+	}
+
+	for expected, path := range errors {
+		v, err := pointer.Get(root, path)
+		if err == nil || err.Error() != expected {
+			t.Error("Unexpected get", v, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := map[string][]interface{}{
+		"":                     nil,
+		"/Todos/1/Description": {"Todos", 1, "Description"},
+		"/a~1b~0c":             {"a/b~c"},
+		"/New":                 {"New"},
+		"/Todos/0":             {"Todos", 0},
+	}
+
+	for ptr, expected := range tests {
+		path, err := pointer.Parse(root, ptr)
+		if err != nil || !reflect.DeepEqual(path, expected) {
+			t.Error("Unexpected parse", ptr, path, err)
+		}
+		if s := pointer.Format(path); s != ptr {
+			t.Error("Unexpected format", s, ptr)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	errors := map[string]string{
+		"Todos":          ": pointer must start with /",
+		"/a~2":           ": invalid escape in \"a~2\"",
+		"/Todos/01":      "/Todos/01: invalid index",
+		"/Todos/2":       "/Todos/2: index out of range",
+		"/Todos/-1":      "/Todos/-1: index out of range",
+		"/Missing/x":     "/Missing: no value",
+		"/Todos/0/Title": "/Todos/0/Title: invalid key",
+	}
+
+	for ptr, expected := range errors {
+		path, err := pointer.Parse(root, ptr)
+		if err == nil || err.Error() != expected {
+			t.Error("Unexpected parse", ptr, path, err)
+		}
+		if _, err := pointer.Change(root, ptr, nil); err == nil {
+			t.Error("Unexpected change success", ptr)
+		}
+	}
+}
+
+func TestChange(t *testing.T) {
+	splice := changes.Splice{Offset: 3, Before: types.S16(""), After: types.S16("!")}
+	c, err := pointer.Change(root, "/Todos/1/Description", splice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := pointer.Builder{}.Key("Todos").Index(1).Key("Description").Change(splice)
+	if !reflect.DeepEqual(c, expected) {
+		t.Error("Unexpected change", c, expected)
+	}
+
+	v, _ := pointer.Get(root.Apply(nil, c), []interface{}{"Todos", 1})
+	if v != (todo{Description: "two!", Done: true}) {
+		t.Error("Unexpected apply", v)
+	}
+}
+
+func TestBuilderDoesNotAlias(t *testing.T) {
+	b := pointer.Builder{}.Key("Todos")
+	b1, b2 := b.Index(1), b.Index(2)
+	if b1.Pointer() != "/Todos/1" || b2.Pointer() != "/Todos/2" {
+		t.Error("Unexpected pointers", b1, b2)
+	}
+}
@@ -17,12 +17,22 @@ func (s Replace) Revert() Change {
 }
 
 // MergeReplace merges against another Replace change.  The last writer wins
-// here with the receiver assumed to be the earlier change
+// here with the receiver assumed to be the earlier change.
+//
+// If neither change is a create or delete and other.After implements
+// Merger (or is an Atomic holding an AtomicMerger), the two values
+// are reconciled instead.
 func (s Replace) MergeReplace(other Replace) (other1, s1 *Replace) {
 	if s.IsDelete() && other.IsDelete() {
 		return nil, nil
 	}
 
+	if !s.IsDelete() && !s.IsCreate() && !other.IsDelete() && !other.IsCreate() {
+		if merged, ok := merge3(s.Before, s.After, other.After); ok {
+			return &Replace{s.After, merged}, &Replace{other.After, merged}
+		}
+	}
+
 	other.Before = s.After
 	return &other, nil
 }
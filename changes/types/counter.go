@@ -27,23 +27,13 @@ func (c Counter) Count() int {
 
 // ApplyCollection implements Collection interface
 func (c Counter) ApplyCollection(ctx changes.Context, cx changes.Change) changes.Collection {
-	return c + cx.(changes.Splice).After.(Counter)
+	splice := cx.(changes.Splice)
+	return c - splice.Before.(Counter) + splice.After.(Counter)
 }
 
 // Apply only supports Replace and Inserts
 func (c Counter) Apply(ctx changes.Context, cx changes.Change) changes.Value {
-	switch cx := cx.(type) {
-	case nil:
-		return c
-	case changes.Replace:
-		if cx.IsDelete() {
-			return changes.Nil
-		}
-		return cx.After
-	case changes.Custom:
-		return cx.ApplyTo(ctx, c)
-	}
-	return c.ApplyCollection(ctx, cx)
+	return counterApply(ctx, c, cx)
 }
 
 // Increment returns a change which implements the increment
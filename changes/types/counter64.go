@@ -0,0 +1,107 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package types
+
+import "github.com/dotchain/dot/changes"
+
+// Counter64 implements a 64-bit counter. It is identical to Counter
+// except for the size.
+type Counter64 int64
+
+// Slice implements changes.Value.Slice but it is not expected to ever
+// by used for counters
+func (c Counter64) Slice(offset, count int) changes.Collection {
+	panic("Slice call not expected on counter")
+}
+
+// Count always returns 1 for non-zero counters
+func (c Counter64) Count() int {
+	if c != 0 {
+		return 1
+	}
+	return 0
+}
+
+// ApplyCollection implements Collection interface
+func (c Counter64) ApplyCollection(ctx changes.Context, cx changes.Change) changes.Collection {
+	splice := cx.(changes.Splice)
+	return c - splice.Before.(Counter64) + splice.After.(Counter64)
+}
+
+// Apply only supports Replace and Inserts
+func (c Counter64) Apply(ctx changes.Context, cx changes.Change) changes.Value {
+	return counterApply(ctx, c, cx)
+}
+
+// Increment returns a change which implements the increment
+// operation.
+func (c Counter64) Increment(by int64) changes.Change {
+	return changes.Splice{Before: Counter64(0), After: Counter64(by)}
+}
+
+// Set returns a change which implements updating the value
+func (c Counter64) Set(v int64) changes.Change {
+	return changes.Replace{Before: c, After: Counter64(v)}
+}
+
+// FloatCounter implements a float64 counter.
+//
+// Concurrent increments are applied in different orders on different
+// clients and floating point addition is not associative, so the
+// values may differ in the least significant bits unless the
+// increments are exactly representable (such as integers or
+// multiples of a power of two).
+type FloatCounter float64
+
+// Slice implements changes.Value.Slice but it is not expected to ever
+// by used for counters
+func (c FloatCounter) Slice(offset, count int) changes.Collection {
+	panic("Slice call not expected on counter")
+}
+
+// Count always returns 1 for non-zero counters
+func (c FloatCounter) Count() int {
+	if c != 0 {
+		return 1
+	}
+	return 0
+}
+
+// ApplyCollection implements Collection interface
+func (c FloatCounter) ApplyCollection(ctx changes.Context, cx changes.Change) changes.Collection {
+	splice := cx.(changes.Splice)
+	return c - splice.Before.(FloatCounter) + splice.After.(FloatCounter)
+}
+
+// Apply only supports Replace and Inserts
+func (c FloatCounter) Apply(ctx changes.Context, cx changes.Change) changes.Value {
+	return counterApply(ctx, c, cx)
+}
+
+// Increment returns a change which implements the increment
+// operation.
+func (c FloatCounter) Increment(by float64) changes.Change {
+	return changes.Splice{Before: FloatCounter(0), After: FloatCounter(by)}
+}
+
+// Set returns a change which implements updating the value
+func (c FloatCounter) Set(v float64) changes.Change {
+	return changes.Replace{Before: c, After: FloatCounter(v)}
+}
+
+func counterApply(ctx changes.Context, c changes.Collection, cx changes.Change) changes.Value {
+	switch cx := cx.(type) {
+	case nil:
+		return c
+	case changes.Replace:
+		if cx.IsDelete() {
+			return changes.Nil
+		}
+		return cx.After
+	case changes.Custom:
+		return cx.ApplyTo(ctx, c)
+	}
+	return c.ApplyCollection(ctx, cx)
+}
@@ -0,0 +1,111 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package types_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/test/fuzztest"
+)
+
+func TestCounter64Apply(t *testing.T) {
+	c := types.Counter64(1 << 40)
+	if x := c.Apply(nil, nil); x != c {
+		t.Error("Apply(nil, nil)", x)
+	}
+
+	if x := c.Apply(nil, changes.Replace{Before: c, After: changes.Nil}); x != changes.Nil {
+		t.Error("Replace(IsDelete)", x)
+	}
+
+	if x := c.Apply(nil, c.Increment(1<<41)); x != types.Counter64(3<<40) {
+		t.Error("Increment()", x)
+	}
+
+	if x := c.Apply(nil, c.Set(42)); x != types.Counter64(42) {
+		t.Error("Set", x)
+	}
+
+	if x := c.Apply(nil, changes.ChangeSet{c.Increment(2)}); x != c+2 {
+		t.Error("ChangeSet", x)
+	}
+
+	if types.Counter64(0).Count() != 0 || c.Count() != 1 {
+		t.Error("Unexpected count")
+	}
+}
+
+func TestFloatCounterApply(t *testing.T) {
+	c := types.FloatCounter(1.5)
+	if x := c.Apply(nil, nil); x != c {
+		t.Error("Apply(nil, nil)", x)
+	}
+
+	if x := c.Apply(nil, changes.Replace{Before: c, After: changes.Nil}); x != changes.Nil {
+		t.Error("Replace(IsDelete)", x)
+	}
+
+	if x := c.Apply(nil, c.Increment(-0.25)); x != types.FloatCounter(1.25) {
+		t.Error("Increment()", x)
+	}
+
+	if x := c.Apply(nil, c.Set(42)); x != types.FloatCounter(42) {
+		t.Error("Set", x)
+	}
+
+	if x := c.Apply(nil, changes.ChangeSet{c.Increment(2)}); x != c+2 {
+		t.Error("ChangeSet", x)
+	}
+
+	if types.FloatCounter(0).Count() != 0 || c.Count() != 1 {
+		t.Error("Unexpected count")
+	}
+}
+
+func TestCounter64Panics(t *testing.T) {
+	mustPanic := func(fn func()) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Failed to panic")
+			}
+		}()
+		fn()
+	}
+
+	mustPanic(func() {
+		types.Counter64(0).Slice(0, 0)
+	})
+
+	mustPanic(func() {
+		types.FloatCounter(0).Slice(0, 0)
+	})
+}
+
+func TestCounterConvergence(t *testing.T) {
+	counters := map[string]func(n int) changes.Value{
+		"counter":   func(n int) changes.Value { return types.Counter(n) },
+		"counter64": func(n int) changes.Value { return types.Counter64(n) << 33 },
+		"float":     func(n int) changes.Value { return types.FloatCounter(n) / 4 },
+	}
+
+	for name, counter := range counters {
+		counter := counter
+		model := fuzztest.Model{
+			Value: func(r *rand.Rand) changes.Value {
+				return counter(r.Intn(100) - 50)
+			},
+			Change: func(r *rand.Rand, v changes.Value) changes.Change {
+				if r.Intn(5) == 0 {
+					return changes.Replace{Before: v, After: counter(r.Intn(10))}
+				}
+				return changes.Splice{Before: counter(0).(changes.Collection), After: counter(r.Intn(20) - 10).(changes.Collection)}
+			},
+		}
+		t.Run(name, func(t *testing.T) { model.Check(t, 300) })
+	}
+}
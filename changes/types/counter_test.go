@@ -29,6 +29,10 @@ func TestCounterApply(t *testing.T) {
 		t.Error("Increment()", x)
 	}
 
+	if x := c.Apply(nil, c.Increment(2)).Apply(nil, c.Increment(2).Revert()); x != c {
+		t.Error("Increment().Revert()", x)
+	}
+
 	if x := c.Apply(nil, c.Set(42)); x != types.Counter(42) {
 		t.Error("Set", x)
 	}
@@ -0,0 +1,221 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package types
+
+import (
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/refs"
+)
+
+// Set represents an unordered set of comparable keys. It implements
+// the changes.Value interface.
+//
+// Sets are modified via SetAdd and SetRemove which implement
+// add-wins observed-remove semantics: a key that is concurrently
+// added and removed remains in the set.
+type Set map[interface{}]bool
+
+// Apply applies the change and returns the updated value
+func (s Set) Apply(ctx changes.Context, c changes.Change) changes.Value {
+	return (Generic{}).Apply(ctx, c, s)
+}
+
+// Add returns a change which adds the provided keys
+func (s Set) Add(keys ...interface{}) changes.Change {
+	var existing []interface{}
+	for _, key := range keys {
+		if s[key] {
+			existing = append(existing, key)
+		}
+	}
+	return SetAdd{Keys: keys, Existing: existing}.simplify()
+}
+
+// Remove returns a change which removes the provided keys.  Keys
+// that are not in the set are ignored.
+func (s Set) Remove(keys ...interface{}) changes.Change {
+	var present []interface{}
+	for _, key := range keys {
+		if s[key] {
+			present = append(present, key)
+		}
+	}
+	return SetRemove{Keys: present}.simplify()
+}
+
+func (s Set) update(add, remove []interface{}) Set {
+	result := make(Set, len(s)+len(add))
+	for key := range s {
+		result[key] = true
+	}
+	for _, key := range add {
+		result[key] = true
+	}
+	for _, key := range remove {
+		delete(result, key)
+	}
+	return result
+}
+
+// SetAdd adds Keys to a Set.  Existing holds the subset of keys
+// that were already present (and so are not removed on Revert).
+type SetAdd struct {
+	Keys, Existing []interface{}
+}
+
+// ApplyTo implements changes.Custom
+func (s SetAdd) ApplyTo(ctx changes.Context, v changes.Value) changes.Value {
+	return v.(Set).update(s.Keys, nil)
+}
+
+// Revert removes all the keys that were not already present
+func (s SetAdd) Revert() changes.Change {
+	return SetRemove{Keys: setDiff(s.Keys, s.Existing)}.simplify()
+}
+
+// Merge implements changes.Change
+func (s SetAdd) Merge(o changes.Change) (changes.Change, changes.Change) {
+	switch o := o.(type) {
+	case SetAdd:
+		o.Existing = setUnion(o.Existing, setIntersect(o.Keys, s.Keys))
+		s.Existing = setUnion(s.Existing, setIntersect(s.Keys, o.Keys))
+		return o, s
+	case SetRemove:
+		s.Existing = setDiff(s.Existing, o.Keys)
+		return SetRemove{Keys: setDiff(o.Keys, s.Keys)}.simplify(), s
+	}
+	return setMerge(s, o)
+}
+
+// ReverseMerge implements changes.Custom. Set changes are
+// symmetric, so this is the same as Merge.
+func (s SetAdd) ReverseMerge(o changes.Change) (changes.Change, changes.Change) {
+	return s.Merge(o)
+}
+
+// MergePath implements refs.PathMerger. Adds do not affect any
+// paths other than the set itself.
+func (s SetAdd) MergePath(p []interface{}) *refs.MergeResult {
+	if len(p) == 0 {
+		return &refs.MergeResult{P: p, Scoped: s, Affected: s}
+	}
+	return &refs.MergeResult{P: p, Unaffected: s}
+}
+
+func (s SetAdd) simplify() changes.Change {
+	if len(s.Keys) == 0 {
+		return nil
+	}
+	return s
+}
+
+// SetRemove removes Keys from a Set.  All the keys are expected to
+// be present in the set.
+type SetRemove struct {
+	Keys []interface{}
+}
+
+// ApplyTo implements changes.Custom
+func (s SetRemove) ApplyTo(ctx changes.Context, v changes.Value) changes.Value {
+	return v.(Set).update(nil, s.Keys)
+}
+
+// Revert adds back all the removed keys
+func (s SetRemove) Revert() changes.Change {
+	return SetAdd{Keys: s.Keys}.simplify()
+}
+
+// Merge implements changes.Change
+func (s SetRemove) Merge(o changes.Change) (changes.Change, changes.Change) {
+	switch o := o.(type) {
+	case SetAdd:
+		o.Existing = setDiff(o.Existing, s.Keys)
+		return o, SetRemove{Keys: setDiff(s.Keys, o.Keys)}.simplify()
+	case SetRemove:
+		ox := SetRemove{Keys: setDiff(o.Keys, s.Keys)}
+		sx := SetRemove{Keys: setDiff(s.Keys, o.Keys)}
+		return ox.simplify(), sx.simplify()
+	}
+	return setMerge(s, o)
+}
+
+// ReverseMerge implements changes.Custom. Set changes are
+// symmetric, so this is the same as Merge.
+func (s SetRemove) ReverseMerge(o changes.Change) (changes.Change, changes.Change) {
+	return s.Merge(o)
+}
+
+// MergePath implements refs.PathMerger. Paths referring to a removed
+// key are invalidated.
+func (s SetRemove) MergePath(p []interface{}) *refs.MergeResult {
+	if len(p) == 0 {
+		return &refs.MergeResult{P: p, Scoped: s, Affected: s}
+	}
+	for _, key := range s.Keys {
+		if key == p[0] {
+			return nil
+		}
+	}
+	return &refs.MergeResult{P: p, Unaffected: s}
+}
+
+func (s SetRemove) simplify() changes.Change {
+	if len(s.Keys) == 0 {
+		return nil
+	}
+	return s
+}
+
+// setMerge merges a set change with a non-set change
+func setMerge(s changes.Custom, o changes.Change) (changes.Change, changes.Change) {
+	switch o := o.(type) {
+	case nil:
+		return nil, s
+	case changes.Replace:
+		o.Before = o.Before.Apply(nil, s)
+		return o, nil
+	case changes.PathChange:
+		if len(o.Path) == 0 {
+			return s.Merge(o.Change)
+		}
+		return o, s
+	}
+
+	sx, ox := o.(changes.Custom).ReverseMerge(s)
+	return ox, sx
+}
+
+func setContains(keys []interface{}, key interface{}) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func setDiff(a, b []interface{}) []interface{} {
+	var result []interface{}
+	for _, key := range a {
+		if !setContains(b, key) {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+func setIntersect(a, b []interface{}) []interface{} {
+	var result []interface{}
+	for _, key := range a {
+		if setContains(b, key) {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+func setUnion(a, b []interface{}) []interface{} {
+	return append(append([]interface{}(nil), a...), setDiff(b, a)...)
+}
@@ -0,0 +1,138 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package types_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/refs"
+	"github.com/dotchain/dot/test/fuzztest"
+)
+
+func TestSetApply(t *testing.T) {
+	s := types.Set{"a": true, "b": true}
+
+	if x := s.Apply(nil, s.Add("b", "c")); !reflect.DeepEqual(x, types.Set{"a": true, "b": true, "c": true}) {
+		t.Error("Add", x)
+	}
+
+	if x := s.Apply(nil, s.Remove("b", "c")); !reflect.DeepEqual(x, types.Set{"a": true}) {
+		t.Error("Remove", x)
+	}
+
+	if x := s.Add("a").Revert(); x != nil {
+		t.Error("Revert of add existing", x)
+	}
+
+	if x := s.Remove("c"); x != nil {
+		t.Error("Remove missing", x)
+	}
+
+	if x := s.Add(); x != nil {
+		t.Error("Empty add", x)
+	}
+
+	x := s.Apply(nil, changes.PathChange{Change: s.Remove("a")})
+	if !reflect.DeepEqual(x, types.Set{"b": true}) {
+		t.Error("PathChange", x)
+	}
+}
+
+func TestSetAddWins(t *testing.T) {
+	s := types.Set{"a": true}
+	add, remove := s.Add("a", "b"), s.Remove("a")
+
+	removex, addx := add.Merge(remove)
+	expected := types.Set{"a": true, "b": true}
+	if x := s.Apply(nil, add).Apply(nil, removex); !reflect.DeepEqual(x, expected) {
+		t.Error("Unexpected merge", x)
+	}
+	if x := s.Apply(nil, remove).Apply(nil, addx); !reflect.DeepEqual(x, expected) {
+		t.Error("Unexpected merge", x)
+	}
+
+	// the add of "a" is no longer a no-op after the remove
+	if x := s.Apply(nil, remove).Apply(nil, addx).Apply(nil, addx.Revert()); !reflect.DeepEqual(x, types.Set{}) {
+		t.Error("Unexpected revert", x)
+	}
+}
+
+func TestSetMergeOthers(t *testing.T) {
+	s := types.Set{"a": true}
+	replace := changes.Replace{Before: s, After: types.S8("x")}
+
+	for _, c := range []changes.Change{s.Add("b"), s.Remove("a")} {
+		cx, rx := replace.Merge(c)
+		if cx != nil || !reflect.DeepEqual(rx.(changes.Replace).Before, s.Apply(nil, c)) {
+			t.Error("Unexpected replace merge", rx, cx)
+		}
+
+		if rx, cx = c.Merge(nil); rx != nil || !reflect.DeepEqual(cx, c) {
+			t.Error("Unexpected nil merge", rx, cx)
+		}
+
+		pc := changes.PathChange{Path: []interface{}{"x"}, Change: replace}
+		if rx, cx = c.Merge(pc); !reflect.DeepEqual(rx, pc) || !reflect.DeepEqual(cx, c) {
+			t.Error("Unexpected path merge", rx, cx)
+		}
+	}
+}
+
+func TestSetMergePath(t *testing.T) {
+	s := types.Set{"a": true, "b": true}
+	if r := refs.Merge([]interface{}{"a"}, s.Remove("a")); r != nil {
+		t.Error("Remove failed to invalidate", r)
+	}
+	if r := refs.Merge([]interface{}{"b"}, s.Remove("a")); r == nil || r.Scoped != nil {
+		t.Error("Remove affected other keys", r)
+	}
+	if r := refs.Merge([]interface{}{"a"}, s.Add("a", "c")); r == nil || r.Scoped != nil {
+		t.Error("Add affected path", r)
+	}
+	if r := s.Remove("a").(types.SetRemove).MergePath(nil); r == nil || !reflect.DeepEqual(r.Scoped, s.Remove("a")) {
+		t.Error("Remove did not affect the set", r)
+	}
+	if r := s.Add("c").(types.SetAdd).MergePath(nil); r == nil || r.Scoped == nil {
+		t.Error("Add did not affect the set", r)
+	}
+}
+
+var setModel = fuzztest.Model{
+	Value: func(r *rand.Rand) changes.Value {
+		s := types.Set{}
+		for kk := r.Intn(4); kk > 0; kk-- {
+			s[r.Intn(6)] = true
+		}
+		return s
+	},
+	Change: func(r *rand.Rand, v changes.Value) changes.Change {
+		s := v.(types.Set)
+		keys := []interface{}{r.Intn(6), r.Intn(6)}[:1+r.Intn(2)]
+		switch r.Intn(5) {
+		case 0:
+			return changes.Replace{Before: s, After: types.Set{}}
+		case 1, 2:
+			return s.Add(keys...)
+		}
+		return s.Remove(keys...)
+	},
+	Path: func(r *rand.Rand, v changes.Value) []interface{} {
+		for key := range v.(types.Set) {
+			return []interface{}{key}
+		}
+		return nil
+	},
+	Get: func(v changes.Value, path []interface{}) changes.Value {
+		return changes.Atomic{Value: v.(types.Set)[path[0]]}
+	},
+}
+
+func TestSetConvergence(t *testing.T) {
+	setModel.Check(t, 500)
+}
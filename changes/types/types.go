@@ -16,6 +16,11 @@
 // Counter implements a 32-bit integer counter. This also serves as an
 // example of an interesting data structure as it uses a virtual array
 // as far as OT is concerned but only stores the accumuated count.
+// Counter64 and FloatCounter are the 64-bit integer and float64
+// versions.
+//
+// Set implements an unordered set of keys with SetAdd and SetRemove
+// changes that merge using add-wins observed-remove semantics.
 //
 // A much richer type is available at
 // https://godoc.org/github.com/dotchain/dot/x/rt which also
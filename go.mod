@@ -1,6 +1,6 @@
 module github.com/dotchain/dot
 
-go 1.12
+go 1.18
 
 require (
 	github.com/etcd-io/bbolt v1.3.2
@@ -17,8 +17,9 @@ import (
 	"honnef.co/go/js/xhr"
 )
 
-// Client implements the ops.Store interface by making network calls
-// to the provided Url.  All other fields of the Client are optional.
+// Client implements the ops.Store and presence.Presence interfaces
+// by making network calls to the provided Url.  All other fields of
+// the Client are optional.
 type Client struct {
 	URL         string
 	ContentType string
@@ -12,6 +12,7 @@ import (
 	"github.com/dotchain/dot/changes"
 	"github.com/dotchain/dot/changes/types"
 	"github.com/dotchain/dot/ops"
+	"github.com/dotchain/dot/ops/presence"
 	"github.com/dotchain/dot/ops/sjson"
 	"github.com/dotchain/dot/refs"
 )
@@ -50,11 +51,14 @@ type request struct {
 	Ops            []ops.Op
 	Version, Limit int
 	Duration       time.Duration
+	Owner          string
+	Entry          presence.Entry
 }
 
 type response struct {
-	Ops   []ops.Op
-	Error error
+	Ops     []ops.Op
+	Entries []presence.Entry
+	Error   error
 }
 
 var standardTypes = []interface{}{
@@ -70,11 +74,19 @@ var standardTypes = []interface{}{
 	types.S16(""),
 	types.M{},
 	types.Counter(0),
+	types.Counter64(0),
+	types.FloatCounter(0),
+	types.Set{},
+	types.SetAdd{},
+	types.SetRemove{},
 	ops.Operation{},
 	refs.Update{},
 	refs.Range{},
 	refs.Path{},
 	refs.Caret{},
+	refs.Anchored{},
+	refs.Ghost{},
+	refs.Selection{},
 }
 
 // Register registers the values with all the default codecs
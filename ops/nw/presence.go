@@ -0,0 +1,49 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package nw
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dotchain/dot/ops/presence"
+)
+
+// Publish proxies the presence.Presence Publish call over to the url
+func (c *Client) Publish(ctx context.Context, owner string, e presence.Entry) error {
+	_, err := c.request(ctx, &request{Name: "Publish", Owner: owner, Entry: e})
+	return err
+}
+
+// Remove proxies the presence.Presence Remove call over to the url
+func (c *Client) Remove(ctx context.Context, owner, client string) error {
+	req := &request{Name: "Remove", Owner: owner, Entry: presence.Entry{Client: client}}
+	_, err := c.request(ctx, req)
+	return err
+}
+
+// List proxies the presence.Presence List call over to the url. This
+// can be polled to fetch the entries of other clients.
+func (c *Client) List(ctx context.Context) ([]presence.Entry, error) {
+	res, err := c.request(ctx, &request{Name: "ListPresence"})
+	if err != nil {
+		return nil, err
+	}
+	return res.Entries, nil
+}
+
+func (h *Handler) servePresence(ctx context.Context, req *request) ([]presence.Entry, error) {
+	if h.Presence == nil {
+		return nil, errors.New("presence not supported")
+	}
+
+	switch req.Name {
+	case "Publish":
+		return nil, h.Presence.Publish(ctx, req.Owner, req.Entry)
+	case "Remove":
+		return nil, h.Presence.Remove(ctx, req.Owner, req.Entry.Client)
+	}
+	return h.Presence.List(ctx)
+}
@@ -0,0 +1,104 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package nw_test
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/ops/nw"
+	"github.com/dotchain/dot/ops/presence"
+	"github.com/dotchain/dot/refs"
+	"github.com/dotchain/dot/test/testops"
+)
+
+func TestPresence(t *testing.T) {
+	store := testops.MemStore(nil)
+	defer store.Close()
+	srv := httptest.NewServer(&nw.Handler{Store: store, Presence: &presence.Memory{}})
+	defer srv.Close()
+
+	for _, ct := range []string{"application/x-gob", "application/x-sjson"} {
+		c := &nw.Client{URL: srv.URL, Client: srv.Client(), ContentType: ct}
+		one := presence.Entry{Client: "one", Version: 2, Ref: refs.Caret{Index: 5}}
+		two := presence.Entry{Client: "two", Version: -1, Ref: refs.Range{
+			Start: refs.Caret{Index: 1},
+			End:   refs.Caret{Index: 2, IsLeft: true},
+		}}
+
+		if err := c.Publish(getContext(), "secret2", two); err != nil {
+			t.Fatal(ct, err)
+		}
+		if err := c.Publish(getContext(), "secret1", one); err != nil {
+			t.Fatal(ct, err)
+		}
+		entries, err := c.List(getContext())
+		if err != nil || !reflect.DeepEqual(entries, []presence.Entry{one, two}) {
+			t.Fatal(ct, "Unexpected entries", entries, err)
+		}
+
+		if err := c.Remove(getContext(), "secret2", "one"); err == nil {
+			t.Fatal(ct, "Unexpected remove by non-owner")
+		}
+		if err := c.Remove(getContext(), "secret1", "one"); err != nil {
+			t.Fatal(ct, err)
+		}
+		if err := c.Remove(getContext(), "secret2", "two"); err != nil {
+			t.Fatal(ct, err)
+		}
+		entries, err = c.List(getContext())
+		if err != nil || len(entries) != 0 {
+			t.Fatal(ct, "Unexpected entries", entries, err)
+		}
+	}
+}
+
+func TestPresenceRefs(t *testing.T) {
+	store := testops.MemStore(nil)
+	defer store.Close()
+	srv := httptest.NewServer(&nw.Handler{Store: store, Presence: &presence.Memory{}})
+	defer srv.Close()
+
+	values := []refs.Ref{
+		refs.NewSelection(refs.Caret{Index: 5}, refs.Caret{Path: refs.Path{2}, Index: 1}),
+		refs.Anchored{Range: refs.Range{Start: refs.Caret{Index: 1}, End: refs.Caret{Index: 2}}},
+		refs.Anchored{
+			Range: refs.Range{Start: refs.Caret{Index: 3}, End: refs.Caret{Index: 3}},
+			Ghost: &refs.Ghost{
+				At:      refs.Caret{Index: 3},
+				Removed: types.S8("hello"),
+				Range:   refs.Range{Start: refs.Caret{Index: 4}, End: refs.Caret{Index: 6}},
+				Offset:  3,
+			},
+		},
+	}
+	for _, ct := range []string{"application/x-gob", "application/x-sjson"} {
+		c := &nw.Client{URL: srv.URL, Client: srv.Client(), ContentType: ct}
+		for _, ref := range values {
+			e := presence.Entry{Client: "one", Version: 1, Ref: ref}
+			if err := c.Publish(getContext(), "secret", e); err != nil {
+				t.Fatal(ct, err)
+			}
+			entries, err := c.List(getContext())
+			if err != nil || !reflect.DeepEqual(entries, []presence.Entry{e}) {
+				t.Fatal(ct, "Unexpected entries", entries, err)
+			}
+		}
+	}
+}
+
+func TestPresenceNotSupported(t *testing.T) {
+	store := testops.MemStore(nil)
+	defer store.Close()
+	srv := httptest.NewServer(&nw.Handler{Store: store})
+	defer srv.Close()
+
+	c := &nw.Client{URL: srv.URL, Client: srv.Client()}
+	if _, err := c.List(getContext()); err == nil {
+		t.Fatal("Unexpected success")
+	}
+}
@@ -15,7 +15,7 @@ import (
 	"github.com/dotchain/dot/ops"
 )
 
-func (c *Client) request(ctx context.Context, r *request) ([]ops.Op, error) {
+func (c *Client) request(ctx context.Context, r *request) (*response, error) {
 	if c.Log == nil {
 		c.Log = log.Default()
 	}
@@ -59,18 +59,22 @@ func (c *Client) request(ctx context.Context, r *request) ([]ops.Op, error) {
 	if err != nil {
 		return nil, c.codecError(err)
 	}
-	return res.Ops, res.Error
+	return &res, res.Error
 }
 
 // Append proxies the Append call over to the url
 func (c *Client) Append(ctx context.Context, o []ops.Op) error {
-	_, err := c.request(ctx, &request{"Append", o, -1, -1, 0})
+	_, err := c.request(ctx, &request{Name: "Append", Ops: o, Version: -1, Limit: -1})
 	return err
 }
 
 // GetSince proxies the GetSince call over to the url
 func (c *Client) GetSince(ctx context.Context, version, limit int) ([]ops.Op, error) {
-	return c.request(ctx, &request{"GetSince", nil, version, limit, 0})
+	res, err := c.request(ctx, &request{Name: "GetSince", Version: version, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	return res.Ops, nil
 }
 
 // Close proxies the Close call over to the url
@@ -14,13 +14,17 @@ import (
 
 	"github.com/dotchain/dot/log"
 	"github.com/dotchain/dot/ops"
+	"github.com/dotchain/dot/ops/presence"
 )
 
 // Handler implements ServerHTTP using the provided store and codecs
 // map. If no codecs map is provided, DefaultCodecs is used instead.
+//
+// Presence is optional and serves the presence calls of Client.
 type Handler struct {
 	ops.Store
-	Codecs map[string]Codec
+	Codecs   map[string]Codec
+	Presence presence.Presence
 	log.Log
 
 	once sync.Once
@@ -75,6 +79,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		res.Error = h.Append(ctx, req.Ops)
 	case "GetSince":
 		res.Ops, res.Error = h.GetSince(ctx, req.Version, req.Limit)
+	case "Publish", "Remove", "ListPresence":
+		res.Entries, res.Error = h.servePresence(ctx, &req)
 	}
 
 	// do this hack since we can't be sure what error types are possible
@@ -0,0 +1,311 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+// Package p2p implements peer-to-peer replication of CRDT values.
+//
+// Changes to the types in changes/crdt commute (including compaction,
+// see crdt.Seq.Compact), so they do not need the sequential ordering
+// of an ops.Store.  Each Peer instead keeps a log of all changes it
+// has applied along with a version vector which tracks the number of
+// changes seen from every peer.
+//
+// Changes to nested values that are not crdt types (such as a
+// changes.Splice passed to crdt.Dict.Update) do not commute, so Peer
+// rejects them (see crdt.Commutes).
+//
+// Two peers synchronize via an anti-entropy exchange over any
+// io.ReadWriter: each side sends its version vector and then the
+// changes the other side is missing.  Changes are sent in the order
+// they were applied, so causal order is always preserved.
+//
+//	p1 := p2p.NewPeer("one", crdt.Seq{})
+//	p2 := p2p.NewPeer("two", crdt.Seq{})
+//	p1.Update(func(v changes.Value) changes.Change {
+//	        c, _ := v.(crdt.Seq).Splice(0, 0, []interface{}{"hello"})
+//	        return c
+//	})
+//
+//	c1, c2 := net.Pipe()
+//	go p1.Sync(c1)
+//	p2.Sync(c2)
+//
+// The values inside CRDT containers are encoded with the Codec
+// and so must be registered with it.
+//
+// The log grows with every change.  Entries that all peers have seen
+// can be dropped with Prune.  Peers that have not seen the pruned
+// entries can no longer sync with the pruning peer.
+package p2p
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/crdt"
+	"github.com/dotchain/dot/ops/nw"
+	"github.com/dotchain/dot/ops/sjson"
+)
+
+// Version is a version vector: it maps a peer ID to the number of
+// changes from that peer.
+type Version map[string]int
+
+// Entry is a single change in the log of a peer
+type Entry struct {
+	// Peer is the ID of the peer which created the change
+	Peer string
+
+	// Seq is the sequence number of the change for that peer,
+	// starting at 1
+	Seq int
+
+	Change changes.Change
+}
+
+// Peer holds a replicated value.  All methods are safe for concurrent use.
+type Peer struct {
+	// ID must be unique across all peers
+	ID string
+
+	// Codec is used for the exchange. It defaults to sjson.Std
+	Codec nw.Codec
+
+	mu      sync.Mutex
+	value   changes.Value
+	log     []Entry
+	version Version
+	pruned  Version
+	ranks   crdt.Ranks
+}
+
+// ErrNotCommutative is returned for changes that do not commute
+// with concurrent changes (see crdt.Commutes)
+var ErrNotCommutative = errors.New("change does not commute")
+
+// ErrPruned is returned by Sync if the remote peer is missing
+// changes that have been pruned
+var ErrPruned = errors.New("missing changes have been pruned")
+
+// NewPeer creates a new peer. All peers must start with the same
+// initial value.
+func NewPeer(id string, initial changes.Value) *Peer {
+	ranks := crdt.Ranks{}
+	value, _ := ranks.Intern(initial).(changes.Value)
+	return &Peer{ID: id, value: value, version: Version{}, pruned: Version{}, ranks: ranks}
+}
+
+// Value returns the current value
+func (p *Peer) Value() changes.Value {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.value
+}
+
+// Version returns the current version vector
+func (p *Peer) Version() Version {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.copyVersion()
+}
+
+// Update applies a local change. The provided function is called
+// with the current value and returns the change to apply (or nil).
+// Changes that do not commute are rejected with ErrNotCommutative.
+func (p *Peer) Update(fn func(v changes.Value) changes.Change) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c := fn(p.value)
+	if !crdt.Commutes(c) {
+		return ErrNotCommutative
+	}
+	if c != nil {
+		p.apply(Entry{Peer: p.ID, Seq: p.version[p.ID] + 1, Change: c})
+	}
+	return nil
+}
+
+// Prune drops all log entries included in the provided version.  The
+// version should be one that all peers are known to have seen.
+func (p *Peer) Prune(v Version) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	log := []Entry{}
+	for _, entry := range p.log {
+		if entry.Seq > v[entry.Peer] {
+			log = append(log, entry)
+		} else if entry.Seq > p.pruned[entry.Peer] {
+			p.pruned[entry.Peer] = entry.Seq
+		}
+	}
+	p.log = log
+}
+
+// Sync synchronizes with a remote peer.  The remote peer must call
+// Sync at the same time with the other end of the connection.
+//
+// Local updates made while Sync is in progress are not lost but may
+// not be sent until the next Sync.
+//
+// Remote changes which do not commute are not applied and Sync
+// returns ErrNotCommutative.  If the remote peer is missing changes
+// that have been pruned, Sync sends no changes and returns ErrPruned
+// after applying the changes received.
+//
+// If the exchange fails while a write is still pending, the write is
+// cancelled by setting a write deadline if rw supports it (such as a
+// net.Conn) or by closing rw if it is an io.Closer.  Otherwise, the
+// pending write only completes when rw accepts or fails it.
+func (p *Peer) Sync(rw io.ReadWriter) error {
+	entries, written := make(chan []Entry, 1), make(chan error, 1)
+	go func() {
+		err := p.write(rw, message{Version: p.Version()})
+		if list, ok := <-entries; ok && err == nil {
+			err = p.write(rw, message{Entries: list})
+		}
+		written <- err
+	}()
+
+	var remote, missing message
+	var pruneErr error
+	err := p.read(rw, &remote)
+	if err == nil {
+		var list []Entry
+		list, pruneErr = p.missing(remote.Version)
+		entries <- list
+		err = p.read(rw, &missing)
+	}
+	close(entries)
+
+	if err != nil {
+		if cancel(rw) {
+			<-written
+		}
+		return err
+	}
+
+	if err = p.applyRemote(missing.Entries); err == nil {
+		err = pruneErr
+	}
+	if werr := <-written; err == nil {
+		err = werr
+	}
+	return err
+}
+
+// cancel cancels pending writes, returning false if that is not
+// possible
+func cancel(rw io.ReadWriter) bool {
+	if d, ok := rw.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		if d.SetWriteDeadline(time.Now()) == nil {
+			return true
+		}
+	}
+	if c, ok := rw.(io.Closer); ok {
+		return c.Close() == nil
+	}
+	return false
+}
+
+// applyRemote applies the entries received from a remote peer,
+// stopping at the first change that does not commute
+func (p *Peer) applyRemote(entries []Entry) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, entry := range entries {
+		if entry.Seq != p.version[entry.Peer]+1 {
+			continue
+		}
+		if !crdt.Commutes(entry.Change) {
+			return ErrNotCommutative
+		}
+		p.apply(entry)
+	}
+	return nil
+}
+
+// missing returns all entries not included in the provided version.
+// It returns ErrPruned with no entries if some of them were pruned.
+func (p *Peer) missing(v Version) ([]Entry, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for id, seq := range p.pruned {
+		if v[id] < seq {
+			return []Entry{}, ErrPruned
+		}
+	}
+
+	result := []Entry{}
+	for _, entry := range p.log {
+		if entry.Seq > v[entry.Peer] {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+func (p *Peer) apply(entry Entry) {
+	entry.Change, _ = p.ranks.Intern(entry.Change).(changes.Change)
+	p.value = p.value.Apply(nil, entry.Change)
+	p.log = append(p.log, entry)
+	p.version[entry.Peer] = entry.Seq
+}
+
+func (p *Peer) copyVersion() Version {
+	result := Version{}
+	for id, seq := range p.version {
+		result[id] = seq
+	}
+	return result
+}
+
+// messages are length-prefixed as codecs may read ahead
+func (p *Peer) write(w io.Writer, m message) error {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 4))
+	if err := p.codec().Encode(m, &buf); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data, uint32(len(data)-4))
+	_, err := w.Write(data)
+	return err
+}
+
+func (p *Peer) read(r io.Reader, m *message) error {
+	var size uint32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return err
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	return p.codec().Decode(m, bytes.NewReader(data))
+}
+
+func (p *Peer) codec() nw.Codec {
+	if p.Codec == nil {
+		return sjson.Std
+	}
+	return p.Codec
+}
+
+type message struct {
+	Version Version
+	Entries []Entry
+}
+
+func init() {
+	crdt.Register(nw.Register)
+	nw.Register(message{})
+}
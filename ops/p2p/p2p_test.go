@@ -0,0 +1,295 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package p2p_test
+
+import (
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/crdt"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/ops/p2p"
+)
+
+func TestSync(t *testing.T) {
+	p1 := p2p.NewPeer("one", crdt.Seq{})
+	p2 := p2p.NewPeer("two", crdt.Seq{})
+
+	splice(p1, 0, 0, "hello")
+	splice(p2, 0, 0, "world")
+	sync(t, p1, p2)
+
+	x1, x2 := items(p1), items(p2)
+	if len(x1) != 2 || !reflect.DeepEqual(x1, x2) {
+		t.Fatal("Diverged", x1, x2)
+	}
+
+	splice(p1, 0, 1, "")
+	sync(t, p1, p2)
+	sync(t, p1, p2)
+	if x1, x2 := items(p1), items(p2); len(x1) != 1 || !reflect.DeepEqual(x1, x2) {
+		t.Fatal("Diverged", x1, x2)
+	}
+
+	expected := p2p.Version{"one": 2, "two": 1}
+	if v := p2.Version(); !reflect.DeepEqual(v, expected) {
+		t.Error("Unexpected version", v)
+	}
+}
+
+func TestSyncText(t *testing.T) {
+	p1 := p2p.NewPeer("one", crdt.Text{})
+	p2 := p2p.NewPeer("two", crdt.Text{})
+
+	update := func(p *p2p.Peer, offset, count int, insert string) {
+		p.Update(func(v changes.Value) changes.Change {
+			c, _ := v.(crdt.Text).Splice(offset, count, insert)
+			return c
+		})
+	}
+	update(p1, 0, 0, "hello world")
+	sync(t, p1, p2)
+	update(p1, 0, 1, "H")
+	update(p2, 6, 5, "there")
+	sync(t, p2, p1)
+
+	s1, s2 := p1.Value().(crdt.Text).String(), p2.Value().(crdt.Text).String()
+	if s1 != "Hello there" || s1 != s2 {
+		t.Fatal("Diverged", s1, s2)
+	}
+}
+
+func TestPartitions(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	peers := make([]*p2p.Peer, 5)
+	for kk := range peers {
+		peers[kk] = p2p.NewPeer(strconv.Itoa(kk), crdt.Seq{})
+	}
+
+	for round := 0; round < 50; round++ {
+		for _, p := range peers {
+			if r.Intn(2) == 0 {
+				randomEdit(r, p)
+			}
+		}
+
+		// randomly partition the peers and sync within partitions
+		groups := make([]int, len(peers))
+		for kk := range groups {
+			groups[kk] = r.Intn(3)
+		}
+		for kk := 0; kk < len(peers); kk++ {
+			other := r.Intn(len(peers))
+			if other != kk && groups[other] == groups[kk] {
+				sync(t, peers[kk], peers[other])
+			}
+		}
+	}
+
+	// heal all partitions
+	for _, p := range peers[1:] {
+		sync(t, peers[0], p)
+	}
+	for _, p := range peers[1:] {
+		sync(t, p, peers[0])
+	}
+
+	expected := items(peers[0])
+	for _, p := range peers[1:] {
+		if x := items(p); !reflect.DeepEqual(x, expected) {
+			t.Fatal("Diverged", p.ID, x, expected)
+		}
+		if !reflect.DeepEqual(p.Version(), peers[0].Version()) {
+			t.Fatal("Diverged version", p.Version(), peers[0].Version())
+		}
+	}
+}
+
+func TestSyncError(t *testing.T) {
+	c1, c2 := net.Pipe()
+	c2.Close()
+	if err := p2p.NewPeer("one", crdt.Seq{}).Sync(c1); err == nil {
+		t.Fatal("Unexpected success")
+	}
+}
+
+func TestSyncCompact(t *testing.T) {
+	p1 := p2p.NewPeer("one", crdt.Seq{})
+	p2 := p2p.NewPeer("two", crdt.Seq{})
+	for kk := 0; kk < 4; kk++ {
+		splice(p1, kk/2, 0, strconv.Itoa(kk))
+	}
+	sync(t, p1, p2)
+
+	p1.Update(func(v changes.Value) changes.Change {
+		c, _ := v.(crdt.Seq).Compact(math.MaxInt64)
+		return c
+	})
+	splice(p2, 2, 0, "x")
+	sync(t, p1, p2)
+
+	x1, x2 := items(p1), items(p2)
+	if !reflect.DeepEqual(x1, x2) || x2[2] != "x" {
+		t.Fatal("Diverged", x1, x2)
+	}
+}
+
+func TestSyncCancelsWriter(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+
+	before := runtime.NumGoroutine()
+	go c2.Write([]byte{0, 0, 0, 1, '!'})
+	if err := p2p.NewPeer("one", crdt.Seq{}).Sync(c1); err == nil {
+		t.Fatal("Unexpected success")
+	}
+
+	for kk := 0; runtime.NumGoroutine() > before; kk++ {
+		if kk == 100 {
+			t.Fatal("Leaked goroutines", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSyncCancelsWriterWithoutDeadlines(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+
+	before := runtime.NumGoroutine()
+	go c2.Write([]byte{0, 0, 0, 1, '!'})
+	rw := struct{ io.ReadWriteCloser }{c1}
+	if err := p2p.NewPeer("one", crdt.Seq{}).Sync(rw); err == nil {
+		t.Fatal("Unexpected success")
+	}
+
+	for kk := 0; runtime.NumGoroutine() > before; kk++ {
+		if kk == 100 {
+			t.Fatal("Leaked goroutines", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUpdateNotCommutative(t *testing.T) {
+	p1 := p2p.NewPeer("one", crdt.Seq{})
+	p2 := p2p.NewPeer("two", crdt.Seq{})
+	p1.Update(func(v changes.Value) changes.Change {
+		c, _ := v.(crdt.Seq).Splice(0, 0, []interface{}{types.S8("ab")})
+		return c
+	})
+	sync(t, p1, p2)
+
+	for kk, p := range []*p2p.Peer{p1, p2} {
+		err := p.Update(func(v changes.Value) changes.Change {
+			insert := changes.Splice{Offset: kk, Before: types.S8(""), After: types.S8("x")}
+			c, _ := v.(crdt.Seq).Update(0, insert)
+			return c
+		})
+		if err != p2p.ErrNotCommutative {
+			t.Fatal("Unexpected error", err)
+		}
+	}
+	sync(t, p1, p2)
+
+	if x1, x2 := items(p1), items(p2); !reflect.DeepEqual(x1, x2) || x1[0] != types.S8("ab") {
+		t.Fatal("Unexpected items", x1, x2)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	p1 := p2p.NewPeer("one", crdt.Seq{})
+	p2 := p2p.NewPeer("two", crdt.Seq{})
+	splice(p1, 0, 0, "hello")
+	splice(p2, 0, 0, "world")
+	sync(t, p1, p2)
+
+	p1.Prune(p1.Version())
+	if x := p1.Value().(crdt.Seq).Items(); len(x) != 2 {
+		t.Fatal("Prune changed value", x)
+	}
+
+	splice(p1, 0, 0, "!")
+	sync(t, p1, p2)
+	if x1, x2 := items(p1), items(p2); len(x1) != 3 || !reflect.DeepEqual(x1, x2) {
+		t.Fatal("Diverged", x1, x2)
+	}
+
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	p3 := p2p.NewPeer("three", crdt.Seq{})
+	errs := make(chan error, 1)
+	go func() { errs <- p3.Sync(c1) }()
+	if err := p1.Sync(c2); err != p2p.ErrPruned {
+		t.Fatal("Unexpected error", err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if x := items(p3); len(x) != 0 {
+		t.Fatal("Unexpected items", x)
+	}
+}
+
+func randomEdit(r *rand.Rand, p *p2p.Peer) {
+	p.Update(func(v changes.Value) changes.Change {
+		s := v.(crdt.Seq)
+		n := len(s.Items())
+		var c changes.Change
+		switch {
+		case r.Intn(10) == 0:
+			// partitioned peers have no safe cutoff, so
+			// only rebalance
+			c, _ = s.Compact(0)
+		case n > 1 && r.Intn(4) == 0:
+			c, _ = s.Move(0, 1, r.Intn(n-1)+1)
+		case n > 0 && r.Intn(3) == 0:
+			c, _ = s.Splice(r.Intn(n), 1, nil)
+		default:
+			c, _ = s.Splice(r.Intn(n+1), 0, []interface{}{p.ID + strconv.Itoa(r.Intn(100))})
+		}
+		return c
+	})
+}
+
+func splice(p *p2p.Peer, offset, count int, insert string) {
+	p.Update(func(v changes.Value) changes.Change {
+		var replacement []interface{}
+		if insert != "" {
+			replacement = []interface{}{insert}
+		}
+		c, _ := v.(crdt.Seq).Splice(offset, count, replacement)
+		return c
+	})
+}
+
+func items(p *p2p.Peer) []interface{} {
+	return p.Value().(crdt.Seq).Items()
+}
+
+func sync(t *testing.T, p1, p2 *p2p.Peer) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	errs := make(chan error, 1)
+	go func() { errs <- p1.Sync(c1) }()
+	if err := p2.Sync(c2); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+// Package presence implements an ephemeral channel for sharing the
+// carets and selections of collaborators.
+//
+// Refs are not written into the op log. Instead each client
+// publishes its ref along with the version of the ops.Store that the
+// ref is relative to.  Receivers use Rebase to bring the refs up to
+// date with later operations.
+//
+// Entries expire unless they are published again within the TTL of
+// the Memory store, so clients that disconnect without calling
+// Remove eventually disappear.
+//
+// Each entry is owned by the client that first published it: the
+// owner is a secret (such as a random string) which must be provided
+// to publish or remove the entry.  Other clients cannot modify the
+// entry until it expires.
+//
+// Memory is typically shared with remote clients via nw.Handler and
+// nw.Client, which implements Presence. Clients poll List to fetch
+// the entries of collaborators.
+package presence
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dotchain/dot/ops"
+	"github.com/dotchain/dot/refs"
+)
+
+// Entry is the ref of a single client
+type Entry struct {
+	// Client is a unique ID for the client
+	Client string
+
+	// Version is the version of the last operation that the ref
+	// has been merged with or -1 if it refers to the initial value.
+	// The ref should not include any unacknowledged local changes.
+	Version int
+
+	// Ref is typically a refs.Caret or refs.Range
+	Ref refs.Ref
+}
+
+// ErrNotOwner is returned when publishing or removing an entry
+// owned by someone else
+var ErrNotOwner = errors.New("presence entry has a different owner")
+
+// Presence is the interface implemented by presence stores
+type Presence interface {
+	// Publish creates or updates the entry for a client. The
+	// owner must match that of the existing entry, if any.
+	Publish(ctx context.Context, owner string, e Entry) error
+
+	// Remove removes the entry for a client. The owner must match
+	// that of the entry.
+	Remove(ctx context.Context, owner, client string) error
+
+	// List returns all current entries sorted by the client ID
+	List(ctx context.Context) ([]Entry, error)
+
+	// Close releases all resources
+	Close()
+}
+
+// Memory is an in-memory presence store. Entries which have not been
+// published within the TTL are dropped.
+type Memory struct {
+	// TTL defaults to 30 seconds
+	TTL time.Duration
+
+	// Now defaults to time.Now
+	Now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]Entry
+	owners  map[string]string
+	updated map[string]time.Time
+}
+
+// Publish implements Presence.Publish
+func (m *Memory) Publish(ctx context.Context, owner string, e Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.check(owner, e.Client); err != nil {
+		return err
+	}
+
+	if m.entries == nil {
+		m.entries, m.owners = map[string]Entry{}, map[string]string{}
+		m.updated = map[string]time.Time{}
+	}
+	m.entries[e.Client] = e
+	m.owners[e.Client] = owner
+	m.updated[e.Client] = m.now()
+	return nil
+}
+
+// Remove implements Presence.Remove
+func (m *Memory) Remove(ctx context.Context, owner, client string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.check(owner, client); err != nil {
+		return err
+	}
+	m.remove(client)
+	return nil
+}
+
+// List implements Presence.List
+func (m *Memory) List(ctx context.Context) ([]Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := []Entry{}
+	for client, e := range m.entries {
+		if m.expired(client) {
+			m.remove(client)
+		} else {
+			result = append(result, e)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Client < result[j].Client
+	})
+	return result, nil
+}
+
+// Close implements Presence.Close
+func (m *Memory) Close() {}
+
+// check returns ErrNotOwner if the client has a live entry with a
+// different owner
+func (m *Memory) check(owner, client string) error {
+	if _, ok := m.entries[client]; !ok || m.expired(client) {
+		return nil
+	}
+	if m.owners[client] != owner {
+		return ErrNotOwner
+	}
+	return nil
+}
+
+func (m *Memory) expired(client string) bool {
+	ttl := m.TTL
+	if ttl == 0 {
+		ttl = 30 * time.Second
+	}
+	return m.updated[client].Before(m.now().Add(-ttl))
+}
+
+func (m *Memory) remove(client string) {
+	delete(m.entries, client)
+	delete(m.owners, client)
+	delete(m.updated, client)
+}
+
+func (m *Memory) now() time.Time {
+	if m.Now == nil {
+		return time.Now()
+	}
+	return m.Now()
+}
+
+// Rebase merges the refs with all operations in the store up to and
+// including the provided version.  Entries whose refs are nil or
+// become invalid are dropped.
+func Rebase(ctx context.Context, store ops.Store, entries []Entry, version int) ([]Entry, error) {
+	oldest := version
+	for _, e := range entries {
+		if e.Version < oldest {
+			oldest = e.Version
+		}
+	}
+
+	var list []ops.Op
+	for oldest+len(list) < version {
+		next, err := store.GetSince(ctx, oldest+len(list)+1, version-oldest-len(list))
+		if err != nil {
+			return nil, err
+		}
+		if len(next) == 0 {
+			break
+		}
+		list = append(list, next...)
+	}
+
+	result := []Entry{}
+	for _, e := range entries {
+		if e.Ref == nil {
+			continue
+		}
+		for _, op := range list {
+			if e.Ref == refs.InvalidRef {
+				break
+			}
+			if op.Version() > e.Version && op.Version() <= version {
+				e.Ref, _ = e.Ref.Merge(op.Changes())
+				e.Version = op.Version()
+			}
+		}
+		if e.Ref != refs.InvalidRef {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,133 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package presence_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/ops"
+	"github.com/dotchain/dot/ops/presence"
+	"github.com/dotchain/dot/refs"
+	"github.com/dotchain/dot/test/testops"
+)
+
+func TestMemory(t *testing.T) {
+	now := time.Now()
+	m := &presence.Memory{TTL: time.Minute, Now: func() time.Time { return now }}
+	defer m.Close()
+	ctx := context.Background()
+
+	one := presence.Entry{Client: "one", Version: -1, Ref: refs.Caret{Index: 1}}
+	two := presence.Entry{Client: "two", Version: 2, Ref: refs.Caret{Index: 2}}
+	must(t, m.Publish(ctx, "secret2", two))
+	must(t, m.Publish(ctx, "secret1", one))
+
+	entries, err := m.List(ctx)
+	if err != nil || !reflect.DeepEqual(entries, []presence.Entry{one, two}) {
+		t.Fatal("Unexpected entries", entries, err)
+	}
+
+	// republishing keeps the entry alive
+	now = now.Add(50 * time.Second)
+	two.Version = 5
+	must(t, m.Publish(ctx, "secret2", two))
+	now = now.Add(50 * time.Second)
+	entries, err = m.List(ctx)
+	if err != nil || !reflect.DeepEqual(entries, []presence.Entry{two}) {
+		t.Fatal("Unexpected entries", entries, err)
+	}
+
+	must(t, m.Remove(ctx, "secret2", "two"))
+	entries, err = m.List(ctx)
+	if err != nil || len(entries) != 0 {
+		t.Fatal("Unexpected entries", entries, err)
+	}
+}
+
+func TestMemoryOwner(t *testing.T) {
+	now := time.Now()
+	m := &presence.Memory{TTL: time.Minute, Now: func() time.Time { return now }}
+	defer m.Close()
+	ctx := context.Background()
+
+	one := presence.Entry{Client: "one", Version: 1, Ref: refs.Caret{Index: 1}}
+	must(t, m.Publish(ctx, "secret", one))
+
+	spoof := presence.Entry{Client: "one", Version: 2, Ref: refs.Caret{Index: 2}}
+	if err := m.Publish(ctx, "other", spoof); err != presence.ErrNotOwner {
+		t.Fatal("Unexpected publish", err)
+	}
+	if err := m.Remove(ctx, "other", "one"); err != presence.ErrNotOwner {
+		t.Fatal("Unexpected remove", err)
+	}
+	entries, err := m.List(ctx)
+	if err != nil || !reflect.DeepEqual(entries, []presence.Entry{one}) {
+		t.Fatal("Unexpected entries", entries, err)
+	}
+
+	// expired entries can be claimed by anyone
+	now = now.Add(2 * time.Minute)
+	must(t, m.Publish(ctx, "other", spoof))
+	if err := m.Remove(ctx, "secret", "one"); err != presence.ErrNotOwner {
+		t.Fatal("Unexpected remove", err)
+	}
+	must(t, m.Remove(ctx, "other", "one"))
+}
+
+func TestRebase(t *testing.T) {
+	insert := func(id interface{}, offset int, s string) ops.Op {
+		splice := changes.Splice{Offset: offset, Before: types.S8(""), After: types.S8(s)}
+		return ops.Operation{OpID: id, Change: splice}
+	}
+	remove := changes.Splice{Offset: 3, Before: types.S8("hello"), After: types.S8("")}
+
+	store := testops.MemStore([]ops.Op{
+		insert(0, 0, "hello"),
+		insert(1, 0, "abc"),
+		insert(2, 8, "xyz"),
+		ops.Operation{OpID: 3, Change: remove},
+	})
+	defer store.Close()
+
+	entries := []presence.Entry{
+		{Client: "one", Version: 0, Ref: refs.Caret{Index: 2}},
+		{Client: "two", Version: 1, Ref: refs.Caret{Index: 8, IsLeft: true}},
+		{Client: "three", Version: 2, Ref: refs.Path{5}},
+	}
+
+	result, err := presence.Rebase(context.Background(), store, entries, 2)
+	expected := []presence.Entry{
+		{Client: "one", Version: 2, Ref: refs.Caret{Index: 5}},
+		{Client: "two", Version: 2, Ref: refs.Caret{Index: 8, IsLeft: true}},
+		{Client: "three", Version: 2, Ref: refs.Path{5}},
+	}
+	if err != nil || !reflect.DeepEqual(result, expected) {
+		t.Fatal("Unexpected rebase", result, err)
+	}
+
+	// invalidated refs are dropped
+	result, err = presence.Rebase(context.Background(), store, entries, 3)
+	if err != nil || len(result) != 2 {
+		t.Fatal("Unexpected rebase", result, err)
+	}
+
+	// nil refs are dropped
+	entries = append(entries, presence.Entry{Client: "four", Version: 0})
+	result, err = presence.Rebase(context.Background(), store, entries, 2)
+	if err != nil || !reflect.DeepEqual(result, expected) {
+		t.Fatal("Unexpected rebase", result, err)
+	}
+}
+
+func must(t *testing.T, err error) {
+	if err != nil {
+		t.Fatal(err)
+	}
+}
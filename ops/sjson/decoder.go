@@ -170,7 +170,7 @@ func (d Decoder) decodeMap(typ reflect.Type, r *bufio.Reader) reflect.Value {
 		panic(errors.New("missing ["))
 	}
 	result := reflect.MakeMap(typ)
-	finished := false
+	finished := d.check("]", r)
 	for !finished {
 		key := d.decodeType(typ.Key(), r)
 		if !d.check(",", r) {
@@ -141,6 +141,7 @@ func TestSuccess(t *testing.T) {
 
 		// type of map
 		`{"ops/sjson_test.myMap": [[0],1]}`: myMap{&[]int{0}: 1},
+		`{"ops/sjson_test.myMap": []}`:      myMap{},
 
 		// time
 		`{"time.Time": "2006-01-02T15:04:05+07:00"}`:             epoch,
@@ -74,3 +74,11 @@ func (s stream) Next() (streams.Stream, changes.Change) {
 	}
 	return stream{s.session, next}, c
 }
+
+// Notify implements streams.Notifier
+func (s stream) Notify(fn func()) (cancel func()) {
+	if n, ok := s.Stream.(streams.Notifier); ok {
+		return n.Notify(fn)
+	}
+	return func() {}
+}
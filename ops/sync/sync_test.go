@@ -159,6 +159,27 @@ func TestSyncMismatchedVersions(t *testing.T) {
 	}
 }
 
+func TestSyncNotify(t *testing.T) {
+	store := ops.Polled(testops.MemStore(nil))
+	c1 := stream(store, -1, nil)
+	c2 := stream(store, -1, nil)
+	defer store.Close()
+
+	var watched []changes.Change
+	cancel := streams.Watch(c1, func(c changes.Change) {
+		watched = append(watched, c)
+	})
+	defer cancel()
+
+	move := changes.Move{Offset: 2, Count: 3, Distance: 4}
+	c2.Append(move)
+	must(c2.Push())
+	next(c1)
+	if !reflect.DeepEqual(watched, []changes.Change{move}) {
+		t.Fatal("Unexpected watch", watched)
+	}
+}
+
 func stream(s ops.Store, version int, pending []ops.Op) streams.Stream {
 	xformed := ops.Transformed(s, testops.NullCache())
 	l := log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile)
@@ -0,0 +1,187 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package refs
+
+import (
+	"reflect"
+
+	"github.com/dotchain/dot/changes"
+)
+
+// Anchored is a Range meant for comments and annotations: unlike
+// Range, it is never invalidated by deletions.
+//
+// When the text (or the ancestor object) containing the range is
+// deleted, the range collapses to the position of the deletion which
+// is the nearest surviving neighbor.  The range before the deletion
+// is remembered as a Ghost and is restored if the exact same content
+// is inserted back at the same spot (such as when the deletion is
+// undone via streams/undo).
+//
+// Only the most recent deletion is remembered.
+//
+// This is an immutable type.
+type Anchored struct {
+	Range
+	Ghost *Ghost
+}
+
+// Ghost tracks a range that was affected by a deletion
+type Ghost struct {
+	// At is the current position of the deletion
+	At Caret
+
+	// Removed is the content that was deleted
+	Removed changes.Collection
+
+	// Range is the range before the deletion and Offset is the
+	// offset of the deletion at that point
+	Range  Range
+	Offset int
+}
+
+// Merge updates the range based on the change.  It always returns a
+// nil change.
+func (a Anchored) Merge(c changes.Change) (Ref, changes.Change) {
+	switch c := c.(type) {
+	case changes.ChangeSet:
+		return a.mergeAll(nil, c), nil
+	case changes.PathChange:
+		switch inner := c.Change.(type) {
+		case changes.ChangeSet:
+			return a.mergeAll(c.Path, inner), nil
+		case changes.PathChange:
+			path := append(append([]interface{}(nil), c.Path...), inner.Path...)
+			return a.Merge(changes.PathChange{Path: path, Change: inner.Change})
+		}
+		return a.merge(c.Path, c.Change, c), nil
+	}
+	return a.merge(nil, c, c), nil
+}
+
+func (a Anchored) mergeAll(path []interface{}, cx changes.ChangeSet) Ref {
+	var result Ref = a
+	for _, c := range cx {
+		if result == InvalidRef {
+			break
+		}
+		result, _ = result.Merge(changes.PathChange{Path: path, Change: c})
+	}
+	return result
+}
+
+// merge merges the change c which is the same as the inner change
+// at the path
+func (a Anchored) merge(path []interface{}, inner, c changes.Change) Ref {
+	merged, _ := a.Range.Merge(c)
+	splice, _ := inner.(changes.Splice)
+
+	if a.Ghost != nil && a.Ghost.restoredBy(path, splice) {
+		r, _ := merged.(Range)
+		return Anchored{Range: a.Ghost.restore(r, splice)}
+	}
+
+	var ghost *Ghost
+	if a.deletedBy(path, splice) {
+		ghost = &Ghost{Caret{path, splice.Offset, false}, splice.Before, a.Range, splice.Offset}
+	} else if a.Ghost != nil {
+		if at, ok := a.Ghost.At.tryMerge(c); ok {
+			ghost = &Ghost{at, a.Ghost.Removed, a.Ghost.Range, a.Ghost.Offset}
+		}
+	}
+
+	if r, ok := merged.(Range); ok {
+		return Anchored{r, ghost}
+	}
+
+	// re-anchor to the nearest surviving ancestor
+	p := a.Start.Path
+	for kk := len(p) - 1; kk >= 0; kk-- {
+		idx, ok := p[kk].(int)
+		if !ok {
+			continue
+		}
+		if caret, ok := (Caret{p[:kk], idx, false}).tryMerge(c); ok {
+			return Anchored{Range{caret, caret}, ghost}
+		}
+	}
+	return InvalidRef
+}
+
+// deletedBy checks if the splice at the path removes any part of
+// the range
+func (a Anchored) deletedBy(path []interface{}, splice changes.Splice) bool {
+	if splice.Before == nil || splice.Before.Count() == 0 || len(path) > len(a.Start.Path) {
+		return false
+	}
+	if !a.Start.Path[:len(path)].Equal(Path(path)) {
+		return false
+	}
+
+	end := splice.Offset + splice.Before.Count()
+	if len(path) < len(a.Start.Path) {
+		idx, ok := a.Start.Path[len(path)].(int)
+		return ok && idx >= splice.Offset && idx < end
+	}
+
+	start, stop := a.Start.Index, a.End.Index
+	if start > stop {
+		start, stop = stop, start
+	}
+	if start == stop {
+		return start > splice.Offset && start < end
+	}
+	return start < end && stop > splice.Offset
+}
+
+func (g *Ghost) restoredBy(path []interface{}, splice changes.Splice) bool {
+	return g.At.Path.Equal(Path(path)) &&
+		splice.Offset == g.At.Index &&
+		splice.Before != nil && splice.Before.Count() == 0 &&
+		reflect.DeepEqual(splice.After, g.Removed)
+}
+
+// restore returns the original range given the current range and
+// the splice that restored the deleted content
+func (g *Ghost) restore(current Range, splice changes.Splice) Range {
+	level, shift := len(g.At.Path), splice.Offset-g.Offset
+	orig := g.Range
+	if len(orig.Start.Path) > level {
+		p := append(Path(nil), orig.Start.Path...)
+		p[level] = p[level].(int) + shift
+		return Range{Caret{p, orig.Start.Index, orig.Start.IsLeft}, Caret{p, orig.End.Index, orig.End.IsLeft}}
+	}
+
+	end := g.Offset + g.Removed.Count()
+	fix := func(orig, current Caret) Caret {
+		if orig.Index >= g.Offset && orig.Index <= end {
+			return Caret{g.At.Path, orig.Index + shift, orig.IsLeft}
+		}
+		return current
+	}
+	return Range{fix(orig.Start, current.Start), fix(orig.End, current.End)}
+}
+
+// Equal implements Ref.Equal
+func (a Anchored) Equal(other Ref) bool {
+	o, ok := other.(Anchored)
+	if !ok || !a.Range.Equal(o.Range) || (a.Ghost == nil) != (o.Ghost == nil) {
+		return false
+	}
+	return a.Ghost == nil || a.Ghost.equal(o.Ghost)
+}
+
+func (g *Ghost) equal(o *Ghost) bool {
+	return g.At.Equal(o.At) && g.Range.Equal(o.Range) && g.Offset == o.Offset &&
+		reflect.DeepEqual(g.Removed, o.Removed)
+}
+
+// tryMerge merges the caret returning false if the caret is
+// no longer valid
+func (caret Caret) tryMerge(c changes.Change) (Caret, bool) {
+	r, _ := caret.Merge(c)
+	result, ok := r.(Caret)
+	return result, ok
+}
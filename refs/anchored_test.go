@@ -0,0 +1,119 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package refs_test
+
+import (
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/refs"
+	"github.com/dotchain/dot/streams"
+	"github.com/dotchain/dot/streams/undo"
+)
+
+func TestAnchoredDelete(t *testing.T) {
+	r := refs.Range{Start: refs.Caret{Index: 3}, End: refs.Caret{Index: 6}}
+	a := refs.Anchored{Range: r}
+
+	// unrelated edits behave like Range
+	insert := changes.Splice{Offset: 0, Before: types.S8(""), After: types.S8("OK")}
+	x, cx := a.Merge(insert)
+	expected, _ := r.Merge(insert)
+	if !x.Equal(refs.Anchored{Range: expected.(refs.Range)}) || cx != nil {
+		t.Fatal("Unexpected merge", x, cx)
+	}
+
+	// deletes collapse the range
+	remove := changes.Splice{Offset: 2, Before: types.S8("abcde"), After: types.S8("")}
+	x, _ = a.Merge(remove)
+	collapsed := refs.Range{Start: refs.Caret{Index: 2}, End: refs.Caret{Index: 2}}
+	if ax := x.(refs.Anchored); !ax.Range.Equal(collapsed) || ax.Ghost == nil {
+		t.Fatal("Unexpected merge", x)
+	}
+
+	// inserting the same content elsewhere does not restore
+	if y, _ := x.Merge(changes.Splice{Offset: 0, Before: types.S8(""), After: types.S8("abcde")}); y.(refs.Anchored).Start.Index != 7 {
+		t.Fatal("Unexpected merge", y)
+	}
+
+	// reverting the delete after other edits restores the range
+	x, _ = x.Merge(insert)
+	x, _ = x.Merge(changes.Splice{Offset: 4, Before: types.S8(""), After: types.S8("abcde")})
+	expected = refs.Range{Start: refs.Caret{Index: 5}, End: refs.Caret{Index: 8}}
+	if !x.Equal(refs.Anchored{Range: expected.(refs.Range)}) {
+		t.Fatal("Unexpected restore", x)
+	}
+}
+
+func TestAnchoredPartialDelete(t *testing.T) {
+	a := refs.Anchored{Range: refs.Range{Start: refs.Caret{Index: 1}, End: refs.Caret{Index: 4}}}
+	remove := changes.Splice{Offset: 3, Before: types.S8("abcd"), After: types.S8("")}
+	x, _ := a.Merge(remove)
+	x, _ = x.Merge(remove.Revert())
+	if !x.Equal(a) {
+		t.Fatal("Unexpected restore", x)
+	}
+
+	// collapsed ranges at the edge of the delete are unaffected
+	a = refs.Anchored{Range: refs.Range{Start: refs.Caret{Index: 3}, End: refs.Caret{Index: 3}}}
+	if x, _ := a.Merge(remove); !x.Equal(a) {
+		t.Fatal("Unexpected merge", x)
+	}
+}
+
+func TestAnchoredNested(t *testing.T) {
+	p := refs.Path{"items", 2}
+	a := refs.Anchored{Range: refs.Range{Start: refs.Caret{Path: p, Index: 1}, End: refs.Caret{Path: p, Index: 2}}}
+
+	remove := changes.PathChange{
+		Path:   []interface{}{"items"},
+		Change: changes.Splice{Offset: 1, Before: types.A{types.S8("x"), types.S8("abc")}, After: types.A{}},
+	}
+	x, _ := a.Merge(remove)
+	collapsed := refs.Caret{Path: refs.Path{"items"}, Index: 1}
+	if ax := x.(refs.Anchored); !ax.Range.Equal(refs.Range{Start: collapsed, End: collapsed}) {
+		t.Fatal("Unexpected merge", x)
+	}
+
+	// deleting the parent of the range invalidates it
+	replace := changes.Replace{Before: types.A{}, After: types.S8("")}
+	if y, _ := x.Merge(replace); y != refs.InvalidRef {
+		t.Fatal("Unexpected merge", y)
+	}
+
+	// changes.ChangeSet works with nested paths
+	insert := changes.Splice{Offset: 0, Before: types.A{}, After: types.A{types.S8("")}}
+	restore := changes.ChangeSet{
+		changes.PathChange{Path: []interface{}{"items"}, Change: insert},
+		changes.PathChange{
+			Path:   []interface{}{"items"},
+			Change: changes.Splice{Offset: 2, Before: types.A{}, After: types.A{types.S8("x"), types.S8("abc")}},
+		},
+	}
+	x, _ = x.Merge(changes.PathChange{Path: nil, Change: restore})
+	p = refs.Path{"items", 3}
+	expected := refs.Anchored{Range: refs.Range{Start: refs.Caret{Path: p, Index: 1}, End: refs.Caret{Path: p, Index: 2}}}
+	if !x.Equal(expected) {
+		t.Fatal("Unexpected restore", x)
+	}
+}
+
+func TestAnchoredUndo(t *testing.T) {
+	var ref refs.Ref = refs.Anchored{Range: refs.Range{Start: refs.Caret{Index: 2}, End: refs.Caret{Index: 4}}}
+	initial := undo.New(streams.New())
+
+	s := initial.Append(changes.Splice{Offset: 1, Before: types.S8("cde"), After: types.S8("")})
+	s = s.Append(changes.Splice{Offset: 0, Before: types.S8(""), After: types.S8("hello")})
+	s.Undo()
+	s.Undo()
+
+	for next, c := initial.Next(); next != nil; next, c = next.Next() {
+		ref, _ = ref.Merge(c)
+	}
+	if !ref.Equal(refs.Anchored{Range: refs.Range{Start: refs.Caret{Index: 2}, End: refs.Caret{Index: 4}}}) {
+		t.Error("Unexpected undo", ref)
+	}
+}
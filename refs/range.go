@@ -23,7 +23,8 @@ import "github.com/dotchain/dot/changes"
 // called  on the updated Caret (based on the path returned by
 // MergePath).
 //
-// Note that the paths for the Start and End are expected to be the same.
+// Note that the paths for the Start and End are expected to be the
+// same. Use Selection for ranges that span different paths.
 type Range struct {
 	Start, End Caret
 }
@@ -0,0 +1,119 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package refs
+
+import "github.com/dotchain/dot/changes"
+
+// Selection is like Range except Start and End can have different
+// paths, such as when a selection spans multiple paragraphs.
+//
+// Start is always before End in document order: paths are compared
+// element by element (with integers and strings compared by value)
+// and shorter paths come first if one path is a prefix of the other.
+// If a change reorders the ends, Merge swaps them and flips
+// Backward.
+//
+// Backward is true if the selection was made from End to Start, i.e.
+// the focus is at Start instead of End.
+//
+// This is an immutable type.
+type Selection struct {
+	Start, End Caret
+	Backward   bool
+}
+
+// NewSelection creates a selection from the anchor and focus
+func NewSelection(anchor, focus Caret) Selection {
+	if compareCarets(focus, anchor) < 0 {
+		return Selection{focus, anchor, true}
+	}
+	return Selection{anchor, focus, false}
+}
+
+// Anchor returns the end where the selection started
+func (s Selection) Anchor() Caret {
+	if s.Backward {
+		return s.End
+	}
+	return s.Start
+}
+
+// Focus returns the end where the selection currently is
+func (s Selection) Focus() Caret {
+	if s.Backward {
+		return s.Start
+	}
+	return s.End
+}
+
+// Merge updates both ends of the selection independently. If one of
+// the ends becomes invalid, the selection collapses to the other
+// end. It always returns a nil change.
+func (s Selection) Merge(c changes.Change) (Ref, changes.Change) {
+	sx, _ := s.Start.Merge(c)
+	ex, _ := s.End.Merge(c)
+	start, ok1 := sx.(Caret)
+	end, ok2 := ex.(Caret)
+	switch {
+	case !ok1 && !ok2:
+		return InvalidRef, nil
+	case !ok1:
+		start = end
+	case !ok2:
+		end = start
+	}
+
+	if compareCarets(end, start) < 0 {
+		return Selection{end, start, !s.Backward}, nil
+	}
+	return Selection{start, end, s.Backward}, nil
+}
+
+// Equal implements Ref.Equal
+func (s Selection) Equal(other Ref) bool {
+	o, ok := other.(Selection)
+	return ok && s.Start.Equal(o.Start) && s.End.Equal(o.End) && s.Backward == o.Backward
+}
+
+// compareCarets returns -1, 0 or 1 depending on the document order
+func compareCarets(c1, c2 Caret) int {
+	p1 := append(append([]interface{}(nil), c1.Path...), c1.Index)
+	p2 := append(append([]interface{}(nil), c2.Path...), c2.Index)
+	for kk := 0; kk < len(p1) && kk < len(p2); kk++ {
+		if result := compareKeys(p1[kk], p2[kk]); result != 0 {
+			return result
+		}
+	}
+	return compareInts(len(p1), len(p2))
+}
+
+// compareKeys compares integers and strings. Other keys are
+// considered equal.
+func compareKeys(k1, k2 interface{}) int {
+	switch k1 := k1.(type) {
+	case int:
+		if k2, ok := k2.(int); ok {
+			return compareInts(k1, k2)
+		}
+	case string:
+		if k2, ok := k2.(string); ok && k1 != k2 {
+			if k1 < k2 {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func compareInts(i1, i2 int) int {
+	switch {
+	case i1 < i2:
+		return -1
+	case i1 > i2:
+		return 1
+	}
+	return 0
+}
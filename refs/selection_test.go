@@ -0,0 +1,86 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package refs_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/refs"
+)
+
+func TestSelectionEqual(t *testing.T) {
+	s := refs.NewSelection(refs.Caret{refs.Path{1}, 2, false}, refs.Caret{refs.Path{0}, 5, false})
+	if !s.Backward || s.Start.Index != 5 || s.Anchor().Index != 2 || s.Focus().Index != 5 {
+		t.Fatal("Unexpected selection", s)
+	}
+	if !s.Equal(s) || s.Equal(refs.Range{s.Start, s.End}) {
+		t.Error("Unexpected equal")
+	}
+
+	flipped := s
+	flipped.Backward = false
+	if s.Equal(flipped) {
+		t.Error("Backward not compared")
+	}
+}
+
+func TestSelectionOrder(t *testing.T) {
+	caret := func(idx int, path ...interface{}) refs.Caret {
+		return refs.Caret{Path: refs.Path(path), Index: idx}
+	}
+	ordered := [][2]refs.Caret{
+		{caret(1), caret(2)},
+		{caret(2), caret(5, 2)},
+		{caret(5, 2), caret(3, 3)},
+		{caret(5, 2, "a"), caret(0, 2, "b")},
+	}
+	for _, pair := range ordered {
+		s := refs.NewSelection(pair[0], pair[1])
+		if s.Backward || !s.Start.Equal(pair[0]) {
+			t.Error("Unexpected order", pair)
+		}
+		s = refs.NewSelection(pair[1], pair[0])
+		if !s.Backward || !s.Start.Equal(pair[0]) {
+			t.Error("Unexpected order", pair)
+		}
+	}
+}
+
+func TestSelectionMerge(t *testing.T) {
+	s := refs.NewSelection(refs.Caret{refs.Path{1}, 2, false}, refs.Caret{refs.Path{3}, 5, false})
+
+	// edits within a paragraph only affect the ends in it
+	splice := changes.Splice{Offset: 0, Before: types.S8(""), After: types.S8("OK")}
+	x, cx := s.Merge(changes.PathChange{Path: []interface{}{3}, Change: splice})
+	expected := refs.NewSelection(refs.Caret{refs.Path{1}, 2, false}, refs.Caret{refs.Path{3}, 7, false})
+	if !reflect.DeepEqual(x, expected) || cx != nil {
+		t.Error("Unexpected merge", x, cx)
+	}
+
+	// moves reorder the ends
+	move := changes.Move{Offset: 3, Count: 1, Distance: -3}
+	x, _ = s.Merge(move)
+	expected = refs.NewSelection(refs.Caret{refs.Path{2}, 2, false}, refs.Caret{refs.Path{0}, 5, false})
+	if !reflect.DeepEqual(x, expected) || !expected.Backward {
+		t.Error("Unexpected merge", x)
+	}
+
+	// deleting one end collapses the selection
+	remove := changes.Splice{Offset: 3, Before: types.A{nil}, After: types.A{}}
+	x, _ = s.Merge(remove)
+	expected = refs.Selection{Start: s.Start, End: s.Start}
+	if !reflect.DeepEqual(x, expected) {
+		t.Error("Unexpected merge", x)
+	}
+
+	// replacing everything invalidates the selection
+	replace := changes.Replace{Before: types.A{}, After: types.S8("")}
+	if x, _ = s.Merge(replace); x != refs.InvalidRef {
+		t.Error("Unexpected merge", x)
+	}
+}
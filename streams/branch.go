@@ -4,18 +4,57 @@
 
 package streams
 
-import "github.com/dotchain/dot/changes"
+import (
+	"errors"
+	"strconv"
+
+	"github.com/dotchain/dot/changes"
+)
 
 // Branch returns a new stream based on the provided stream. All
 // changes made on the branch are only merged upstream when Push
 // is called explicitly and all changes made upstream are only
 // brought into the local branch when Pull is called explicitly.
+//
+// The returned stream also implements Branched.
 func Branch(upstream Stream) Stream {
 	downstream := New()
-	b := &branchInfo{upstream, downstream, false}
+	b := &branchInfo{up: upstream, down: downstream}
 	return branch{b, downstream}
 }
 
+// Branched is implemented by the streams returned by Branch and
+// allows managing the changes that have not been pushed yet.
+type Branched interface {
+	Stream
+
+	// Pending returns the changes on the branch that have not
+	// been pushed upstream.  The changes are in order and each
+	// change applies on top of the previous one.
+	Pending() []changes.Change
+
+	// PushSome pushes only the pending changes at the provided
+	// indices (of Pending()). The other pending changes remain
+	// pending, transformed so that they apply after the pushed
+	// changes.
+	//
+	// Nothing is pushed if an index is out of range, if a
+	// selected change depends on an unselected one (i.e. it
+	// cannot be applied without it) or if it is called while
+	// the branch is already being merged (such as from an
+	// upstream notification).  An error is returned instead.
+	PushSome(indices ...int) error
+
+	// Discard reverts all the pending changes on the branch and
+	// then pulls in any upstream changes.  Like PushSome, it
+	// fails if the branch is already being merged.
+	Discard() error
+
+	// Preview returns the combined change that Push would apply
+	// to the latest upstream stream.
+	Preview() changes.Change
+}
+
 type branch struct {
 	info *branchInfo
 	s    Stream
@@ -29,6 +68,22 @@ func (b branch) Pull() error {
 	return b.info.pull()
 }
 
+func (b branch) Pending() []changes.Change {
+	return b.info.getPending()
+}
+
+func (b branch) PushSome(indices ...int) error {
+	return b.info.pushSome(indices)
+}
+
+func (b branch) Discard() error {
+	return b.info.discard()
+}
+
+func (b branch) Preview() changes.Change {
+	return b.info.preview()
+}
+
 func (b branch) Undo() {
 }
 
@@ -51,36 +106,178 @@ func (b branch) Next() (Stream, changes.Change) {
 	return s, c
 }
 
+// Notify implements Notifier. Only changes to the branch are
+// notified: upstream changes are notified after a Pull.
+func (b branch) Notify(fn func()) (cancel func()) {
+	return notify(b.s, fn)
+}
+
+// branchInfo tracks the last synchronized up and down streams.
+//
+// The pending field holds changes that are already on the down
+// stream but which were left behind by PushSome. The state of down
+// is always that of up with the pending changes applied.
 type branchInfo struct {
 	up, down Stream
 	merging  bool
+	pending  []changes.Change
 }
 
 func (b *branchInfo) push() error {
-	b.down, b.up = b.merge(b.down, b.up, false)
-	return nil
+	return b.guard(b.pushAll, nil)
 }
 
 func (b *branchInfo) pull() error {
-	b.up, b.down = b.merge(b.up, b.down, true)
+	return b.guard(b.pullAll, nil)
+}
+
+func (b *branchInfo) pushSome(indices []int) error {
+	return b.guard(func() error { return b.pushSelected(indices) }, errMerging)
+}
+
+func (b *branchInfo) discard() error {
+	return b.guard(b.discardAll, errMerging)
+}
+
+var errMerging = errors.New("streams: branch merge in progress")
+
+// guard calls fn unless a merge is already in progress, in which
+// case it returns busy.  This protects against re-entrant calls
+// (such as a Pull from within an upstream notification caused by a
+// Push).
+func (b *branchInfo) guard(fn func() error, busy error) error {
+	if b.merging {
+		return busy
+	}
+	b.merging = true
+	defer func() { b.merging = false }()
+	return fn()
+}
+
+func (b *branchInfo) pushAll() error {
+	if len(b.pending) == 0 {
+		b.down, b.up = b.merge(b.down, b.up, false)
+		return nil
+	}
+
+	all := make([]int, len(b.getPending()))
+	for kk := range all {
+		all[kk] = kk
+	}
+	return b.pushSelected(all)
+}
+
+func (b *branchInfo) pullAll() error {
+	if len(b.pending) == 0 {
+		b.up, b.down = b.merge(b.up, b.down, true)
+		return nil
+	}
+
+	for next, c := b.up.Next(); next != nil; next, c = b.up.Next() {
+		b.up = next
+		for kk := range b.pending {
+			if c != nil && b.pending[kk] != nil {
+				b.pending[kk], c = c.Merge(b.pending[kk])
+			}
+		}
+		if c != nil {
+			b.down = b.down.ReverseAppend(c)
+		}
+	}
 	return nil
 }
 
-func (b *branchInfo) merge(from, to Stream, reverse bool) (fromx, tox Stream) {
-	if !b.merging {
-		b.merging = true
-		next, c := from.Next()
-		for next != nil {
-			if reverse {
-				to = to.ReverseAppend(c)
-			} else {
-				to = to.Append(c)
+func (b *branchInfo) getPending() []changes.Change {
+	result := append([]changes.Change(nil), b.pending...)
+	for next, c := b.down.Next(); next != nil; next, c = next.Next() {
+		if c != nil {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (b *branchInfo) pushSelected(indices []int) error {
+	pending := b.getPending()
+	selected := map[int]bool{}
+	for _, idx := range indices {
+		if idx < 0 || idx >= len(pending) {
+			return errors.New("streams: invalid pending index " + strconv.Itoa(idx))
+		}
+		selected[idx] = true
+	}
+
+	// reorder the pending changes so that the selected changes
+	// come first.  When an unselected change u is followed by a
+	// selected change s, the pair is replaced with s' and u'
+	// where s' applies before u.  If either s or u does not
+	// survive this (such as when s modifies or overwrites the
+	// effect of u), s depends on u.
+	var push, keep []changes.Change
+	for kk, c := range pending {
+		if !selected[kk] {
+			keep = append(keep, c)
+			continue
+		}
+		for jj := len(keep) - 1; jj >= 0; jj-- {
+			if keep[jj] == nil {
+				continue
+			}
+			var undo changes.Change
+			c, undo = keep[jj].Revert().Merge(c)
+			if c == nil || undo == nil {
+				return errors.New("streams: pending change " + strconv.Itoa(kk) + " depends on an unselected change")
 			}
-			from = next
-			next, c = from.Next()
+			keep[jj] = undo.Revert()
 		}
-		b.merging = false
+		push = append(push, c)
 	}
-	return from, to
 
+	for _, c := range push {
+		if c != nil {
+			b.up = b.up.Append(c)
+		}
+	}
+	b.down, _ = Latest(b.down)
+	b.pending = nil
+	for _, c := range keep {
+		if c != nil {
+			b.pending = append(b.pending, c)
+		}
+	}
+	return nil
+}
+
+func (b *branchInfo) discardAll() error {
+	pending := b.getPending()
+	down, _ := Latest(b.down)
+	for kk := len(pending) - 1; kk >= 0; kk-- {
+		down = down.Append(pending[kk].Revert())
+	}
+	b.down, b.pending = down, nil
+	return b.pullAll()
+}
+
+func (b *branchInfo) preview() changes.Change {
+	c := changes.Change(changes.ChangeSet(b.getPending()))
+	for next, x := b.up.Next(); next != nil && c != nil; next, x = next.Next() {
+		if x != nil {
+			c, _ = x.Merge(c)
+		}
+	}
+	return changes.Simplify(c)
+}
+
+func (b *branchInfo) merge(from, to Stream, reverse bool) (fromx, tox Stream) {
+	next, c := from.Next()
+	for next != nil {
+		if reverse {
+			to = to.ReverseAppend(c)
+		} else {
+			to = to.Append(c)
+		}
+		from = next
+		next, c = from.Next()
+	}
+	return from, to
 }
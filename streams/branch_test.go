@@ -5,6 +5,7 @@
 package streams_test
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/dotchain/dot/changes"
@@ -118,3 +119,180 @@ func TestBranchNilChange(t *testing.T) {
 		t.Fatal("Failed merging nil changes", v)
 	}
 }
+
+func TestBranchPending(t *testing.T) {
+	up := streams.New()
+	b := streams.Branch(up)
+	insert := func(s streams.Stream, offset int, text string) {
+		latest, _ := streams.Latest(s)
+		latest.Append(changes.Splice{Offset: offset, Before: S(""), After: S(text)})
+	}
+	value := func(s streams.Stream) changes.Value {
+		var v changes.Value = S("")
+		for next, c := s.Next(); next != nil; next, c = next.Next() {
+			v = v.Apply(nil, c)
+		}
+		return v
+	}
+
+	insert(b, 0, "a")
+	insert(b, 1, "b")
+	insert(b, 2, "c")
+	insert(up, 0, "x")
+
+	branched := b.(streams.Branched)
+	if x := branched.Pending(); len(x) != 3 {
+		t.Fatal("Unexpected pending", x)
+	}
+	if x := S("x").Apply(nil, branched.Preview()); x != S("xabc") {
+		t.Fatal("Unexpected preview", x)
+	}
+
+	// push only "b" and "c"
+	if err := branched.PushSome(1, 2); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if x := value(up); x != S("xbc") {
+		t.Fatal("Unexpected upstream", x)
+	}
+	expected := []changes.Change{changes.Splice{Offset: 0, Before: S(""), After: S("a")}}
+	if x := branched.Pending(); !reflect.DeepEqual(x, expected) {
+		t.Fatal("Unexpected pending", x)
+	}
+
+	// pull with pending changes left behind
+	insert(up, 0, "y")
+	insert(b, 3, "d")
+	if err := branched.Pull(); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if x := value(b); x != S("yxabcd") {
+		t.Fatal("Unexpected downstream", x)
+	}
+
+	if err := branched.Push(); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if x := value(up); x != S("yxabcd") {
+		t.Fatal("Unexpected upstream", x)
+	}
+	if x := branched.Pending(); len(x) != 0 {
+		t.Fatal("Unexpected pending", x)
+	}
+
+	// discard
+	insert(b, 0, "z")
+	insert(up, 0, "w")
+	branched.PushSome()
+	if err := branched.Discard(); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	if x := value(b); x != S("wyxabcd") {
+		t.Fatal("Unexpected downstream", x)
+	}
+	if x := branched.Preview(); x != nil {
+		t.Fatal("Unexpected preview", x)
+	}
+}
+
+func TestBranchPushSomeErrors(t *testing.T) {
+	up := streams.New()
+	b := streams.Branch(up)
+	b = b.Append(changes.Splice{Offset: 0, Before: S(""), After: S("abc")})
+	b.Append(changes.Splice{Offset: 1, Before: S("b"), After: S("B")})
+
+	branched := b.(streams.Branched)
+	for _, idx := range []int{-1, 2} {
+		if err := branched.PushSome(0, idx); err == nil {
+			t.Fatal("Unexpected success", idx)
+		}
+	}
+
+	// the second change updates the text inserted by the first
+	if err := branched.PushSome(1); err == nil {
+		t.Fatal("Unexpected success")
+	}
+
+	if x, _ := up.Next(); x != nil {
+		t.Fatal("Unexpected push")
+	}
+	if x := branched.Pending(); len(x) != 2 {
+		t.Fatal("Unexpected pending", x)
+	}
+}
+
+func TestBranchPushSomeReplaced(t *testing.T) {
+	up := streams.New()
+	b := streams.Branch(up)
+	b = b.Append(changes.Replace{Before: S("a"), After: S("b")})
+	b.Append(changes.Replace{Before: S("b"), After: S("c")})
+
+	// the second replace overwrites the first
+	branched := b.(streams.Branched)
+	if err := branched.PushSome(1); err == nil {
+		t.Fatal("Unexpected success")
+	}
+	if x, _ := up.Next(); x != nil {
+		t.Fatal("Unexpected push")
+	}
+	if x := branched.Pending(); len(x) != 2 {
+		t.Fatal("Unexpected pending", x)
+	}
+}
+
+func TestBranchReentrantPull(t *testing.T) {
+	up := streams.New()
+	initial := streams.Branch(up)
+	b := initial.Append(changes.Splice{Offset: 0, Before: S(""), After: S("abc")})
+	b.Append(changes.Splice{Offset: 0, Before: S(""), After: S("xyz")})
+	branched := b.(streams.Branched)
+
+	// pulling from within the upstream notification is a no-op
+	cancel := up.(streams.Notifier).Notify(func() {
+		if err := branched.Pull(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	defer cancel()
+
+	if err := branched.PushSome(1); err != nil {
+		t.Fatal(err)
+	}
+	expected := []changes.Change{changes.Splice{Offset: 3, Before: S(""), After: S("abc")}}
+	if x := branched.Pending(); !reflect.DeepEqual(x, expected) {
+		t.Fatal("Unexpected pending", x)
+	}
+
+	var v changes.Value = S("")
+	for next, c := initial.Next(); next != nil; next, c = next.Next() {
+		v = v.Apply(nil, c)
+	}
+	if v != S("xyzabc") {
+		t.Fatal("Unexpected value", v)
+	}
+}
+
+func TestBranchReentrantPushSome(t *testing.T) {
+	up := streams.New()
+	b := streams.Branch(up)
+	b = b.Append(changes.Splice{Offset: 0, Before: S(""), After: S("abc")})
+	b.Append(changes.Splice{Offset: 0, Before: S(""), After: S("xyz")})
+	branched := b.(streams.Branched)
+
+	// PushSome and Discard fail from within the upstream notification
+	var errs []error
+	cancel := up.(streams.Notifier).Notify(func() {
+		errs = append(errs, branched.PushSome(0), branched.Discard())
+	})
+	defer cancel()
+
+	if err := branched.PushSome(1); err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 2 || errs[0] == nil || errs[1] == nil {
+		t.Fatal("Unexpected errors", errs)
+	}
+	if x := branched.Pending(); len(x) != 1 {
+		t.Fatal("Unexpected pending", x)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package streams
+
+import (
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+)
+
+// Counter64 implements a 64-bit counter stream.
+type Counter64 struct {
+	Stream Stream
+	Value  int64
+}
+
+// Next returns the next if there is one.
+func (c *Counter64) Next() (*Counter64, changes.Change) {
+	if c.Stream == nil {
+		return nil, nil
+	}
+
+	next, nextc := c.Stream.Next()
+	if next == nil {
+		return nil, nil
+	}
+
+	v := c.Value
+	val, ok := (types.Counter64(v)).Apply(nil, nextc).(types.Counter64)
+	if ok {
+		v = int64(val)
+	} else {
+		next = nil
+		nextc = nil
+	}
+	return &Counter64{Stream: next, Value: v}, nextc
+}
+
+// Latest returns the latest non-nil entry in the stream
+func (c *Counter64) Latest() *Counter64 {
+	for next, _ := c.Next(); next != nil; next, _ = c.Next() {
+		c = next
+	}
+	return c
+}
+
+// Update replaces the current value with the new value
+func (c *Counter64) Update(val int64) *Counter64 {
+	before, after := types.Counter64(c.Value), types.Counter64(val)
+
+	if c.Stream != nil {
+		nexts := c.Stream.Append(changes.Replace{Before: before, After: after})
+		c = &Counter64{Stream: nexts, Value: val}
+	}
+	return c
+}
+
+// Increment by specified amount
+func (c *Counter64) Increment(by int64) *Counter64 {
+	if c.Stream != nil {
+		nexts := c.Stream.Append(types.Counter64(c.Value).Increment(by))
+		c = &Counter64{Stream: nexts, Value: c.Value + by}
+	}
+	return c
+}
+
+// FloatCounter implements a float64 counter stream.
+type FloatCounter struct {
+	Stream Stream
+	Value  float64
+}
+
+// Next returns the next if there is one.
+func (c *FloatCounter) Next() (*FloatCounter, changes.Change) {
+	if c.Stream == nil {
+		return nil, nil
+	}
+
+	next, nextc := c.Stream.Next()
+	if next == nil {
+		return nil, nil
+	}
+
+	v := c.Value
+	val, ok := (types.FloatCounter(v)).Apply(nil, nextc).(types.FloatCounter)
+	if ok {
+		v = float64(val)
+	} else {
+		next = nil
+		nextc = nil
+	}
+	return &FloatCounter{Stream: next, Value: v}, nextc
+}
+
+// Latest returns the latest non-nil entry in the stream
+func (c *FloatCounter) Latest() *FloatCounter {
+	for next, _ := c.Next(); next != nil; next, _ = c.Next() {
+		c = next
+	}
+	return c
+}
+
+// Update replaces the current value with the new value
+func (c *FloatCounter) Update(val float64) *FloatCounter {
+	before, after := types.FloatCounter(c.Value), types.FloatCounter(val)
+
+	if c.Stream != nil {
+		nexts := c.Stream.Append(changes.Replace{Before: before, After: after})
+		c = &FloatCounter{Stream: nexts, Value: val}
+	}
+	return c
+}
+
+// Increment by specified amount
+func (c *FloatCounter) Increment(by float64) *FloatCounter {
+	if c.Stream != nil {
+		nexts := c.Stream.Append(types.FloatCounter(c.Value).Increment(by))
+		c = &FloatCounter{Stream: nexts, Value: c.Value + by}
+	}
+	return c
+}
@@ -0,0 +1,68 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package streams_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/streams"
+)
+
+func TestCounter64Stream(t *testing.T) {
+	s := streams.New()
+	strong := &streams.Counter64{Stream: s, Value: 5}
+
+	strong = strong.Update(1 << 40)
+	s, c := s.Next()
+	if !reflect.DeepEqual(c, changes.Replace{Before: types.Counter64(5), After: types.Counter64(1 << 40)}) {
+		t.Error("Unexpected change on main stream", c)
+	}
+
+	other := &streams.Counter64{Stream: s, Value: 1 << 40}
+	other.Increment(-3)
+	strong = strong.Increment(5).Latest()
+	if strong.Value != 1<<40+2 {
+		t.Error("Unexpected value", strong.Value)
+	}
+
+	s.Append(changes.Replace{Before: types.Counter64(1 << 40), After: changes.Nil})
+	if strong, c = strong.Next(); c != nil {
+		t.Error("Unexpected change on counter stream", c, strong)
+	}
+
+	if x := (&streams.Counter64{}).Increment(1).Update(2); x.Value != 0 {
+		t.Error("Unexpected update on nil stream", x)
+	}
+}
+
+func TestFloatCounterStream(t *testing.T) {
+	s := streams.New()
+	strong := &streams.FloatCounter{Stream: s, Value: 5}
+
+	strong = strong.Update(1.5)
+	s, c := s.Next()
+	if !reflect.DeepEqual(c, changes.Replace{Before: types.FloatCounter(5), After: types.FloatCounter(1.5)}) {
+		t.Error("Unexpected change on main stream", c)
+	}
+
+	other := &streams.FloatCounter{Stream: s, Value: 1.5}
+	other.Increment(-0.25)
+	strong = strong.Increment(0.5).Latest()
+	if strong.Value != 1.75 {
+		t.Error("Unexpected value", strong.Value)
+	}
+
+	s.Append(changes.Replace{Before: types.FloatCounter(1.5), After: changes.Nil})
+	if strong, c = strong.Next(); c != nil {
+		t.Error("Unexpected change on counter stream", c, strong)
+	}
+
+	if x := (&streams.FloatCounter{}).Increment(1).Update(2); x.Value != 0 {
+		t.Error("Unexpected update on nil stream", x)
+	}
+}
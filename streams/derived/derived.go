@@ -0,0 +1,313 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+// Package derived implements read-only streams computed from other
+// streams.
+//
+// A derived stream tracks the values of its base streams and
+// computes a derived value from them (such as a filtered or sorted
+// list).  The changes on the derived stream are the changes to the
+// derived value.  Filter and Sort compute these incrementally where
+// possible (such as when an item of a filtered list is updated) with
+// changes/diff used as the fallback.  Map and Join always use
+// changes/diff as the derivation functions are opaque.
+//
+// Derived streams are read-only: Append and ReverseAppend have no
+// effect. Push and Pull are passed to the base streams.
+package derived
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/diff"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/streams"
+)
+
+// Stream is a read-only stream whose Value is derived from other
+// streams.
+type Stream interface {
+	streams.Stream
+
+	// Value returns the derived value for this instance.
+	Value() changes.Value
+}
+
+// Map returns a stream of fn(v) where v is the value of the base
+// stream.
+//
+// The changes of the stream are not incremental: every change of the
+// base stream recomputes fn and diffs the result against the previous
+// value.
+func Map(s streams.Stream, v changes.Value, fn func(v changes.Value) changes.Value) Stream {
+	return newDerived([]streams.Stream{s}, []changes.Value{v}, func(values []changes.Value) changes.Value {
+		return fn(values[0])
+	}, nil)
+}
+
+// Join returns a stream of fn(v1, v2) where v1 and v2 are the
+// values of the two streams.
+//
+// Like Map, the changes of the stream are computed by diffing.
+func Join(s1 streams.Stream, v1 changes.Value, s2 streams.Stream, v2 changes.Value, fn func(v1, v2 changes.Value) changes.Value) Stream {
+	return newDerived([]streams.Stream{s1, s2}, []changes.Value{v1, v2}, func(values []changes.Value) changes.Value {
+		return fn(values[0], values[1])
+	}, nil)
+}
+
+// Filter returns a stream of all items of the base collection for
+// which keep returns true.
+//
+// Splices and changes to individual items of the base collection
+// map to splices or item changes of the filtered collection.
+func Filter(s streams.Stream, v types.A, keep func(v changes.Value) bool) Stream {
+	compute := func(values []changes.Value) changes.Value {
+		items, _ := values[0].(types.A)
+		return filterItems(items, keep)
+	}
+	incremental := func(values []changes.Value, c changes.Change) (changes.Change, bool) {
+		items, _ := values[0].(types.A)
+		return filterChange(items, c, keep)
+	}
+	return newDerived([]streams.Stream{s}, []changes.Value{v}, compute, incremental)
+}
+
+// Sort returns a stream of the items of the base collection sorted
+// using the less function. The sort is stable.
+//
+// Changes to individual items which do not affect the sort order
+// map to changes of the corresponding items of the sorted
+// collection.
+func Sort(s streams.Stream, v types.A, less func(v1, v2 changes.Value) bool) Stream {
+	compute := func(values []changes.Value) changes.Value {
+		items, _ := values[0].(types.A)
+		result := types.A{}
+		for _, idx := range sortOrder(items, less) {
+			result = append(result, items[idx])
+		}
+		return result
+	}
+	incremental := func(values []changes.Value, c changes.Change) (changes.Change, bool) {
+		items, _ := values[0].(types.A)
+		return sortChange(items, c, less)
+	}
+	return newDerived([]streams.Stream{s}, []changes.Value{v}, compute, incremental)
+}
+
+type derived struct {
+	bases       []streams.Stream
+	values      []changes.Value
+	value       changes.Value
+	compute     func(values []changes.Value) changes.Value
+	incremental func(values []changes.Value, c changes.Change) (changes.Change, bool)
+}
+
+func newDerived(bases []streams.Stream, values []changes.Value, compute func([]changes.Value) changes.Value, incremental func([]changes.Value, changes.Change) (changes.Change, bool)) Stream {
+	return &derived{bases, values, compute(values), compute, incremental}
+}
+
+func (d *derived) Value() changes.Value {
+	return d.value
+}
+
+func (d *derived) Next() (streams.Stream, changes.Change) {
+	for kk, base := range d.bases {
+		next, c := base.Next()
+		if next == nil {
+			continue
+		}
+
+		bases := append([]streams.Stream(nil), d.bases...)
+		values := append([]changes.Value(nil), d.values...)
+		bases[kk] = next
+		values[kk] = values[kk].Apply(nil, c)
+
+		var cx changes.Change
+		ok := false
+		if d.incremental != nil {
+			cx, ok = d.incremental(d.values, c)
+		}
+
+		value := d.value
+		if ok {
+			value = value.Apply(nil, cx)
+		} else {
+			value = d.compute(values)
+			cx = fallback(d.value, value)
+		}
+		return &derived{bases, values, value, d.compute, d.incremental}, cx
+	}
+	return nil, nil
+}
+
+func (d *derived) Append(c changes.Change) streams.Stream {
+	return d
+}
+
+func (d *derived) ReverseAppend(c changes.Change) streams.Stream {
+	return d
+}
+
+func (d *derived) Push() error {
+	for _, base := range d.bases {
+		if err := base.Push(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *derived) Pull() error {
+	for _, base := range d.bases {
+		if err := base.Pull(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *derived) Undo() {}
+
+func (d *derived) Redo() {}
+
+// Notify implements streams.Notifier
+func (d *derived) Notify(fn func()) (cancel func()) {
+	var cancels []func()
+	for _, base := range d.bases {
+		if n, ok := base.(streams.Notifier); ok {
+			cancels = append(cancels, n.Notify(fn))
+		}
+	}
+	return func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+}
+
+func fallback(before, after changes.Value) changes.Change {
+	if reflect.DeepEqual(before, after) {
+		return nil
+	}
+	return diff.Std{}.Diff(diff.Std{}, before, after)
+}
+
+// itemChange splits a change to an item of a collection into the
+// index of the item and the change to the item.
+func itemChange(c changes.Change) (int, changes.Change, bool) {
+	if pc, ok := c.(changes.PathChange); ok {
+		if len(pc.Path) == 0 {
+			return itemChange(pc.Change)
+		}
+		idx, ok := pc.Path[0].(int)
+		ok = ok && idx >= 0
+		if ok && len(pc.Path) == 1 {
+			return idx, pc.Change, true
+		}
+		if ok {
+			return idx, changes.PathChange{Path: pc.Path[1:], Change: pc.Change}, true
+		}
+	}
+	return 0, nil, false
+}
+
+func filterItems(items types.A, keep func(changes.Value) bool) types.A {
+	result := types.A{}
+	for _, item := range items {
+		if keep(item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func filterChange(items types.A, c changes.Change, keep func(changes.Value) bool) (changes.Change, bool) {
+	if cs, ok := c.(changes.ChangeSet); ok {
+		var result changes.ChangeSet
+		for _, cx := range cs {
+			fx, ok := filterChange(items, cx, keep)
+			if !ok {
+				return nil, false
+			}
+			result = append(result, fx)
+			items, _ = items.Apply(nil, cx).(types.A)
+		}
+		return result.Simplify(), true
+	}
+
+	if splice, ok := c.(changes.Splice); ok {
+		before, ok1 := splice.Before.(types.A)
+		after, ok2 := splice.After.(types.A)
+		if !ok1 || !ok2 || splice.Offset < 0 || splice.Offset > len(items) {
+			return nil, false
+		}
+		before, after = filterItems(before, keep), filterItems(after, keep)
+		if len(before) == 0 && len(after) == 0 {
+			return nil, true
+		}
+		offset := len(filterItems(items[:splice.Offset], keep))
+		return changes.Splice{Offset: offset, Before: before, After: after}, true
+	}
+
+	idx, cx, ok := itemChange(c)
+	if !ok || idx >= len(items) {
+		return nil, false
+	}
+
+	before := items[idx]
+	after := before.Apply(nil, cx)
+	offset := len(filterItems(items[:idx], keep))
+	switch kept1, kept2 := keep(before), keep(after); {
+	case kept1 && kept2:
+		return changes.PathChange{Path: []interface{}{offset}, Change: cx}, true
+	case kept1:
+		return changes.Splice{Offset: offset, Before: types.A{before}, After: types.A{}}, true
+	case kept2:
+		return changes.Splice{Offset: offset, Before: types.A{}, After: types.A{after}}, true
+	}
+	return nil, true
+}
+
+func sortChange(items types.A, c changes.Change, less func(v1, v2 changes.Value) bool) (changes.Change, bool) {
+	idx, cx, ok := itemChange(c)
+	if !ok || idx >= len(items) {
+		return nil, false
+	}
+
+	// the order is unchanged if the updated item still sorts
+	// between its neighbors
+	sorted := func(i int, vi changes.Value, j int, vj changes.Value) bool {
+		return less(vi, vj) || !less(vj, vi) && i < j
+	}
+
+	order := sortOrder(items, less)
+	updated := items[idx].Apply(nil, cx)
+	for kk, orig := range order {
+		if orig != idx {
+			continue
+		}
+		if kk > 0 && !sorted(order[kk-1], items[order[kk-1]], idx, updated) {
+			return nil, false
+		}
+		if next := kk + 1; next < len(order) && !sorted(idx, updated, order[next], items[order[next]]) {
+			return nil, false
+		}
+		return changes.PathChange{Path: []interface{}{kk}, Change: cx}, true
+	}
+	return nil, false
+}
+
+// sortOrder returns the indices of the items in sorted order
+func sortOrder(items types.A, less func(v1, v2 changes.Value) bool) []int {
+	order := make([]int, len(items))
+	for kk := range order {
+		order[kk] = kk
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return less(items[order[i]], items[order[j]])
+	})
+	return order
+}
@@ -0,0 +1,257 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package derived_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/streams"
+	"github.com/dotchain/dot/streams/derived"
+)
+
+func TestMap(t *testing.T) {
+	s := streams.New()
+	upper := derived.Map(s, types.S8("hello"), func(v changes.Value) changes.Value {
+		return types.S8(strings.ToUpper(string(v.(types.S8))))
+	})
+	if upper.Value() != types.S8("HELLO") {
+		t.Fatal("Unexpected value", upper.Value())
+	}
+
+	s = s.Append(changes.Splice{Offset: 5, Before: types.S8(""), After: types.S8(" world")})
+	s.Append(changes.Splice{Offset: 0, Before: types.S8("h"), After: types.S8("H")})
+
+	changed := collect(t, upper)
+	expected := []changes.Change{
+		changes.ChangeSet{changes.Splice{Offset: 5, Before: types.S8(""), After: types.S8(" WORLD")}},
+		nil,
+	}
+	if !reflect.DeepEqual(changed, expected) {
+		t.Fatal("Unexpected changes", changed)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	s1, s2 := streams.New(), streams.New()
+	joined := derived.Join(s1, types.S8("a"), s2, types.S8("b"), func(v1, v2 changes.Value) changes.Value {
+		return v1.(types.S8) + v2.(types.S8)
+	})
+
+	s1.Append(changes.Splice{Offset: 1, Before: types.S8(""), After: types.S8("x")})
+	s2.Append(changes.Splice{Offset: 1, Before: types.S8(""), After: types.S8("y")})
+	collect(t, joined)
+
+	latest := latest(joined)
+	if latest.Value() != types.S8("axby") {
+		t.Fatal("Unexpected value", latest.Value())
+	}
+}
+
+func TestFilter(t *testing.T) {
+	odd := func(v changes.Value) bool { return len(v.(types.S8))%2 == 1 }
+	s := streams.New()
+	filtered := derived.Filter(s, types.A{types.S8("a"), types.S8("bb"), types.S8("c")}, odd)
+	if !reflect.DeepEqual(filtered.Value(), types.A{types.S8("a"), types.S8("c")}) {
+		t.Fatal("Unexpected value", filtered.Value())
+	}
+
+	insert := func(offset int, s string) changes.Change {
+		return changes.Splice{Offset: offset, Before: types.S8(""), After: types.S8(s)}
+	}
+	item := func(idx int, c changes.Change) changes.Change {
+		return changes.PathChange{Path: []interface{}{idx}, Change: c}
+	}
+
+	cs := []changes.Change{
+		// splice in items
+		changes.Splice{Offset: 1, Before: types.A{}, After: types.A{types.S8("dd"), types.S8("e")}},
+		// updates to kept item
+		item(2, insert(0, "xx")),
+		// item becomes hidden
+		item(0, insert(0, "x")),
+		// item becomes visible
+		item(1, insert(0, "x")),
+		// hidden item remains hidden
+		item(3, insert(0, "xx")),
+		// ChangeSet
+		changes.ChangeSet{item(0, insert(0, "x")), changes.PathChange{Change: item(1, insert(0, "x"))}},
+		// move falls back to diff
+		changes.Move{Offset: 0, Count: 1, Distance: 1},
+		// non-splice ops fall back
+		changes.Replace{Before: types.A{}, After: types.A{types.S8("z")}},
+	}
+
+	for _, c := range cs {
+		s = s.Append(c)
+	}
+
+	changed := collect(t, filtered)
+	expected := []changes.Change{
+		changes.Splice{Offset: 1, Before: types.A{}, After: types.A{types.S8("e")}},
+		item(1, insert(0, "xx")),
+		changes.Splice{Offset: 0, Before: types.A{types.S8("a")}, After: types.A{}},
+		changes.Splice{Offset: 0, Before: types.A{}, After: types.A{types.S8("xdd")}},
+		nil,
+	}
+	if !reflect.DeepEqual(changed[:len(expected)], expected) {
+		t.Fatal("Unexpected changes", changed)
+	}
+	if x := latest(filtered).Value(); !reflect.DeepEqual(x, types.A{types.S8("z")}) {
+		t.Fatal("Unexpected value", x)
+	}
+}
+
+func TestSort(t *testing.T) {
+	less := func(v1, v2 changes.Value) bool { return v1.(types.S8) < v2.(types.S8) }
+	s := streams.New()
+	sorted := derived.Sort(s, types.A{types.S8("c"), types.S8("a"), types.S8("b")}, less)
+	if !reflect.DeepEqual(sorted.Value(), types.A{types.S8("a"), types.S8("b"), types.S8("c")}) {
+		t.Fatal("Unexpected value", sorted.Value())
+	}
+
+	item := func(idx int, s string) changes.Change {
+		splice := changes.Splice{Offset: 0, Before: types.S8(""), After: types.S8(s)}
+		return changes.PathChange{Path: []interface{}{idx}, Change: splice}
+	}
+
+	// order preserved
+	s = s.Append(item(0, "x"))
+	// order changed
+	s.Append(item(1, "z"))
+
+	changed := collect(t, sorted)
+	if !reflect.DeepEqual(changed[0], changes.PathChange{Path: []interface{}{2}, Change: item(0, "x").(changes.PathChange).Change}) {
+		t.Fatal("Unexpected change", changed[0])
+	}
+	if _, ok := changed[1].(changes.Replace); !ok {
+		t.Fatal("Unexpected change", changed[1])
+	}
+}
+
+func TestFilterIncremental(t *testing.T) {
+	calls := 0
+	keep := func(v changes.Value) bool {
+		calls++
+		return true
+	}
+	items := types.A{}
+	for kk := 0; kk < 100; kk++ {
+		items = append(items, types.S8("a"))
+	}
+	s := streams.New()
+	filtered := derived.Filter(s, items, keep)
+
+	calls = 0
+	splice := changes.Splice{Offset: 0, Before: types.S8(""), After: types.S8("x")}
+	s.Append(changes.PathChange{Path: []interface{}{0}, Change: splice})
+	next, _ := filtered.Next()
+	if calls > 2 {
+		t.Fatal("Unexpected recompute", calls)
+	}
+	if x := next.(derived.Stream).Value().(types.A)[0]; x != types.S8("xa") {
+		t.Fatal("Unexpected value", x)
+	}
+}
+
+func TestSortTies(t *testing.T) {
+	less := func(v1, v2 changes.Value) bool { return v1.(types.S8) < v2.(types.S8) }
+	s := streams.New()
+	sorted := derived.Sort(s, types.A{types.S8("a"), types.S8("b"), types.S8("c")}, less)
+
+	item := func(idx int, before, after string) changes.Change {
+		splice := changes.Splice{Offset: 0, Before: types.S8(before), After: types.S8(after)}
+		return changes.PathChange{Path: []interface{}{idx}, Change: splice}
+	}
+
+	// "c" => "b" ties with the earlier "b" and so stays after it
+	s = s.Append(item(2, "c", "b"))
+	// "a" => "b" ties with the later "b" and so stays before it
+	s = s.Append(item(0, "a", "b"))
+	// "b" => "c" moves after the later "b"
+	s.Append(item(0, "b", "c"))
+
+	changed := collect(t, sorted)
+	if _, ok := changed[0].(changes.Replace); ok {
+		t.Fatal("Unexpected change", changed[0])
+	}
+	if _, ok := changed[1].(changes.Replace); ok {
+		t.Fatal("Unexpected change", changed[1])
+	}
+	if _, ok := changed[2].(changes.PathChange); ok {
+		t.Fatal("Unexpected change", changed[2])
+	}
+
+	expected := types.A{types.S8("b"), types.S8("b"), types.S8("c")}
+	if x := latest(sorted).Value(); !reflect.DeepEqual(x, expected) {
+		t.Fatal("Unexpected value", x)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	s := &errStream{streams.New()}
+	d := derived.Map(s, types.S8(""), func(v changes.Value) changes.Value { return v })
+	if d.Append(nil) != d || d.ReverseAppend(nil) != d {
+		t.Fatal("Unexpected append")
+	}
+	d.Undo()
+	d.Redo()
+
+	if d.Push() == nil || d.Pull() == nil {
+		t.Fatal("Unexpected push/pull success")
+	}
+
+	base := streams.New()
+	d = derived.Map(base, types.S8(""), func(v changes.Value) changes.Value { return v })
+	if d.Push() != nil || d.Pull() != nil {
+		t.Fatal("Unexpected push/pull failure")
+	}
+
+	var seen []changes.Change
+	cancel := streams.Watch(d, func(c changes.Change) { seen = append(seen, c) })
+	base.Append(changes.Replace{Before: types.S8(""), After: types.S8("x")})
+	cancel()
+	base.Append(changes.Replace{Before: types.S8(""), After: types.S8("y")})
+
+	if len(seen) != 1 {
+		t.Fatal("Unexpected watch", seen)
+	}
+}
+
+type errStream struct {
+	streams.Stream
+}
+
+func (e *errStream) Push() error { return errors.New("push") }
+func (e *errStream) Pull() error { return errors.New("pull") }
+
+// collect fetches all the changes on the derived stream, validating
+// that the changes match the values
+func collect(t *testing.T, d derived.Stream) []changes.Change {
+	var result []changes.Change
+	for next, c := d.Next(); next != nil; next, c = next.Next() {
+		v := d.Value()
+		if c != nil {
+			v = v.Apply(nil, c)
+		}
+		d = next.(derived.Stream)
+		if !reflect.DeepEqual(v, d.Value()) {
+			t.Fatal("Change does not match value", c, v, d.Value())
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+func latest(d derived.Stream) derived.Stream {
+	for next, _ := d.Next(); next != nil; next, _ = next.Next() {
+		d = next.(derived.Stream)
+	}
+	return d
+}
@@ -0,0 +1,69 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package streams
+
+import (
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+)
+
+// Set implements a stream of types.Set values.
+type Set struct {
+	Stream Stream
+	Value  types.Set
+}
+
+// Next returns the next if there is one.
+func (s *Set) Next() (*Set, changes.Change) {
+	if s.Stream == nil {
+		return nil, nil
+	}
+
+	next, nextc := s.Stream.Next()
+	if next == nil {
+		return nil, nil
+	}
+
+	v := s.Value
+	val, ok := v.Apply(nil, nextc).(types.Set)
+	if ok {
+		v = val
+	} else {
+		next = nil
+		nextc = nil
+	}
+	return &Set{Stream: next, Value: v}, nextc
+}
+
+// Latest returns the latest non-nil entry in the stream
+func (s *Set) Latest() *Set {
+	for next, _ := s.Next(); next != nil; next, _ = s.Next() {
+		s = next
+	}
+	return s
+}
+
+// Update replaces the current value with the new value
+func (s *Set) Update(val types.Set) *Set {
+	return s.append(changes.Replace{Before: s.Value, After: val})
+}
+
+// Add adds the keys to the set
+func (s *Set) Add(keys ...interface{}) *Set {
+	return s.append(s.Value.Add(keys...))
+}
+
+// Remove removes the keys from the set
+func (s *Set) Remove(keys ...interface{}) *Set {
+	return s.append(s.Value.Remove(keys...))
+}
+
+func (s *Set) append(c changes.Change) *Set {
+	if s.Stream != nil {
+		nexts := s.Stream.Append(c)
+		s = &Set{Stream: nexts, Value: s.Value.Apply(nil, c).(types.Set)}
+	}
+	return s
+}
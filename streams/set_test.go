@@ -0,0 +1,64 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package streams_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/streams"
+)
+
+func TestSetStream(t *testing.T) {
+	s := streams.New()
+	strong := &streams.Set{Stream: s, Value: types.Set{"a": true}}
+
+	strong = strong.Update(types.Set{"b": true})
+	if !reflect.DeepEqual(strong.Value, types.Set{"b": true}) {
+		t.Error("Update did not change value", strong.Value)
+	}
+	s, c := s.Next()
+
+	if !reflect.DeepEqual(c, changes.Replace{Before: types.Set{"a": true}, After: types.Set{"b": true}}) {
+		t.Error("Unexpected change on main stream", c)
+	}
+
+	s = s.Append(types.Set{"b": true}.Add("c"))
+	strong = strong.Latest()
+	if !reflect.DeepEqual(strong.Value, types.Set{"b": true, "c": true}) {
+		t.Error("Unexpected change on set stream", strong.Value)
+	}
+
+	if _, c := strong.Next(); c != nil {
+		t.Error("Unexpected change on set stream", c)
+	}
+
+	s.Append(changes.Replace{Before: strong.Value, After: changes.Nil})
+	if strong, c = strong.Next(); c != nil {
+		t.Error("Unexpected change on set stream", c, strong)
+	}
+
+	if x := (&streams.Set{}).Add("a"); x.Stream != nil || x.Value != nil {
+		t.Error("Unexpected add on nil stream", x)
+	}
+}
+
+func TestSetStreamAddRemove(t *testing.T) {
+	s := streams.New()
+	strong1 := &streams.Set{Stream: s, Value: types.Set{"a": true}}
+	strong2 := &streams.Set{Stream: s, Value: types.Set{"a": true}}
+
+	strong1 = strong1.Remove("a")
+	strong2 = strong2.Add("a", "b")
+	strong1 = strong1.Latest()
+	strong2 = strong2.Latest()
+
+	expected := types.Set{"a": true, "b": true}
+	if !reflect.DeepEqual(strong1.Value, expected) || !reflect.DeepEqual(strong2.Value, expected) {
+		t.Error("Add/Remove diverged", strong1.Value, strong2.Value)
+	}
+}
@@ -49,6 +49,12 @@
 // refer to the correct index on the parent.   The Substream() method
 // provides the implementation of this concept.
 //
+// Notifications
+//
+// Watch calls a function with every new change on a stream instead
+// of requiring the caller to poll Next or Latest. This works with
+// derived streams such as Substream, Transform and Branch.
+//
 // Value Streams
 //
 // Streams inherently only track the actual changes and not the
@@ -61,6 +67,10 @@
 // The dotc package (https://godoc.org/github.com/dotchain/dot/x/dotc)
 // defines a mechanism to automatically generate the Stream related
 // types for structs, slices and unions.
+//
+// Typed, Slice and Map provide generic value streams for any
+// changes.Value type (with Field for accessing sub-fields via a
+// Lens) which can be used instead of generated code.
 package streams
 
 import "github.com/dotchain/dot/changes"
@@ -134,12 +144,13 @@ type Stream interface {
 
 // New returns a new Stream
 func New() Stream {
-	return &stream{}
+	return &stream{family: &family{}}
 }
 
 type stream struct {
-	c    changes.Change
-	next *stream
+	c      changes.Change
+	next   *stream
+	family *family
 }
 
 func (s *stream) Next() (Stream, changes.Change) {
@@ -150,20 +161,29 @@ func (s *stream) Next() (Stream, changes.Change) {
 }
 
 func (s *stream) Append(c changes.Change) Stream {
-	return s.apply(c, false)
+	result := s.apply(c, false)
+	s.family.notify()
+	return result
 }
 
 func (s *stream) ReverseAppend(c changes.Change) Stream {
-	return s.apply(c, true)
+	result := s.apply(c, true)
+	s.family.notify()
+	return result
+}
+
+// Notify implements Notifier
+func (s *stream) Notify(fn func()) (cancel func()) {
+	return s.family.add(fn)
 }
 
 func (s *stream) apply(c changes.Change, reverse bool) *stream {
-	result := &stream{}
+	result := &stream{family: s.family}
 	next := result
 	for s.next != nil {
 		c, next.c = s.merge(s.c, c, reverse)
 		s = s.next
-		next.next = &stream{}
+		next.next = &stream{family: s.family}
 		next = next.next
 	}
 	s.c, s.next = c, next
@@ -76,3 +76,8 @@ func (s *substream) apply(c changes.Change, reverse bool) Stream {
 	}
 	return &substream{s.Stream.Append(c), s.ref}
 }
+
+// Notify implements Notifier
+func (s *substream) Notify(fn func()) (cancel func()) {
+	return notify(s.Stream, fn)
+}
@@ -0,0 +1,68 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package streams
+
+import (
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/crdt"
+)
+
+// Text implements a stream of crdt.Text values.  Offsets and counts
+// are in UTF16 units like crdt.Text.
+type Text struct {
+	Stream Stream
+	Value  crdt.Text
+}
+
+// Next returns the next if there is one.
+func (s *Text) Next() (*Text, changes.Change) {
+	if s.Stream == nil {
+		return nil, nil
+	}
+
+	next, nextc := s.Stream.Next()
+	if next == nil {
+		return nil, nil
+	}
+
+	v := s.Value
+	val, ok := v.Apply(nil, nextc).(crdt.Text)
+	if ok {
+		v = val
+	} else {
+		next = nil
+		nextc = nil
+	}
+	return &Text{Stream: next, Value: v}, nextc
+}
+
+// Latest returns the latest non-nil entry in the stream
+func (s *Text) Latest() *Text {
+	for next, _ := s.Next(); next != nil; next, _ = s.Next() {
+		s = next
+	}
+	return s
+}
+
+// Splice replaces s[offset:offset+count] with the provided insert
+// string value.
+func (s *Text) Splice(offset, count int, insert string) *Text {
+	c, v := s.Value.Splice(offset, count, insert)
+	return s.append(c, v)
+}
+
+// Move moves s[offset:offset+count] by the provided distance to the
+// right (or if distance is negative, to the left)
+func (s *Text) Move(offset, count, distance int) *Text {
+	c, v := s.Value.Move(offset, count, distance)
+	return s.append(c, v)
+}
+
+func (s *Text) append(c changes.Change, v crdt.Text) *Text {
+	if s.Stream != nil {
+		s = &Text{Stream: s.Stream.Append(c), Value: v}
+	}
+	return s
+}
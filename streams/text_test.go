@@ -0,0 +1,47 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package streams_test
+
+import (
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/crdt"
+	"github.com/dotchain/dot/streams"
+)
+
+func TestTextStream(t *testing.T) {
+	s := streams.New()
+	strong1 := &streams.Text{Stream: s, Value: crdt.Text{}}
+	strong1 = strong1.Splice(0, 0, "hello world")
+	strong2 := strong1.Latest()
+
+	strong1 = strong1.Splice(0, 1, "H")
+	strong2 = strong2.Splice(6, 5, "there")
+	strong1, strong2 = strong1.Latest(), strong2.Latest()
+
+	if x, y := strong1.Value.String(), strong2.Value.String(); x != "Hello there" || x != y {
+		t.Error("Unexpected text", x, y)
+	}
+
+	strong2 = strong2.Move(0, 5, 6)
+	strong1 = strong1.Latest()
+	if x := strong1.Value.String(); x != " thereHello" || x != strong2.Value.String() {
+		t.Error("Unexpected move", x)
+	}
+
+	if _, c := strong1.Next(); c != nil {
+		t.Error("Unexpected change", c)
+	}
+
+	strong1.Stream.Append(changes.Replace{Before: strong1.Value, After: changes.Nil})
+	if next, c := strong1.Next(); c != nil || next.Stream != nil {
+		t.Error("Unexpected change", c, next)
+	}
+
+	if x := (&streams.Text{}).Splice(0, 0, "a"); x.Stream != nil || x.Value.String() != "" {
+		t.Error("Unexpected splice on nil stream", x)
+	}
+}
@@ -48,3 +48,8 @@ func (t transform) Append(c changes.Change) Stream {
 func (t transform) ReverseAppend(c changes.Change) Stream {
 	return transform{t.Stream.ReverseAppend(c), t.append, t.next}
 }
+
+// Notify implements Notifier
+func (t transform) Notify(fn func()) (cancel func()) {
+	return notify(t.Stream, fn)
+}
@@ -0,0 +1,278 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package streams
+
+import (
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+)
+
+// Typed implements a stream of any changes.Value type without the
+// need for generated code.
+type Typed[T changes.Value] struct {
+	Stream Stream
+	Value  T
+}
+
+// Next returns the next if there is one.
+func (s *Typed[T]) Next() (*Typed[T], changes.Change) {
+	if s.Stream == nil {
+		return nil, nil
+	}
+
+	next, nextc := s.Stream.Next()
+	if next == nil {
+		return nil, nil
+	}
+
+	v, ok := s.Value.Apply(nil, nextc).(T)
+	if !ok {
+		return &Typed[T]{Value: s.Value}, nil
+	}
+	return &Typed[T]{Stream: next, Value: v}, nextc
+}
+
+// Latest returns the latest non-nil entry in the stream
+func (s *Typed[T]) Latest() *Typed[T] {
+	for next, _ := s.Next(); next != nil; next, _ = s.Next() {
+		s = next
+	}
+	return s
+}
+
+// Update replaces the current value with the new value
+func (s *Typed[T]) Update(val T) *Typed[T] {
+	if s.Stream != nil {
+		c := changes.Replace{Before: orNil(s.Value), After: orNil(val)}
+		s = &Typed[T]{Stream: s.Stream.Append(c), Value: val}
+	}
+	return s
+}
+
+// Lens describes a field F within a value T: Key is the path
+// element of the field and Get fetches the field value.
+type Lens[T, F changes.Value] struct {
+	Key interface{}
+	Get func(v T) F
+}
+
+// Field returns the stream of the field described by the lens
+func Field[T, F changes.Value](s *Typed[T], lens Lens[T, F]) *Typed[F] {
+	var stream Stream
+	if s.Stream != nil {
+		stream = Substream(s.Stream, lens.Key)
+	}
+	return &Typed[F]{Stream: stream, Value: lens.Get(s.Value)}
+}
+
+// Slice implements a stream of a slice of values, backed by a
+// types.A value.
+type Slice[T changes.Value] struct {
+	Stream Stream
+	Value  []T
+}
+
+// Next returns the next if there is one.
+func (s *Slice[T]) Next() (*Slice[T], changes.Change) {
+	if s.Stream == nil {
+		return nil, nil
+	}
+
+	next, nextc := s.Stream.Next()
+	if next == nil {
+		return nil, nil
+	}
+
+	v, ok := fromA[T](toA(s.Value).Apply(nil, nextc))
+	if !ok {
+		return &Slice[T]{Value: s.Value}, nil
+	}
+	return &Slice[T]{Stream: next, Value: v}, nextc
+}
+
+// Latest returns the latest non-nil entry in the stream
+func (s *Slice[T]) Latest() *Slice[T] {
+	for next, _ := s.Next(); next != nil; next, _ = s.Next() {
+		s = next
+	}
+	return s
+}
+
+// Update replaces the current value with the new value
+func (s *Slice[T]) Update(val []T) *Slice[T] {
+	if s.Stream != nil {
+		c := changes.Replace{Before: toA(s.Value), After: toA(val)}
+		s = &Slice[T]{Stream: s.Stream.Append(c), Value: val}
+	}
+	return s
+}
+
+// Item returns the stream of the item at the provided index
+func (s *Slice[T]) Item(index int) *Typed[T] {
+	var stream Stream
+	if s.Stream != nil {
+		stream = Substream(s.Stream, index)
+	}
+	return &Typed[T]{Stream: stream, Value: s.Value[index]}
+}
+
+// Splice replaces s[offset:offset+count] with the provided items
+func (s *Slice[T]) Splice(offset, count int, insert ...T) *Slice[T] {
+	val := toA(s.Value)
+	before := val[offset : offset+count]
+	c := changes.Splice{Offset: offset, Before: before, After: toA(insert)}
+	v, _ := fromA[T](val.Apply(nil, c))
+	var stream Stream
+	if s.Stream != nil {
+		stream = s.Stream.Append(c)
+	}
+	return &Slice[T]{Stream: stream, Value: v}
+}
+
+// Move moves[offset:offset+count] by the provided distance to the
+// right (or if distance is negative, to the left)
+func (s *Slice[T]) Move(offset, count, distance int) *Slice[T] {
+	c := changes.Move{Offset: offset, Count: count, Distance: distance}
+	v, _ := fromA[T](toA(s.Value).Apply(nil, c))
+	var stream Stream
+	if s.Stream != nil {
+		stream = s.Stream.Append(c)
+	}
+	return &Slice[T]{Stream: stream, Value: v}
+}
+
+// Map implements a stream of a map of values, backed by a types.M
+// value.
+type Map[K comparable, V changes.Value] struct {
+	Stream Stream
+	Value  map[K]V
+}
+
+// Next returns the next if there is one.
+func (s *Map[K, V]) Next() (*Map[K, V], changes.Change) {
+	if s.Stream == nil {
+		return nil, nil
+	}
+
+	next, nextc := s.Stream.Next()
+	if next == nil {
+		return nil, nil
+	}
+
+	v, ok := fromM[K, V](toM(s.Value).Apply(nil, nextc))
+	if !ok {
+		return &Map[K, V]{Value: s.Value}, nil
+	}
+	return &Map[K, V]{Stream: next, Value: v}, nextc
+}
+
+// Latest returns the latest non-nil entry in the stream
+func (s *Map[K, V]) Latest() *Map[K, V] {
+	for next, _ := s.Next(); next != nil; next, _ = s.Next() {
+		s = next
+	}
+	return s
+}
+
+// Update replaces the current value with the new value
+func (s *Map[K, V]) Update(val map[K]V) *Map[K, V] {
+	if s.Stream != nil {
+		c := changes.Replace{Before: toM(s.Value), After: toM(val)}
+		s = &Map[K, V]{Stream: s.Stream.Append(c), Value: val}
+	}
+	return s
+}
+
+// Item returns the stream of the value at the provided key
+func (s *Map[K, V]) Item(key K) *Typed[V] {
+	var stream Stream
+	if s.Stream != nil {
+		stream = Substream(s.Stream, key)
+	}
+	return &Typed[V]{Stream: stream, Value: s.Value[key]}
+}
+
+// Set sets the value at the provided key
+func (s *Map[K, V]) Set(key K, val V) *Map[K, V] {
+	return s.set(key, orNil(val))
+}
+
+// Delete removes the provided key
+func (s *Map[K, V]) Delete(key K) *Map[K, V] {
+	return s.set(key, changes.Nil)
+}
+
+func (s *Map[K, V]) set(key K, val changes.Value) *Map[K, V] {
+	m := toM(s.Value)
+	before := changes.Value(changes.Nil)
+	if v, ok := m[key]; ok {
+		before = v
+	}
+
+	replace := changes.Replace{Before: before, After: val}
+	c := changes.PathChange{Path: []interface{}{key}, Change: replace}
+	v, _ := fromM[K, V](m.Apply(nil, c))
+	var stream Stream
+	if s.Stream != nil {
+		stream = s.Stream.Append(c)
+	}
+	return &Map[K, V]{Stream: stream, Value: v}
+}
+
+func orNil(v changes.Value) changes.Value {
+	if v == nil {
+		return changes.Nil
+	}
+	return v
+}
+
+func toA[T changes.Value](v []T) types.A {
+	result := make(types.A, len(v))
+	for kk, elt := range v {
+		result[kk] = elt
+	}
+	return result
+}
+
+func fromA[T changes.Value](v changes.Value) ([]T, bool) {
+	a, ok := v.(types.A)
+	if !ok {
+		return nil, false
+	}
+
+	result := make([]T, len(a))
+	for kk, elt := range a {
+		if result[kk], ok = elt.(T); !ok {
+			return nil, false
+		}
+	}
+	return result, true
+}
+
+func toM[K comparable, V changes.Value](v map[K]V) types.M {
+	result := make(types.M, len(v))
+	for key, elt := range v {
+		result[key] = elt
+	}
+	return result
+}
+
+func fromM[K comparable, V changes.Value](v changes.Value) (map[K]V, bool) {
+	m, ok := v.(types.M)
+	if !ok {
+		return nil, false
+	}
+
+	result := make(map[K]V, len(m))
+	for key, elt := range m {
+		k, ok1 := key.(K)
+		val, ok2 := elt.(V)
+		if !ok1 || !ok2 {
+			return nil, false
+		}
+		result[k] = val
+	}
+	return result, true
+}
@@ -0,0 +1,116 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package streams_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/streams"
+)
+
+func TestTyped(t *testing.T) {
+	s := streams.New()
+	typed := &streams.Typed[types.S8]{Stream: s, Value: "hello"}
+
+	typed = typed.Update("world")
+	if _, c := s.Next(); c != (changes.Replace{Before: types.S8("hello"), After: types.S8("world")}) {
+		t.Fatal("Unexpected change", c)
+	}
+
+	latest, _ := streams.Latest(s)
+	latest.Append(changes.Splice{Offset: 0, Before: types.S8(""), After: types.S8("OK ")})
+	if typed = typed.Latest(); typed.Value != "OK world" {
+		t.Fatal("Unexpected value", typed.Value)
+	}
+
+	// type mismatch terminates the stream
+	latest, _ = streams.Latest(s)
+	latest.Append(changes.Replace{Before: typed.Value, After: types.A{}})
+	if next, c := typed.Next(); next.Stream != nil || c != nil || next.Value != typed.Value {
+		t.Fatal("Unexpected next", next, c)
+	}
+
+	var empty streams.Typed[types.S8]
+	if x, c := empty.Next(); x != nil || c != nil || empty.Update("x") != &empty {
+		t.Fatal("Unexpected nil stream behavior", x, c)
+	}
+}
+
+func TestTypedField(t *testing.T) {
+	s := streams.New()
+	m := &streams.Typed[types.M]{Stream: s, Value: types.M{"name": types.S8("a")}}
+	name := streams.Field(m, streams.Lens[types.M, types.S8]{
+		Key: "name",
+		Get: func(v types.M) types.S8 { return v["name"].(types.S8) },
+	})
+
+	name.Update("b")
+	if m = m.Latest(); !reflect.DeepEqual(m.Value, types.M{"name": types.S8("b")}) {
+		t.Fatal("Unexpected value", m.Value)
+	}
+}
+
+func TestSlice(t *testing.T) {
+	s := streams.New()
+	slice := &streams.Slice[types.S8]{Stream: s, Value: []types.S8{"a", "b"}}
+
+	slice = slice.Splice(1, 0, "x", "y")
+	slice = slice.Move(0, 1, 1)
+	slice.Item(3).Update("c")
+	if slice = slice.Latest(); !reflect.DeepEqual(slice.Value, []types.S8{"x", "a", "y", "c"}) {
+		t.Fatal("Unexpected value", slice.Value)
+	}
+
+	slice = slice.Update([]types.S8{"z"})
+	other := &streams.Slice[types.S8]{Stream: streams.New(), Value: []types.S8{"z"}}
+	other.Stream.Append(changes.Splice{Offset: 0, Before: types.A{}, After: types.A{types.S16("x")}})
+	if x, _ := other.Next(); x.Stream != nil || !reflect.DeepEqual(x.Value, slice.Value) {
+		t.Fatal("Unexpected next", x)
+	}
+	if x, _ := other.Latest().Next(); x != nil {
+		t.Fatal("Unexpected next", x)
+	}
+
+	empty := &streams.Slice[types.S8]{Value: []types.S8{"a", "b"}}
+	if x := empty.Splice(1, 0, "x"); x.Stream != nil || !reflect.DeepEqual(x.Value, []types.S8{"a", "x", "b"}) {
+		t.Fatal("Unexpected nil stream splice", x)
+	}
+	if x := empty.Move(0, 1, 1); x.Stream != nil || !reflect.DeepEqual(x.Value, []types.S8{"b", "a"}) {
+		t.Fatal("Unexpected nil stream move", x)
+	}
+}
+
+func TestMap(t *testing.T) {
+	s := streams.New()
+	m := &streams.Map[string, types.S8]{Stream: s, Value: map[string]types.S8{"a": "x"}}
+
+	m = m.Set("b", "y")
+	m = m.Delete("a")
+	m.Item("b").Update("z")
+	if m = m.Latest(); !reflect.DeepEqual(m.Value, map[string]types.S8{"b": "z"}) {
+		t.Fatal("Unexpected value", m.Value)
+	}
+
+	m = m.Update(map[string]types.S8{"c": "w"})
+	latest, _ := streams.Latest(s)
+	latest.Append(changes.PathChange{
+		Path:   []interface{}{"c"},
+		Change: changes.Replace{Before: types.S8("w"), After: types.S16("w")},
+	})
+	if x, _ := m.Next(); x.Stream != nil || !reflect.DeepEqual(x.Value, m.Value) {
+		t.Fatal("Unexpected next", x)
+	}
+
+	empty := &streams.Map[string, types.S8]{Value: map[string]types.S8{"a": "x"}}
+	if x := empty.Set("b", "y"); x.Stream != nil || !reflect.DeepEqual(x.Value, map[string]types.S8{"a": "x", "b": "y"}) {
+		t.Fatal("Unexpected nil stream set", x)
+	}
+	if x := empty.Delete("a"); x.Stream != nil || len(x.Value) != 0 {
+		t.Fatal("Unexpected nil stream delete", x)
+	}
+}
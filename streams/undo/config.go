@@ -0,0 +1,103 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package undo
+
+import (
+	"time"
+
+	"github.com/dotchain/dot/changes"
+)
+
+// Config defines the grouping and eviction options for the undo
+// stack.
+//
+// Consecutive local changes are grouped into a single undo step if
+// they are made within Window of each other (and are of the same
+// kind if Kind is provided).  If Window is zero but Kind is
+// provided, consecutive changes of the same non-empty kind are
+// grouped irrespective of time.
+type Config struct {
+	Window   time.Duration
+	Kind     func(c changes.Change) string
+	MaxDepth int
+
+	// Now defaults to time.Now
+	Now func() time.Time
+
+	// Author scopes the stack to changes whose AuthorOf matches
+	// it. All other changes are treated as upstream changes.
+	Author   string
+	AuthorOf func(c changes.Change) string
+}
+
+// Option configures the undo stack
+type Option func(c *Config)
+
+// WithWindow groups local changes made within the window into a
+// single undo step
+func WithWindow(window time.Duration) Option {
+	return func(c *Config) {
+		c.Window = window
+	}
+}
+
+// WithKind groups consecutive local changes of the same kind into a
+// single undo step. When used with WithWindow, both conditions must
+// hold.
+func WithKind(kind func(c changes.Change) string) Option {
+	return func(c *Config) {
+		c.Kind = kind
+	}
+}
+
+// WithMaxDepth limits the number of local undo steps. Older steps
+// are evicted and can no longer be undone.
+func WithMaxDepth(depth int) Option {
+	return func(c *Config) {
+		c.MaxDepth = depth
+	}
+}
+
+// WithClock configures the clock used for grouping by time
+func WithClock(now func() time.Time) Option {
+	return func(c *Config) {
+		c.Now = now
+	}
+}
+
+// WithAuthor scopes the undo stack to the changes made by the
+// provided author: only these can be undone and all other changes
+// are treated as upstream changes, even if they were appended via
+// the undo stream. This allows multiple undo stacks to share a
+// single stream.
+//
+// The key function returns the author of a change. If it is nil,
+// MetaAuthor is used.
+func WithAuthor(author string, key func(c changes.Change) string) Option {
+	return func(c *Config) {
+		if key == nil {
+			key = MetaAuthor
+		}
+		c.Author, c.AuthorOf = author, key
+	}
+}
+
+// MetaAuthor returns the author of a change if it is a changes.Meta
+// with a string Data. It returns an empty string otherwise.
+func MetaAuthor(c changes.Change) string {
+	if m, ok := c.(changes.Meta); ok {
+		if author, ok := m.Data.(string); ok {
+			return author
+		}
+	}
+	return ""
+}
+
+func (c *Config) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
@@ -0,0 +1,178 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package undo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/streams"
+	"github.com/dotchain/dot/streams/undo"
+)
+
+func TestGroupByWindow(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+	upstream := streams.New()
+	downstream := undo.New(upstream, undo.WithWindow(time.Second), undo.WithClock(clock))
+
+	insert(downstream, 0, "a")
+	insert(upstream, 0, "x")
+	insert(downstream, 2, "b")
+	now = now.Add(2 * time.Second)
+	insert(downstream, 3, "c")
+
+	if x := downstream.(undo.Stack).Local(); len(x) != 2 {
+		t.Fatal("Unexpected local", x)
+	}
+
+	latest(downstream).Undo()
+	if x := value(upstream); x != "xab" {
+		t.Fatal("Unexpected value", x)
+	}
+
+	latest(downstream).Undo()
+	if x := value(upstream); x != "x" {
+		t.Fatal("Unexpected value", x)
+	}
+
+	latest(downstream).Redo()
+	if x := value(upstream); x != "xab" {
+		t.Fatal("Unexpected value", x)
+	}
+}
+
+func TestGroupByKind(t *testing.T) {
+	kind := func(c changes.Change) string {
+		if splice, ok := c.(changes.Splice); ok && splice.After.Count() > 0 {
+			return "insert"
+		}
+		return ""
+	}
+	upstream := streams.New()
+	downstream := undo.New(upstream, undo.WithKind(kind))
+
+	insert(downstream, 0, "a")
+	insert(downstream, 1, "b")
+	latest(downstream).Append(changes.Splice{Offset: 0, Before: types.S8("a"), After: types.S8("")})
+	latest(downstream).Append(changes.Splice{Offset: 0, Before: types.S8("b"), After: types.S8("")})
+	insert(downstream, 0, "c")
+
+	if x := downstream.(undo.Stack).Local(); len(x) != 4 {
+		t.Fatal("Unexpected local", x)
+	}
+
+	latest(downstream).Undo()
+	latest(downstream).Undo()
+	latest(downstream).Undo()
+	if x := value(upstream); x != "ab" {
+		t.Fatal("Unexpected value", x)
+	}
+
+	latest(downstream).Undo()
+	if x := value(upstream); x != "" {
+		t.Fatal("Unexpected value", x)
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	upstream := streams.New()
+	downstream := undo.New(upstream)
+	stack := downstream.(undo.Stack)
+
+	insert(downstream, 0, "a")
+	stack.BeginGroup()
+	insert(downstream, 1, "b")
+	stack.BeginGroup()
+	insert(downstream, 2, "c")
+	stack.EndGroup()
+	insert(upstream, 0, "x")
+	insert(downstream, 4, "d")
+	stack.EndGroup()
+	insert(downstream, 5, "e")
+
+	latest(downstream).Undo()
+	latest(downstream).Undo()
+	if x := value(upstream); x != "xa" {
+		t.Fatal("Unexpected value", x)
+	}
+
+	latest(downstream).Redo()
+	if x := value(upstream); x != "xabcd" {
+		t.Fatal("Unexpected value", x)
+	}
+
+	stack.UndoLocal(1)
+	if x := value(upstream); x != "xa" {
+		t.Fatal("Unexpected value", x)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	upstream := streams.New()
+	downstream := undo.New(upstream, undo.WithMaxDepth(2))
+
+	insert(downstream, 0, "a")
+	insert(downstream, 1, "b")
+	insert(downstream, 2, "c")
+
+	if x := downstream.(undo.Stack).Local(); len(x) != 2 {
+		t.Fatal("Unexpected local", x)
+	}
+
+	for kk := 0; kk < 3; kk++ {
+		latest(downstream).Undo()
+	}
+	if x := value(upstream); x != "a" {
+		t.Fatal("Unexpected value", x)
+	}
+}
+
+func insert(s streams.Stream, offset int, text string) {
+	latest(s).Append(changes.Splice{Offset: offset, Before: types.S8(""), After: types.S8(text)})
+}
+
+func TestAuthor(t *testing.T) {
+	upstream := streams.New()
+	alice := undo.New(upstream, undo.WithAuthor("alice", nil))
+	bob := undo.New(upstream, undo.WithAuthor("bob", nil))
+	by := func(s streams.Stream, author string, offset int, text string) {
+		splice := changes.Splice{Offset: offset, Before: types.S8(""), After: types.S8(text)}
+		latest(s).Append(changes.Meta{Data: author, Change: splice})
+	}
+
+	by(alice, "alice", 0, "a")
+	by(bob, "bob", 1, "b")
+	// bob's change appended via alice's stream is still bob's
+	by(alice, "bob", 2, "c")
+	by(upstream, "alice", 3, "d")
+
+	if x := alice.(undo.Stack).Local(); len(x) != 2 {
+		t.Fatal("Unexpected local", x)
+	}
+
+	latest(alice).Undo()
+	latest(alice).Undo()
+	if x := value(upstream); x != "bc" {
+		t.Fatal("Unexpected value", x)
+	}
+
+	latest(bob).Undo()
+	if x := value(upstream); x != "b" {
+		t.Fatal("Unexpected value", x)
+	}
+
+	latest(alice).Redo()
+	latest(bob).Undo()
+	if x := value(upstream); x != "a" {
+		t.Fatal("Unexpected value", x)
+	}
+
+	if x := undo.MetaAuthor(changes.Meta{Data: 5}); x != "" {
+		t.Fatal("Unexpected author", x)
+	}
+}
@@ -5,7 +5,10 @@
 package undo
 
 import (
+	"errors"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/dotchain/dot/changes"
 	"github.com/dotchain/dot/streams"
@@ -20,19 +23,112 @@ const (
 	redo
 )
 
+// entry is a single change on the stack.  Local changes that are
+// grouped together share the same group. Upstream changes do not
+// belong to any group.
+type entry struct {
+	c     changes.Change
+	t     cType
+	group int
+}
+
+// step is a sequence of entries of the same group.  Upstream
+// entries are each a separate step.
+type step struct {
+	t       cType
+	offsets []int
+}
+
 type stack struct {
 	sync.Mutex
 	base    streams.Stream
-	changes []changes.Change
-	types   []cType
+	entries []entry
+	config  Config
+
+	groups   int
+	joinable bool
+	depth    int
+	last     time.Time
+	lastKind string
 }
 
 func (s *stack) pullChanges(t cType) {
+	group := -1
 	for base, c := s.base.Next(); base != nil; base, c = s.base.Next() {
 		s.base = base
-		if c != nil {
-			s.changes = append(s.changes, c)
-			s.types = append(s.types, t)
+		if c == nil {
+			continue
+		}
+
+		switch ct := s.classify(t, c); {
+		case ct == upstream:
+			s.entries = append(s.entries, entry{c, ct, -1})
+		case ct == local || group == -1:
+			group = s.nextGroup(ct, c)
+			fallthrough
+		default:
+			s.entries = append(s.entries, entry{c, ct, group})
+		}
+	}
+}
+
+// classify updates the type of local and upstream changes based on
+// the author, if the stack is scoped to an author
+func (s *stack) classify(t cType, c changes.Change) cType {
+	if s.config.AuthorOf == nil || t == undo || t == redo {
+		return t
+	}
+	if s.config.AuthorOf(c) == s.config.Author {
+		return local
+	}
+	return upstream
+}
+
+// nextGroup returns the group for a local change, reusing the last
+// group if the change can be grouped with it
+func (s *stack) nextGroup(t cType, c changes.Change) int {
+	join := s.joinable && t == local && (s.depth > 0 || s.coalesce(c))
+	s.joinable = t == local
+	s.last = s.config.now()
+	if s.config.Kind != nil {
+		s.lastKind = s.config.Kind(c)
+	}
+
+	if !join {
+		s.groups++
+		if t == local {
+			s.evict()
+		}
+	}
+	return s.groups
+}
+
+func (s *stack) coalesce(c changes.Change) bool {
+	kind := ""
+	if s.config.Kind != nil {
+		kind = s.config.Kind(c)
+	}
+
+	if s.config.Window > 0 {
+		within := s.config.now().Sub(s.last) <= s.config.Window
+		return within && (s.config.Kind == nil || kind == s.lastKind)
+	}
+	return kind != "" && kind == s.lastKind
+}
+
+// evict drops the oldest entries if there are more than MaxDepth
+// local groups including the one about to be added
+func (s *stack) evict() {
+	if s.config.MaxDepth <= 0 {
+		return
+	}
+
+	local := s.localSteps()
+	if drop := len(local) + 1 - s.config.MaxDepth; drop > 0 {
+		if drop >= len(local) {
+			s.entries = nil
+		} else {
+			s.entries = s.entries[local[drop].offsets[0]:]
 		}
 	}
 }
@@ -62,14 +158,69 @@ func (s *stack) Redo() {
 	})
 }
 
+func (s *stack) BeginGroup() {
+	s.withLock(func() {
+		if s.depth == 0 {
+			s.joinable = false
+		}
+		s.depth++
+	})
+}
+
+func (s *stack) EndGroup() {
+	s.withLock(func() {
+		if s.depth > 0 {
+			s.depth--
+		}
+		if s.depth == 0 {
+			s.joinable = false
+		}
+	})
+}
+
+func (s *stack) Local() []changes.Change {
+	var result []changes.Change
+	s.withLock(func() {
+		for _, st := range s.localSteps() {
+			cs := changes.ChangeSet{}
+			for _, offset := range st.offsets {
+				cs = append(cs, s.entries[offset].c)
+			}
+			if len(cs) == 1 {
+				result = append(result, cs[0])
+			} else {
+				result = append(result, cs)
+			}
+		}
+	})
+	return result
+}
+
+func (s *stack) UndoLocal(index int) error {
+	var err error
+	s.withLock(func() {
+		steps := s.localSteps()
+		if index < 0 || index >= len(steps) {
+			err = errors.New("undo: invalid local change index " + strconv.Itoa(index))
+			return
+		}
+		s.base.Append(s.undoStep(steps[index]))
+		s.joinable = false
+		s.pullChanges(local)
+		s.joinable = false
+	})
+	return err
+}
+
 func (s *stack) getUndoChange() (changes.Change, bool) {
 	skipCount := 0
-	l := len(s.changes) - 1
-	for kk := range s.changes {
-		switch s.types[l-kk] {
+	steps := s.steps(s.entries)
+	l := len(steps) - 1
+	for kk := range steps {
+		switch steps[l-kk].t {
 		case redo, local:
 			if skipCount == 0 {
-				return s.undoAt(l - kk), true
+				return s.undoStep(steps[l-kk]), true
 			}
 			skipCount--
 		case undo:
@@ -81,12 +232,13 @@ func (s *stack) getUndoChange() (changes.Change, bool) {
 
 func (s *stack) getRedoChange() (changes.Change, bool) {
 	skipCount := 0
-	l := len(s.changes) - 1
-	for kk := range s.changes {
-		switch s.types[l-kk] {
+	steps := s.steps(s.entries)
+	l := len(steps) - 1
+	for kk := range steps {
+		switch steps[l-kk].t {
 		case undo:
 			if skipCount == 0 {
-				return s.undoAt(l - kk), true
+				return s.undoStep(steps[l-kk]), true
 			}
 			skipCount--
 		case redo:
@@ -98,36 +250,85 @@ func (s *stack) getRedoChange() (changes.Change, bool) {
 	return nil, false
 }
 
-func (s *stack) undoAt(offset int) changes.Change {
-	c := s.changes[offset]
-	if c != nil {
-		c = c.Revert()
+// undoStep reverts all the changes of the step, each transformed
+// against all later changes (including the reverts so far). Only
+// upstream changes can be interleaved with the changes of a step,
+// so only the changes after the step are simplified.
+func (s *stack) undoStep(st step) changes.Change {
+	end := st.offsets[len(st.offsets)-1] + 1
+	after := s.simplify(s.entries[end:])
+
+	var result changes.ChangeSet
+	for kk := len(st.offsets) - 1; kk >= 0; kk-- {
+		offset := st.offsets[kk]
+		c := s.entries[offset].c
+		if c != nil {
+			c = c.Revert()
+		}
+
+		rest := []changes.Change{}
+		for _, e := range s.entries[offset+1 : end] {
+			rest = append(rest, e.c)
+		}
+		rest = append(append(rest, after...), result...)
+		cx, _ := (changes.ChangeSet(rest)).Merge(c)
+		result = append(result, cx)
 	}
+	return result.Simplify()
+}
 
-	rest := s.simplify(s.changes[offset+1:], s.types[offset+1:])
-	cx, _ := (changes.ChangeSet(rest)).Merge(c)
-	return cx
+// steps groups the entries into undo steps.  Upstream changes in
+// the middle of a group do not split the group.
+func (s *stack) steps(entries []entry) []step {
+	var result []step
+	last := -1
+	for kk, e := range entries {
+		switch {
+		case e.t == upstream:
+			result = append(result, step{e.t, []int{kk}})
+		case last >= 0 && entries[result[last].offsets[0]].group == e.group:
+			result[last].offsets = append(result[last].offsets, kk)
+		default:
+			last = len(result)
+			result = append(result, step{e.t, []int{kk}})
+		}
+	}
+	return result
+}
+
+func (s *stack) localSteps() []step {
+	var result []step
+	for _, st := range s.steps(s.entries) {
+		if st.t == local {
+			result = append(result, st)
+		}
+	}
+	return result
 }
 
 // simplify remove undo/redo pairs from the sequence so as to not
 // confuse the merge which is not great with some of these cases
-func (s *stack) simplify(cx []changes.Change, types []cType) []changes.Change {
-	var result []changes.Change
-	var resultTypes []cType
-	for kk, opType := range types {
+func (s *stack) simplify(entries []entry) []changes.Change {
+	var result []step
+	for _, st := range s.steps(entries) {
 		l := len(result)
 		if l > 0 {
-			lastOpType := resultTypes[l-1]
-			cancel1 := (lastOpType == local || lastOpType == redo) && opType == undo
-			cancel2 := lastOpType == undo && opType == redo
+			lastOpType := result[l-1].t
+			cancel1 := (lastOpType == local || lastOpType == redo) && st.t == undo
+			cancel2 := lastOpType == undo && st.t == redo
 			if cancel1 || cancel2 {
 				result = result[0 : l-1]
-				resultTypes = resultTypes[0 : l-1]
 				continue
 			}
 		}
-		resultTypes = append(resultTypes, opType)
-		result = append(result, cx[kk])
+		result = append(result, st)
 	}
-	return result
+
+	var cx []changes.Change
+	for _, st := range result {
+		for _, offset := range st.offsets {
+			cx = append(cx, entries[offset].c)
+		}
+	}
+	return cx
 }
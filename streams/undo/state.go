@@ -0,0 +1,88 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package undo
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/streams"
+)
+
+// State is the serializable state of an undo stack.
+//
+// Changes holds all the changes tracked by the stack. Types and
+// Groups hold the type (local, upstream, undo or redo) and undo
+// group of each change.  The position of the stack (i.e what Undo
+// and Redo would do next) is fully determined by these.
+//
+// Version is the version provided when the state was saved.  This
+// is not interpreted by the stack but can be used to fetch the
+// changes that were missed when restoring.
+//
+// State can be encoded with any nw.Codec once it is registered (see
+// Register).
+type State struct {
+	Version int
+	Changes []changes.Change
+	Types   []int
+	Groups  []int
+}
+
+// Register calls the provided function with the types of this
+// package that need to be registered with a codec:
+//
+//	undo.Register(nw.Register)
+func Register(register func(v interface{})) {
+	register(State{})
+}
+
+func (s *stack) Save(version int) *State {
+	result := &State{Version: version}
+	s.withLock(func() {
+		for _, e := range s.entries {
+			result.Changes = append(result.Changes, e.c)
+			result.Types = append(result.Types, int(e.t))
+			result.Groups = append(result.Groups, e.group)
+		}
+	})
+	return result
+}
+
+// Restore returns an undo stream on top of base with the saved
+// state.
+//
+// The missed changes are the changes that happened between the
+// version of the state and the current version of base.  These are
+// treated as upstream changes and so the restored Undo and Redo
+// properly account for them.
+//
+// An error is returned if the state is malformed (such as when it
+// has been truncated).
+func Restore(base streams.Stream, state *State, missed []changes.Change, opts ...Option) (streams.Stream, error) {
+	n := len(state.Changes)
+	if len(state.Types) != n || len(state.Groups) != n {
+		return nil, errors.New("undo: mismatched state lengths")
+	}
+	for _, t := range state.Types {
+		if t < int(local) || t > int(redo) {
+			return nil, errors.New("undo: invalid state type " + strconv.Itoa(t))
+		}
+	}
+
+	s := New(base, opts...).(stream)
+	for kk, c := range state.Changes {
+		e := entry{c, cType(state.Types[kk]), state.Groups[kk]}
+		s.entries = append(s.entries, e)
+		if e.group > s.groups {
+			s.groups = e.group
+		}
+	}
+	for _, c := range missed {
+		s.entries = append(s.entries, entry{c, upstream, -1})
+	}
+	return s, nil
+}
@@ -0,0 +1,105 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package undo_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/ops/nw"
+	"github.com/dotchain/dot/streams"
+	"github.com/dotchain/dot/streams/undo"
+)
+
+func TestSaveRestore(t *testing.T) {
+	undo.Register(nw.Register)
+	for name, codec := range nw.DefaultCodecs {
+		t.Run(name, func(t *testing.T) {
+			testSaveRestore(t, codec)
+		})
+	}
+}
+
+func testSaveRestore(t *testing.T, codec nw.Codec) {
+	upstream := streams.New()
+	downstream := undo.New(upstream)
+
+	insert(downstream, 0, "hello")
+	insert(upstream, 0, "abc")
+	insert(downstream, 8, " world")
+	latest(downstream).Undo()
+
+	var buf bytes.Buffer
+	saved := downstream.(undo.Stack).Save(5)
+	if err := codec.Encode(*saved, &buf); err != nil {
+		t.Fatal("Encode failed", err)
+	}
+
+	var state undo.State
+	if err := codec.Decode(&state, &buf); err != nil {
+		t.Fatal("Decode failed", err)
+	}
+	if !reflect.DeepEqual(&state, saved) {
+		t.Fatal("Unexpected decoded state", state)
+	}
+
+	// restore onto a fresh stream after missing a change
+	missed := changes.Splice{Offset: 0, Before: types.S8(""), After: types.S8("xyz")}
+	fresh := streams.New()
+	restored, err := undo.Restore(fresh, &state, []changes.Change{missed})
+	if err != nil {
+		t.Fatal("Restore failed", err)
+	}
+
+	restored.Undo()
+	_, c := fresh.Next()
+	expected := changes.Splice{Offset: 6, Before: types.S8("hello"), After: types.S8("")}
+	if c != expected {
+		t.Fatal("Unexpected undo", c)
+	}
+
+	latest(restored).Redo()
+	latest(restored).Redo()
+	if x := restored.(undo.Stack).Local(); len(x) != 2 {
+		t.Fatal("Unexpected local", x)
+	}
+
+	var v changes.Value = types.S8("xyzabchello")
+	for next, c := fresh.Next(); next != nil; next, c = next.Next() {
+		v = v.Apply(nil, c)
+	}
+	if v != types.S8("xyzabchello world") {
+		t.Fatal("Unexpected value", v)
+	}
+}
+
+func TestRestoreInvalid(t *testing.T) {
+	upstream := streams.New()
+	downstream := undo.New(upstream)
+	insert(downstream, 0, "hello")
+	insert(downstream, 5, " world")
+	saved := downstream.(undo.Stack).Save(2)
+
+	truncated := *saved
+	truncated.Types = truncated.Types[:1]
+	if _, err := undo.Restore(streams.New(), &truncated, nil); err == nil {
+		t.Fatal("Unexpected success")
+	}
+
+	truncated = *saved
+	truncated.Groups = nil
+	if _, err := undo.Restore(streams.New(), &truncated, nil); err == nil {
+		t.Fatal("Unexpected success")
+	}
+
+	invalid := *saved
+	invalid.Types = []int{0, 42}
+	if _, err := undo.Restore(streams.New(), &invalid, nil); err == nil {
+		t.Fatal("Unexpected success")
+	}
+}
@@ -9,9 +9,53 @@ import (
 	"github.com/dotchain/dot/streams"
 )
 
-// New returns a new stream with undo/redo capabilities
-func New(base streams.Stream) streams.Stream {
-	return stream{base, &stack{base: base}}
+// New returns a new stream with undo/redo capabilities. The
+// returned stream also implements Stack.
+//
+// By default, each local change is a separate undo step. The
+// options can be used to group changes (see Config).
+func New(base streams.Stream, opts ...Option) streams.Stream {
+	st := &stack{base: base}
+	for _, opt := range opts {
+		opt(&st.config)
+	}
+	return stream{base, st}
+}
+
+// Stack is implemented by the streams returned by New and supports
+// selective undo of earlier local changes.
+//
+// Selective undo transforms the revert of the change against all
+// later changes (local or upstream) and appends it as a new local
+// change. So, it can itself be undone via Undo.
+type Stack interface {
+	streams.Stream
+
+	// Local returns all local changes in the order they were
+	// appended.  This includes the reverts appended by UndoLocal
+	// but not the changes appended by Undo and Redo or upstream
+	// changes. Grouped changes are returned as a single
+	// ChangeSet.
+	Local() []changes.Change
+
+	// UndoLocal undoes the local change at the provided index
+	// of Local(). It returns an error if the index is out of
+	// range.
+	UndoLocal(index int) error
+
+	// BeginGroup starts a transaction: all local changes until
+	// the matching EndGroup are undone as a single step.
+	// Transactions can be nested.
+	BeginGroup()
+
+	// EndGroup ends the transaction started by BeginGroup
+	EndGroup()
+
+	// Save returns the current state of the stack which can be
+	// restored using Restore.  The version is stored as is and
+	// is meant to be the version of the latest change seen by
+	// the stack.
+	Save(version int) *State
 }
 
 type stream struct {
@@ -55,3 +99,11 @@ func (s stream) Undo() {
 func (s stream) Redo() {
 	s.stack.Redo()
 }
+
+// Notify implements streams.Notifier
+func (s stream) Notify(fn func()) (cancel func()) {
+	if n, ok := s.base.(streams.Notifier); ok {
+		return n.Notify(fn)
+	}
+	return func() {}
+}
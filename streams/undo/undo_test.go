@@ -232,3 +232,60 @@ func latest(s streams.Stream) streams.Stream {
 	}
 	return s
 }
+
+func TestUndoLocal(t *testing.T) {
+	upstream := streams.New()
+	downstream := undo.New(upstream)
+	insert := func(s streams.Stream, offset int, text string) {
+		latest(s).Append(changes.Splice{Offset: offset, Before: types.S8(""), After: types.S8(text)})
+	}
+
+	insert(downstream, 0, "hello")
+	insert(upstream, 0, "abc")
+	insert(downstream, 8, " world")
+	insert(upstream, 0, "xyz")
+
+	stack := downstream.(undo.Stack)
+	if x := stack.Local(); len(x) != 2 {
+		t.Fatal("Unexpected local changes", x)
+	}
+
+	if err := stack.UndoLocal(0); err != nil {
+		t.Fatal(err)
+	}
+	if x := value(upstream); x != "xyzabc world" {
+		t.Fatal("Unexpected value", x)
+	}
+
+	// selective undo can itself be undone
+	latest(downstream).Undo()
+	if x := value(upstream); x != "xyzabchello world" {
+		t.Fatal("Unexpected value", x)
+	}
+
+	// the selective undo is also a local change
+	if x := stack.Local(); len(x) != 3 {
+		t.Fatal("Unexpected local changes", x)
+	}
+
+	if err := stack.UndoLocal(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := stack.UndoLocal(5); err == nil {
+		t.Fatal("Unexpected success")
+	}
+	if err := stack.UndoLocal(-1); err == nil {
+		t.Fatal("Unexpected success")
+	}
+	if x := value(upstream); x != "xyzabchello" {
+		t.Fatal("Unexpected value", x)
+	}
+}
+
+func value(s streams.Stream) string {
+	var v changes.Value = types.S8("")
+	for next, c := s.Next(); next != nil; next, c = next.Next() {
+		v = v.Apply(nil, c)
+	}
+	return string(v.(types.S8))
+}
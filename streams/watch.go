@@ -0,0 +1,117 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package streams
+
+import (
+	"sync"
+
+	"github.com/dotchain/dot/changes"
+)
+
+// Notifier is implemented by streams that support Watch.
+//
+// Notify registers a function which is called whenever a change is
+// appended anywhere in the stream family.  Streams which wrap other
+// streams (such as Substream or Branch) implement Notifier by
+// delegating to the underlying stream.
+type Notifier interface {
+	Notify(fn func()) (cancel func())
+}
+
+// Watch calls fn with every change after the provided stream
+// instance, including any that are already available.  The changes
+// are the same as those returned by iterating with Next, so watching
+// a Substream or a Transform yields the changes for that stream.
+//
+// The function is called synchronously on the goroutine that
+// appended the change.  Watch does nothing if the stream does not
+// implement Notifier.
+//
+// The returned function cancels the watch.
+func Watch(s Stream, fn func(c changes.Change)) (cancel func()) {
+	n, ok := s.(Notifier)
+	if !ok {
+		return func() {}
+	}
+
+	var mu sync.Mutex
+	busy, canceled := false, false
+	poll := func() {
+		mu.Lock()
+		if busy || canceled {
+			// the active poll will pick up the new changes
+			mu.Unlock()
+			return
+		}
+		busy = true
+		for next, c := s.Next(); next != nil && !canceled; next, c = s.Next() {
+			s = next
+			mu.Unlock()
+			fn(c)
+			mu.Lock()
+		}
+		busy = false
+		mu.Unlock()
+	}
+
+	stop := n.Notify(poll)
+	poll()
+	return func() {
+		mu.Lock()
+		canceled = true
+		mu.Unlock()
+		stop()
+	}
+}
+
+// family tracks the notification functions of a stream family
+type family struct {
+	sync.Mutex
+	fns map[*func()]bool
+}
+
+func (f *family) add(fn func()) func() {
+	if f == nil {
+		return func() {}
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	if f.fns == nil {
+		f.fns = map[*func()]bool{}
+	}
+	key := &fn
+	f.fns[key] = true
+	return func() {
+		f.Lock()
+		defer f.Unlock()
+		delete(f.fns, key)
+	}
+}
+
+func (f *family) notify() {
+	if f == nil {
+		return
+	}
+
+	f.Lock()
+	fns := make([]func(), 0, len(f.fns))
+	for fn := range f.fns {
+		fns = append(fns, *fn)
+	}
+	f.Unlock()
+
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// notify calls Notify on the stream if it is a Notifier
+func notify(s Stream, fn func()) func() {
+	if n, ok := s.(Notifier); ok {
+		return n.Notify(fn)
+	}
+	return func() {}
+}
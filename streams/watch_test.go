@@ -0,0 +1,118 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package streams_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/streams"
+	"github.com/dotchain/dot/streams/undo"
+)
+
+func TestWatch(t *testing.T) {
+	s := streams.New()
+	s1 := s.Append(change(1))
+
+	var seen []changes.Change
+	cancel := streams.Watch(s, func(c changes.Change) {
+		seen = append(seen, c)
+	})
+
+	// changes appended to older instances are also seen
+	s.Append(change(2))
+	s1.Append(change(3))
+
+	expected := []changes.Change{
+		change(1),
+		change(2),
+		change(3),
+	}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Fatal("Unexpected changes", seen)
+	}
+
+	cancel()
+	s.Append(change(4))
+	if len(seen) != 3 {
+		t.Fatal("Unexpected changes after cancel", seen)
+	}
+}
+
+func TestWatchAppendInCallback(t *testing.T) {
+	s := streams.New()
+	count := 0
+	var cancel func()
+	cancel = streams.Watch(s, func(c changes.Change) {
+		if count++; count < 3 {
+			latest, _ := streams.Latest(s)
+			latest.Append(change(count))
+		}
+	})
+	defer cancel()
+
+	s.Append(change(0))
+	if count != 3 {
+		t.Fatal("Unexpected count", count)
+	}
+}
+
+func TestWatchDerived(t *testing.T) {
+	s := streams.New()
+	sub := streams.Substream(s, 5)
+	xform := streams.Transform(s, nil, func(c changes.Change) changes.Change {
+		return changes.PathChange{Path: []interface{}{"x"}, Change: c}
+	})
+
+	var subSeen, xformSeen []changes.Change
+	defer streams.Watch(sub, func(c changes.Change) { subSeen = append(subSeen, changes.Simplify(c)) })()
+	defer streams.Watch(xform, func(c changes.Change) { xformSeen = append(xformSeen, c) })()
+
+	splice := changes.Splice{Offset: 0, Before: types.S8(""), After: types.S8("OK")}
+	s.Append(changes.PathChange{Path: []interface{}{5}, Change: splice})
+
+	if !reflect.DeepEqual(subSeen, []changes.Change{splice}) {
+		t.Error("Unexpected substream changes", subSeen)
+	}
+
+	expected := changes.PathChange{
+		Path:   []interface{}{"x"},
+		Change: changes.PathChange{Path: []interface{}{5}, Change: splice},
+	}
+	if !reflect.DeepEqual(xformSeen, []changes.Change{expected}) {
+		t.Error("Unexpected transform changes", xformSeen)
+	}
+}
+
+func TestWatchBranchAndUndo(t *testing.T) {
+	up := streams.New()
+	down := undo.New(streams.Branch(up))
+
+	count := 0
+	defer streams.Watch(down, func(c changes.Change) { count++ })()
+	defer streams.Watch(streams.New(), func(c changes.Change) { t.Error("Unrelated", c) })()
+
+	up.Append(change(1))
+	if count != 0 {
+		t.Fatal("Unexpected notification before pull")
+	}
+	if err := down.Pull(); err != nil || count != 1 {
+		t.Fatal("Unexpected pull", err, count)
+	}
+
+	down, _ = streams.Latest(down)
+	down = down.Append(change(2))
+	down.Undo()
+	if count != 3 {
+		t.Fatal("Unexpected count", count)
+	}
+}
+
+func change(key int) changes.Change {
+	replace := changes.Replace{Before: changes.Nil, After: types.S8("OK")}
+	return changes.PathChange{Path: []interface{}{key}, Change: replace}
+}
@@ -0,0 +1,329 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+// Package fuzztest implements property based convergence tests for
+// change and value implementations.
+//
+// A Model provides a random value generator and a random change
+// generator. Validate uses these to build a set of concurrent
+// changes (each of which may be a chain of sequential changes) on
+// top of a random initial value and checks the following:
+//
+//	revert:         initial + c + c.Revert() == initial
+//	merge:          initial + c1 + c1x == initial + c2 + c2x
+//	reverse-merge:  the same as merge but using ReverseMerge for
+//	                Custom changes
+//	stream:         appending all the concurrent changes to a
+//	                single stream converges
+//	compose:        composing the sequential changes of a chain
+//	                gives the same value as the chain
+//	path:           refs.Merge of a random path gives a Scoped
+//	                change that matches the effect of the change
+//	                on the value at that path
+//
+// Failures are shrunk to a smaller counterexample before being
+// reported.
+//
+// The Fuzz method can be used with native go fuzzing:
+//
+//	func FuzzMyType(f *testing.F) {
+//	     myModel.Fuzz(f)
+//	}
+//
+// Only the int64 seed is fuzzed: the fuzzing engine mutates the seed
+// and not the generated values or changes, so coverage depends on
+// the Value and Change generators.
+package fuzztest
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/format"
+	"github.com/dotchain/dot/refs"
+	"github.com/dotchain/dot/streams"
+)
+
+// Model holds the generators needed for fuzzing.
+//
+// Value and Change are required. All other fields are optional.
+type Model struct {
+	// Value returns a random initial value
+	Value func(r *rand.Rand) changes.Value
+
+	// Change returns a random change that can be applied to v
+	Change func(r *rand.Rand, v changes.Value) changes.Change
+
+	// Equal compares two values. Defaults to reflect.DeepEqual
+	Equal func(v1, v2 changes.Value) bool
+
+	// Path returns a random path within v. The path check is
+	// skipped if either Path or Get is nil.
+	Path func(r *rand.Rand, v changes.Value) []interface{}
+
+	// Get returns the value at the provided path
+	Get func(v changes.Value, path []interface{}) changes.Value
+
+	// Shrink returns simpler versions of a change which are used
+	// in addition to the standard shrinking of ChangeSet and Meta.
+	// The simpler changes must be applicable to the same value
+	// and must eventually stop shrinking.
+	Shrink func(c changes.Change) []changes.Change
+
+	// MaxChanges is the max number of concurrent changes
+	// generated. Defaults to 3
+	MaxChanges int
+
+	// MaxChain is the max number of sequential changes that
+	// make up each concurrent change. Defaults to 3
+	MaxChain int
+}
+
+// Failure is a counterexample
+type Failure struct {
+	Check   string
+	Initial changes.Value
+	Changes []changes.Change
+	Path    []interface{}
+	Message string
+}
+
+// Error implements the error interface
+func (f *Failure) Error() string {
+	lines := []string{
+		f.Check + " failed: " + f.Message,
+		"initial: " + format.Value(f.Initial),
+	}
+	for _, c := range f.Changes {
+		lines = append(lines, "change: "+format.Change(c))
+	}
+	if f.Path != nil {
+		lines = append(lines, fmt.Sprintf("path: %#v", f.Path))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Check runs Validate with count different seeds and reports
+// failures
+func (m Model) Check(t *testing.T, count int) {
+	for seed := int64(0); seed < int64(count); seed++ {
+		if err := m.Validate(seed); err != nil {
+			t.Fatal("seed", seed, err)
+		}
+	}
+}
+
+// Fuzz runs Validate via native go fuzzing
+func (m Model) Fuzz(f *testing.F) {
+	for seed := int64(0); seed < 20; seed++ {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		if err := m.Validate(seed); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// Validate generates random changes based on the seed and checks
+// all the properties. It returns a shrunk counterexample on
+// failure.
+func (m Model) Validate(seed int64) *Failure {
+	r := rand.New(rand.NewSource(seed))
+	initial := m.Value(r)
+
+	count := 1 + r.Intn(m.max(m.MaxChanges))
+	cx := make([]changes.Change, count)
+	for kk := range cx {
+		cx[kk] = m.chain(r, initial)
+	}
+
+	var path []interface{}
+	if m.Path != nil && m.Get != nil {
+		path = m.Path(r, initial)
+	}
+
+	f := m.check(initial, cx, path)
+	if f == nil {
+		return nil
+	}
+	return m.shrink(f)
+}
+
+func (m Model) max(n int) int {
+	if n <= 0 {
+		return 3
+	}
+	return n
+}
+
+func (m Model) chain(r *rand.Rand, v changes.Value) changes.Change {
+	result := changes.ChangeSet{}
+	for kk := 1 + r.Intn(m.max(m.MaxChain)); kk > 0; kk-- {
+		c := m.Change(r, v)
+		result = append(result, c)
+		if kk > 1 {
+			v = v.Apply(nil, c)
+		}
+	}
+	if len(result) == 1 {
+		return result[0]
+	}
+	return result
+}
+
+func (m Model) equal(v1, v2 changes.Value) bool {
+	if m.Equal != nil {
+		return m.Equal(v1, v2)
+	}
+	return reflect.DeepEqual(v1, v2)
+}
+
+func (m Model) check(initial changes.Value, cx []changes.Change, path []interface{}) *Failure {
+	checks := []struct {
+		name string
+		fn   checkFn
+	}{
+		{"revert", m.checkRevert},
+		{"merge", m.checkMerge},
+		{"reverse-merge", m.checkReverseMerge},
+		{"stream", m.checkStream},
+		{"compose", m.checkCompose},
+		{"path", m.checkPath},
+	}
+
+	for _, check := range checks {
+		if msg := m.safely(check.fn, initial, cx, path); msg != "" {
+			return &Failure{check.name, initial, cx, path, msg}
+		}
+	}
+	return nil
+}
+
+type checkFn func(initial changes.Value, cx []changes.Change, path []interface{}) string
+
+func (m Model) safely(fn checkFn, initial changes.Value, cx []changes.Change, path []interface{}) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprint("panic: ", r)
+		}
+	}()
+	return fn(initial, cx, path)
+}
+
+func (m Model) checkRevert(initial changes.Value, cx []changes.Change, path []interface{}) string {
+	for _, c := range cx {
+		if c == nil {
+			continue
+		}
+		v := initial.Apply(nil, c).Apply(nil, c.Revert())
+		if !m.equal(v, initial) {
+			return "got " + format.Value(v) + " for " + format.Change(c)
+		}
+	}
+	return ""
+}
+
+func (m Model) checkMerge(initial changes.Value, cx []changes.Change, path []interface{}) string {
+	for _, c1 := range cx {
+		for _, c2 := range cx {
+			c2x, c1x := changes.Merge(c1, c2)
+			if msg := m.converge(initial, c1, c2x, c2, c1x); msg != "" {
+				return msg
+			}
+		}
+	}
+	return ""
+}
+
+func (m Model) checkReverseMerge(initial changes.Value, cx []changes.Change, path []interface{}) string {
+	for _, c1 := range cx {
+		custom, ok := c1.(changes.Custom)
+		if !ok {
+			continue
+		}
+		for _, c2 := range cx {
+			c2x, c1x := custom.ReverseMerge(c2)
+			if msg := m.converge(initial, c1, c2x, c2, c1x); msg != "" {
+				return msg
+			}
+		}
+	}
+	return ""
+}
+
+func (m Model) converge(initial changes.Value, c1, c2x, c2, c1x changes.Change) string {
+	v1 := initial.Apply(nil, c1).Apply(nil, c2x)
+	v2 := initial.Apply(nil, c2).Apply(nil, c1x)
+	if !m.equal(v1, v2) {
+		return "diverged " + format.Value(v1) + " != " + format.Value(v2)
+	}
+	return ""
+}
+
+func (m Model) checkStream(initial changes.Value, cx []changes.Change, path []interface{}) string {
+	base := streams.New()
+	all := make([]streams.Stream, len(cx))
+	for kk, c := range cx {
+		if kk%2 == 0 {
+			all[kk] = base.Append(c)
+		} else {
+			all[kk] = base.ReverseAppend(c)
+		}
+	}
+
+	_, expected := streams.Latest(base)
+	v := initial.Apply(nil, expected)
+	for kk, s := range all {
+		_, c := streams.Latest(s)
+		vx := initial.Apply(nil, cx[kk]).Apply(nil, c)
+		if !m.equal(v, vx) {
+			return "diverged " + format.Value(v) + " != " + format.Value(vx)
+		}
+	}
+	return ""
+}
+
+func (m Model) checkCompose(initial changes.Value, cx []changes.Change, path []interface{}) string {
+	for _, c := range cx {
+		var composed changes.Change
+		if set, ok := c.(changes.ChangeSet); ok {
+			for _, cc := range set {
+				composed = changes.Compose(composed, cc)
+			}
+		} else {
+			composed = changes.Compose(c, nil)
+		}
+
+		expected := initial.Apply(nil, c)
+		if got := initial.Apply(nil, composed); !m.equal(expected, got) {
+			return "expected " + format.Value(expected) + " got " + format.Value(got)
+		}
+	}
+	return ""
+}
+
+func (m Model) checkPath(initial changes.Value, cx []changes.Change, path []interface{}) string {
+	if path == nil {
+		return ""
+	}
+
+	for _, c := range cx {
+		result := refs.Merge(path, c)
+		if result == nil {
+			continue
+		}
+
+		expected := m.Get(initial.Apply(nil, c), result.P)
+		got := m.Get(initial, path).Apply(nil, result.Scoped)
+		if !m.equal(expected, got) {
+			return "expected " + format.Value(expected) + " got " + format.Value(got)
+		}
+	}
+	return ""
+}
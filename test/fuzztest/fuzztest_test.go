@@ -0,0 +1,407 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package fuzztest_test
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/crdt"
+	"github.com/dotchain/dot/changes/run"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/test/fuzztest"
+	"github.com/dotchain/dot/x/rich"
+	"github.com/dotchain/dot/x/rich/data"
+)
+
+func randS8(r *rand.Rand, max int) types.S8 {
+	b := make([]byte, r.Intn(max+1))
+	for kk := range b {
+		b[kk] = byte('a' + r.Intn(26))
+	}
+	return types.S8(b)
+}
+
+func randCollectionChange(r *rand.Rand, v changes.Collection, insert func() changes.Collection) changes.Change {
+	n := v.Count()
+	offset := r.Intn(n + 1)
+	count := r.Intn(n - offset + 1)
+	if r.Intn(2) == 0 {
+		return changes.Splice{Offset: offset, Before: v.Slice(offset, count), After: insert()}
+	}
+	distance := r.Intn(n-count+1) - offset
+	return changes.Move{Offset: offset, Count: count, Distance: distance}
+}
+
+var s8Model = fuzztest.Model{
+	Value: func(r *rand.Rand) changes.Value {
+		return randS8(r, 8)
+	},
+	Change: func(r *rand.Rand, v changes.Value) changes.Change {
+		if r.Intn(10) == 0 {
+			return changes.Replace{Before: v, After: randS8(r, 5)}
+		}
+		insert := func() changes.Collection { return randS8(r, 3) }
+		return randCollectionChange(r, v.(types.S8), insert)
+	},
+}
+
+var arrayModel = fuzztest.Model{
+	Value: func(r *rand.Rand) changes.Value {
+		result := types.A{}
+		for kk := r.Intn(5); kk > 0; kk-- {
+			result = append(result, randS8(r, 4))
+		}
+		return result
+	},
+	Change: func(r *rand.Rand, v changes.Value) changes.Change {
+		a := v.(types.A)
+		insert := func() changes.Collection {
+			return types.A{randS8(r, 3)}[:r.Intn(2)]
+		}
+		switch r.Intn(3) {
+		case 0:
+			return randCollectionChange(r, a, insert)
+		case 1:
+			if len(a) > 0 {
+				idx := r.Intn(len(a))
+				return changes.PathChange{
+					Path:   []interface{}{idx},
+					Change: s8Model.Change(r, a[idx]),
+				}
+			}
+		}
+		offset := r.Intn(len(a) + 1)
+		count := r.Intn(len(a) - offset + 1)
+		inner := changes.Splice{Before: types.S8(""), After: randS8(r, 2)}
+		return run.Run{Offset: offset, Count: count, Change: inner}
+	},
+	Path: func(r *rand.Rand, v changes.Value) []interface{} {
+		if a := v.(types.A); len(a) > 0 {
+			return []interface{}{r.Intn(len(a))}
+		}
+		return nil
+	},
+	Get: func(v changes.Value, path []interface{}) changes.Value {
+		return v.(types.A)[path[0].(int)]
+	},
+}
+
+var seqModel = fuzztest.Model{
+	Value: func(r *rand.Rand) changes.Value {
+		items := []interface{}{}
+		for kk := r.Intn(5); kk > 0; kk-- {
+			items = append(items, randS8(r, 4))
+		}
+		_, s := crdt.Seq{}.Splice(0, 0, items)
+		return s
+	},
+	Change: func(r *rand.Rand, v changes.Value) changes.Change {
+		s := v.(crdt.Seq)
+		items := s.Items()
+		n := len(items)
+		if n > 0 && r.Intn(3) == 0 {
+			idx := r.Intn(n)
+			inner := s8Model.Change(r, items[idx].(types.S8))
+			if r.Intn(2) == 0 {
+				c, _ := s.Update(idx, inner)
+				return c
+			}
+			return changes.PathChange{Path: []interface{}{s.Key(idx)}, Change: inner}
+		}
+
+		offset := r.Intn(n + 1)
+		count := r.Intn(n - offset + 1)
+		if r.Intn(3) == 0 {
+			distance := r.Intn(n-count+1) - offset
+			c, _ := s.Move(offset, count, distance)
+			return c
+		}
+		insert := []interface{}{}
+		for kk := r.Intn(3); kk > 0; kk-- {
+			insert = append(insert, randS8(r, 3))
+		}
+		c, _ := s.Splice(offset, count, insert)
+		return c
+	},
+	Equal: func(v1, v2 changes.Value) bool {
+		return reflect.DeepEqual(v1.(crdt.Seq).Items(), v2.(crdt.Seq).Items())
+	},
+}
+
+var textModel = fuzztest.Model{
+	Value: func(r *rand.Rand) changes.Value {
+		_, t := crdt.Text{}.Splice(0, 0, string(randS8(r, 8)))
+		return t
+	},
+	Change: func(r *rand.Rand, v changes.Value) changes.Change {
+		t := v.(crdt.Text)
+		n := t.Count()
+		offset := r.Intn(n + 1)
+		count := r.Intn(n - offset + 1)
+		if r.Intn(3) == 0 {
+			distance := r.Intn(n-count+1) - offset
+			c, _ := t.Move(offset, count, distance)
+			return c
+		}
+		c, _ := t.Splice(offset, count, string(randS8(r, 3)))
+		return c
+	},
+	Equal: func(v1, v2 changes.Value) bool {
+		return v1.(crdt.Text).String() == v2.(crdt.Text).String()
+	},
+}
+
+var richAttrs = []rich.Attr{
+	data.FontBold,
+	data.FontThin,
+	data.FontStyleItalic,
+	data.FontStyleNormal,
+}
+
+func randRich(r *rand.Rand, max int) *rich.Text {
+	t := rich.NewText(string(randS8(r, max-1)) + "x")
+	if n := t.Count(); n > 0 {
+		offset := r.Intn(n)
+		count := 1 + r.Intn(n-offset)
+		attr := richAttrs[r.Intn(len(richAttrs))]
+		t = t.Apply(nil, t.SetAttribute(offset, count, attr)).(*rich.Text)
+	}
+	return t
+}
+
+var richModel = fuzztest.Model{
+	Value: func(r *rand.Rand) changes.Value {
+		return randRich(r, 8)
+	},
+	Change: func(r *rand.Rand, v changes.Value) changes.Change {
+		t := v.(*rich.Text)
+		attr := richAttrs[r.Intn(len(richAttrs))]
+		if n := t.Count(); n > 0 && r.Intn(2) == 0 {
+			// empty ranges are skipped as they are no-ops
+			offset := r.Intn(n)
+			count := 1 + r.Intn(n-offset)
+			if r.Intn(2) == 0 {
+				return t.RemoveAttribute(offset, count, attr.Name())
+			}
+			return t.SetAttribute(offset, count, attr)
+		}
+		insert := func() changes.Collection {
+			if r.Intn(3) == 0 {
+				return &rich.Text{}
+			}
+			return randRich(r, 3)
+		}
+		return randCollectionChange(r, t, insert)
+	},
+	Equal: func(v1, v2 changes.Value) bool {
+		return reflect.DeepEqual(richChars(v1.(*rich.Text)), richChars(v2.(*rich.Text)))
+	},
+}
+
+// richChars splits rich text into individual characters so that
+// texts which only differ in how the runs are split compare equal
+func richChars(t *rich.Text) []changes.Collection {
+	result := []changes.Collection{}
+	for kk := 0; kk < t.Count(); kk++ {
+		result = append(result, t.Slice(kk, 1))
+	}
+	return result
+}
+
+func TestS8(t *testing.T) {
+	s8Model.Check(t, 500)
+}
+
+func TestArray(t *testing.T) {
+	arrayModel.Check(t, 500)
+}
+
+func TestSeq(t *testing.T) {
+	seqModel.Check(t, 300)
+}
+
+func TestText(t *testing.T) {
+	textModel.Check(t, 300)
+}
+
+func TestRich(t *testing.T) {
+	richModel.Check(t, 500)
+}
+
+func FuzzS8(f *testing.F) {
+	s8Model.Fuzz(f)
+}
+
+func FuzzArray(f *testing.F) {
+	arrayModel.Fuzz(f)
+}
+
+func FuzzSeq(f *testing.F) {
+	seqModel.Fuzz(f)
+}
+
+func FuzzText(f *testing.F) {
+	textModel.Fuzz(f)
+}
+
+func FuzzRich(f *testing.F) {
+	richModel.Fuzz(f)
+}
+
+func TestFailureShrinking(t *testing.T) {
+	model := s8Model
+	model.Change = func(r *rand.Rand, v changes.Value) changes.Change {
+		if r.Intn(3) == 0 {
+			return changes.Meta{Data: "bad", Change: appendX(1)}
+		}
+		return s8Model.Change(r, v)
+	}
+	model.MaxChanges = 5
+	model.MaxChain = 5
+
+	var f *fuzztest.Failure
+	for seed := int64(0); f == nil && seed < 100; seed++ {
+		f = model.Validate(seed)
+	}
+
+	if f == nil {
+		t.Fatal("Failed to detect a broken change")
+	}
+
+	if f.Check != "merge" || len(f.Changes) > 2 {
+		t.Error("Failed to shrink", f)
+	}
+
+	for _, c := range f.Changes {
+		if _, ok := c.(changes.Meta); ok {
+			t.Error("Failed to shrink meta", f)
+		}
+	}
+
+	if !strings.Contains(f.Error(), "merge failed: diverged") {
+		t.Error("Unexpected error", f.Error())
+	}
+}
+
+func TestFailurePanics(t *testing.T) {
+	model := s8Model
+	model.Change = func(r *rand.Rand, v changes.Value) changes.Change {
+		return changes.Meta{Change: panicky{true}}
+	}
+	model.Shrink = func(c changes.Change) []changes.Change {
+		if c == (panicky{true}) {
+			return []changes.Change{panicky{false}}
+		}
+		return nil
+	}
+	model.MaxChain = 1
+
+	f := model.Validate(0)
+	if f == nil || f.Check != "revert" || !strings.Contains(f.Message, "panic: ") {
+		t.Fatal("Unexpected failure", f)
+	}
+
+	if len(f.Changes) != 1 || f.Changes[0] != (panicky{false}) {
+		t.Error("Failed to shrink", f.Changes)
+	}
+}
+
+func TestPathFailure(t *testing.T) {
+	model := arrayModel
+	model.Path = func(r *rand.Rand, v changes.Value) []interface{} {
+		return []interface{}{0}
+	}
+	// Get ignores the path and returns the last element instead
+	model.Get = func(v changes.Value, path []interface{}) changes.Value {
+		a := v.(types.A)
+		return a[len(a)-1]
+	}
+	model.Change = func(r *rand.Rand, v changes.Value) changes.Change {
+		if a := v.(types.A); len(a) < 2 {
+			return nil
+		}
+		inner := changes.Splice{Before: types.S8(""), After: types.S8("z")}
+		return changes.PathChange{Path: []interface{}{0}, Change: inner}
+	}
+
+	var f *fuzztest.Failure
+	for seed := int64(0); f == nil && seed < 100; seed++ {
+		f = model.Validate(seed)
+	}
+	if f == nil || f.Check != "path" {
+		t.Fatal("Unexpected failure", f)
+	}
+}
+
+func TestPathUsesEqual(t *testing.T) {
+	model := arrayModel
+	model.Get = func(v changes.Value, path []interface{}) changes.Value {
+		return types.S8("x")
+	}
+	model.Change = func(r *rand.Rand, v changes.Value) changes.Change {
+		a := v.(types.A)
+		return changes.Splice{Offset: len(a), Before: types.A{}, After: types.A{types.S8("z")}}
+	}
+	model.Equal = func(v1, v2 changes.Value) bool {
+		_, ok := v1.(types.S8)
+		return !ok || v1 == v2
+	}
+
+	var f *fuzztest.Failure
+	for seed := int64(0); f == nil && seed < 100; seed++ {
+		f = model.Validate(seed)
+	}
+	if f != nil {
+		t.Fatal("Unexpected failure", f)
+	}
+}
+
+// appendX appends (or removes) x's from the end of a string but
+// does not bother with merges
+type appendX int
+
+func (a appendX) Merge(o changes.Change) (changes.Change, changes.Change) {
+	return o, a
+}
+
+func (a appendX) ReverseMerge(o changes.Change) (changes.Change, changes.Change) {
+	return o, a
+}
+
+func (a appendX) Revert() changes.Change {
+	return -a
+}
+
+func (a appendX) ApplyTo(ctx changes.Context, v changes.Value) changes.Value {
+	s := v.(types.S8)
+	if a < 0 {
+		return s[:len(s)+int(a)]
+	}
+	return s + types.S8(strings.Repeat("x", int(a)))
+}
+
+type panicky struct {
+	big bool
+}
+
+func (p panicky) Merge(o changes.Change) (changes.Change, changes.Change) {
+	return o, p
+}
+
+func (p panicky) ReverseMerge(o changes.Change) (changes.Change, changes.Change) {
+	return o, p
+}
+
+func (p panicky) Revert() changes.Change {
+	return p
+}
+
+func (p panicky) ApplyTo(ctx changes.Context, v changes.Value) changes.Value {
+	panic("bad change")
+}
@@ -0,0 +1,79 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package fuzztest
+
+import "github.com/dotchain/dot/changes"
+
+// shrink repeatedly replaces the failure with a simpler one which
+// fails the same check until no simpler failure can be found.
+func (m Model) shrink(f *Failure) *Failure {
+	for next := m.shrinkOnce(f); next != nil; next = m.shrinkOnce(f) {
+		f = next
+	}
+	return f
+}
+
+func (m Model) shrinkOnce(f *Failure) *Failure {
+	for _, cx := range m.candidates(f.Changes) {
+		if fx := m.check(f.Initial, cx, f.Path); fx != nil && fx.Check == f.Check {
+			return fx
+		}
+	}
+	return nil
+}
+
+// candidates returns all the simpler sets of concurrent changes:
+// either dropping one of the changes or simplifying one of them.
+func (m Model) candidates(cx []changes.Change) [][]changes.Change {
+	result := [][]changes.Change{}
+	if len(cx) > 1 {
+		for kk := range cx {
+			dropped := append([]changes.Change(nil), cx[:kk]...)
+			result = append(result, append(dropped, cx[kk+1:]...))
+		}
+	}
+
+	for kk, c := range cx {
+		for _, simpler := range m.simpler(c) {
+			clone := append([]changes.Change(nil), cx...)
+			clone[kk] = simpler
+			result = append(result, clone)
+		}
+	}
+	return result
+}
+
+// simpler returns simpler versions of a change.  ChangeSets are
+// trimmed from the end (as the later changes may depend on the
+// earlier ones), Meta changes are unwrapped and path changes are
+// simplified recursively.
+//
+// All of these remain valid changes on the initial value. The
+// Model.Shrink function is expected to maintain the same property.
+func (m Model) simpler(c changes.Change) []changes.Change {
+	var result []changes.Change
+	switch c := c.(type) {
+	case changes.ChangeSet:
+		for kk := range c {
+			result = append(result, c[:kk].Simplify())
+		}
+		if l := len(c) - 1; l >= 0 {
+			for _, last := range m.simpler(c[l]) {
+				result = append(result, append(c[:l:l], last))
+			}
+		}
+	case changes.Meta:
+		result = append(result, c.Change)
+	case changes.PathChange:
+		for _, inner := range m.simpler(c.Change) {
+			result = append(result, changes.PathChange{Path: c.Path, Change: inner})
+		}
+	}
+
+	if m.Shrink != nil {
+		result = append(result, m.Shrink(c)...)
+	}
+	return result
+}
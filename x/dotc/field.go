@@ -24,21 +24,28 @@ var fromValueFormats = map[string]string{
 }
 
 var fromStreamValueFormats = map[string]string{
-	"bool":      "%s%.s",
-	"int":       "%s%.s",
-	"string":    "%s%.s",
-	"types.S16": "string(%s)%.s",
-	"types.S8":  "string(%s)%.s",
-	"default":   "%s%.s",
+	"bool":               "%s%.s",
+	"int":                "%s%.s",
+	"string":             "%s%.s",
+	"types.S16":          "string(%s)%.s",
+	"types.S8":           "string(%s)%.s",
+	"types.Counter":      "int32(%s)%.s",
+	"types.Counter64":    "int64(%s)%.s",
+	"types.FloatCounter": "float64(%s)%.s",
+	"default":            "%s%.s",
 }
 
 var toStreamTypeFormats = map[string]string{
-	"bool":      "streams.Bool%.s",
-	"int":       "streams.Int%.s",
-	"string":    "streams.S16%.s",
-	"types.S16": "streams.S16%.s",
-	"types.S8":  "streams.S8%.s",
-	"default":   "%sStream",
+	"bool":               "streams.Bool%.s",
+	"int":                "streams.Int%.s",
+	"string":             "streams.S16%.s",
+	"types.S16":          "streams.S16%.s",
+	"types.S8":           "streams.S8%.s",
+	"types.Set":          "streams.Set%.s",
+	"types.Counter":      "streams.Counter%.s",
+	"types.Counter64":    "streams.Counter64%.s",
+	"types.FloatCounter": "streams.FloatCounter%.s",
+	"default":            "%sStream",
 }
 
 // Field holds info for a struct field
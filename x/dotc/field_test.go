@@ -0,0 +1,46 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package dotc_test
+
+import (
+	"testing"
+
+	"github.com/dotchain/dot/x/dotc"
+)
+
+func TestFieldSet(t *testing.T) {
+	f := dotc.Field{Name: "tags", Key: "t", Type: "types.Set"}
+
+	if x := f.ToStreamType(); x != "streams.Set" {
+		t.Error("Unexpected stream type", x)
+	}
+	if x := f.ToValue("my", "tags"); x != "my.tags" {
+		t.Error("Unexpected ToValue", x)
+	}
+	if x := f.FromValue("v", ""); x != "(v).(types.Set)" {
+		t.Error("Unexpected FromValue", x)
+	}
+	if x := f.FromStreamValue("s", "Value"); x != "s.Value" {
+		t.Error("Unexpected FromStreamValue", x)
+	}
+}
+
+func TestFieldCounters(t *testing.T) {
+	counters := map[string][]string{
+		"types.Counter":      {"streams.Counter", "int32(s.Value)"},
+		"types.Counter64":    {"streams.Counter64", "int64(s.Value)"},
+		"types.FloatCounter": {"streams.FloatCounter", "float64(s.Value)"},
+	}
+
+	for typ, expected := range counters {
+		f := dotc.Field{Name: "count", Key: "c", Type: typ}
+		if x := f.ToStreamType(); x != expected[0] {
+			t.Error("Unexpected stream type", x)
+		}
+		if x := f.FromStreamValue("s", "Value"); x != expected[1] {
+			t.Error("Unexpected FromStreamValue", x)
+		}
+	}
+}
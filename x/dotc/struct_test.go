@@ -19,9 +19,11 @@ func TestStructGenerateApply(t *testing.T) {
 	test.File(t.Error, "mystruct/input.json", "mystruct/generated.go", genStruct)
 	test.File(t.Error, "mystruct/input2.json", "mystruct/generated2.go", genStruct)
 	test.File(t.Error, "mystruct/input3.json", "mystruct/generated3.go", genStruct)
+	test.File(t.Error, "mystruct/input4.json", "mystruct/generated4.go", genStruct)
 	test.File(t.Error, "mystruct/input.json", "mystruct/generated_test.go", genStructTests)
 	test.File(t.Error, "mystruct/input2.json", "mystruct/generated2_test.go", genStructTests)
 	test.File(t.Error, "mystruct/input3.json", "mystruct/generated3_test.go", genStructTests)
+	test.File(t.Error, "mystruct/input4.json", "mystruct/generated4_test.go", genStructTests)
 }
 
 func genStruct(s dotc.Struct) (string, error) {
@@ -0,0 +1,106 @@
+// Generated.  DO NOT EDIT.
+package mystruct
+
+import (
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/streams"
+)
+
+func (my MyCounts) get(key interface{}) changes.Value {
+	switch key {
+
+	case "votes":
+		return my.Votes
+	case "score":
+		return my.Score
+	case "tags":
+		return my.Tags
+	}
+	panic(key)
+}
+
+func (my MyCounts) set(key interface{}, v changes.Value) changes.Value {
+	myClone := my
+	switch key {
+	case "votes":
+		myClone.Votes = (v).(types.Counter64)
+	case "score":
+		myClone.Score = (v).(types.FloatCounter)
+	case "tags":
+		myClone.Tags = (v).(types.Set)
+	}
+	return myClone
+}
+
+func (my MyCounts) Apply(ctx changes.Context, c changes.Change) changes.Value {
+	return (types.Generic{Get: my.get, Set: my.set}).Apply(ctx, c, my)
+}
+
+func (my MyCounts) SetVotes(value types.Counter64) MyCounts {
+	myReplace := changes.Replace{my.Votes, value}
+	myChange := changes.PathChange{[]interface{}{"votes"}, myReplace}
+	return my.Apply(nil, myChange).(MyCounts)
+}
+
+func (my MyCounts) SetScore(value types.FloatCounter) MyCounts {
+	myReplace := changes.Replace{my.Score, value}
+	myChange := changes.PathChange{[]interface{}{"score"}, myReplace}
+	return my.Apply(nil, myChange).(MyCounts)
+}
+
+func (my MyCounts) SetTags(value types.Set) MyCounts {
+	myReplace := changes.Replace{my.Tags, value}
+	myChange := changes.PathChange{[]interface{}{"tags"}, myReplace}
+	return my.Apply(nil, myChange).(MyCounts)
+}
+
+// MyCountsStream implements a stream of MyCounts values
+type MyCountsStream struct {
+	Stream streams.Stream
+	Value  MyCounts
+}
+
+// Next returns the next entry in the stream if there is one
+func (s *MyCountsStream) Next() (*MyCountsStream, changes.Change) {
+	if s.Stream == nil {
+		return nil, nil
+	}
+
+	next, nextc := s.Stream.Next()
+	if next == nil {
+		return nil, nil
+	}
+
+	if nextVal, ok := s.Value.Apply(nil, nextc).(MyCounts); ok {
+		return &MyCountsStream{Stream: next, Value: nextVal}, nextc
+	}
+	return &MyCountsStream{Value: s.Value}, nil
+}
+
+// Latest returns the latest entry in the stream
+func (s *MyCountsStream) Latest() *MyCountsStream {
+	for n, _ := s.Next(); n != nil; n, _ = s.Next() {
+		s = n
+	}
+	return s
+}
+
+// Update replaces the current value with the new value
+func (s *MyCountsStream) Update(val MyCounts) *MyCountsStream {
+	if s.Stream != nil {
+		nexts := s.Stream.Append(changes.Replace{Before: s.Value, After: val})
+		s = &MyCountsStream{Stream: nexts, Value: val}
+	}
+	return s
+}
+
+func (s *MyCountsStream) Votes() *streams.Counter64 {
+	return &streams.Counter64{Stream: streams.Substream(s.Stream, "votes"), Value: int64(s.Value.Votes)}
+}
+func (s *MyCountsStream) Score() *streams.FloatCounter {
+	return &streams.FloatCounter{Stream: streams.Substream(s.Stream, "score"), Value: float64(s.Value.Score)}
+}
+func (s *MyCountsStream) Tags() *streams.Set {
+	return &streams.Set{Stream: streams.Substream(s.Stream, "tags"), Value: s.Value.Tags}
+}
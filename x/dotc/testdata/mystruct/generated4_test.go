@@ -0,0 +1,130 @@
+// Generated.  DO NOT EDIT.
+package mystruct
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/streams"
+)
+
+func TestStreamMyCountsStream(t *testing.T) {
+	s := streams.New()
+	values := valuesForMyCountsStream()
+	strong := &MyCountsStream{Stream: s, Value: values[0]}
+
+	strong = strong.Update(values[1])
+	if !reflect.DeepEqual(strong.Value, values[1]) {
+		t.Error("Update did not change value", strong.Value)
+	}
+
+	s, c := s.Next()
+	if !reflect.DeepEqual(c, changes.Replace{Before: values[0], After: values[1]}) {
+		t.Error("Unexpected change", c)
+	}
+
+	c = changes.Replace{Before: values[1], After: values[2]}
+	s = s.Append(c)
+	c = changes.Replace{Before: values[2], After: values[3]}
+	s = s.Append(c)
+	strong = strong.Latest()
+
+	if !reflect.DeepEqual(strong.Value, values[3]) {
+		t.Error("Unexpected value", strong.Value)
+	}
+
+	_, c = strong.Next()
+	if c != nil {
+		t.Error("Unexpected change on stream", c)
+	}
+
+	s = s.Append(changes.Replace{Before: values[3], After: changes.Nil})
+	if strong, c = strong.Next(); c != nil {
+		t.Error("Unexpected change on terminated stream", c)
+	}
+
+	s.Append(changes.Replace{Before: changes.Nil, After: values[3]})
+	if _, c = strong.Next(); c != nil {
+		t.Error("Unexpected change on terminated stream", c)
+	}
+}
+
+func TestStreamMyCountsStreamVotes(t *testing.T) {
+	s := streams.New()
+	values := valuesForMyCountsStream()
+	strong := &MyCountsStream{Stream: s, Value: values[0]}
+	expected := int64(strong.Value.Votes)
+	if !reflect.DeepEqual(expected, strong.Votes().Value) {
+		t.Error("Substream returned unexpected value", strong.Votes().Value)
+	}
+
+	child := strong.Votes()
+	for kk := range values {
+		child = child.Update(int64(values[kk].Votes))
+		strong = strong.Latest()
+		if !reflect.DeepEqual(child.Value, int64(values[kk].Votes)) {
+			t.Error("updating child didn't  take effect", child.Value)
+		}
+		if !reflect.DeepEqual(child.Value, int64(strong.Value.Votes)) {
+			t.Error("updating child didn't  take effect", child.Value)
+		}
+	}
+
+	v := strong.Value.SetVotes(values[0].Votes)
+	if !reflect.DeepEqual(v.Votes, values[0].Votes) {
+		t.Error("Could not update", "SetVotes")
+	}
+}
+func TestStreamMyCountsStreamScore(t *testing.T) {
+	s := streams.New()
+	values := valuesForMyCountsStream()
+	strong := &MyCountsStream{Stream: s, Value: values[0]}
+	expected := float64(strong.Value.Score)
+	if !reflect.DeepEqual(expected, strong.Score().Value) {
+		t.Error("Substream returned unexpected value", strong.Score().Value)
+	}
+
+	child := strong.Score()
+	for kk := range values {
+		child = child.Update(float64(values[kk].Score))
+		strong = strong.Latest()
+		if !reflect.DeepEqual(child.Value, float64(values[kk].Score)) {
+			t.Error("updating child didn't  take effect", child.Value)
+		}
+		if !reflect.DeepEqual(child.Value, float64(strong.Value.Score)) {
+			t.Error("updating child didn't  take effect", child.Value)
+		}
+	}
+
+	v := strong.Value.SetScore(values[0].Score)
+	if !reflect.DeepEqual(v.Score, values[0].Score) {
+		t.Error("Could not update", "SetScore")
+	}
+}
+func TestStreamMyCountsStreamTags(t *testing.T) {
+	s := streams.New()
+	values := valuesForMyCountsStream()
+	strong := &MyCountsStream{Stream: s, Value: values[0]}
+	expected := strong.Value.Tags
+	if !reflect.DeepEqual(expected, strong.Tags().Value) {
+		t.Error("Substream returned unexpected value", strong.Tags().Value)
+	}
+
+	child := strong.Tags()
+	for kk := range values {
+		child = child.Update(values[kk].Tags)
+		strong = strong.Latest()
+		if !reflect.DeepEqual(child.Value, values[kk].Tags) {
+			t.Error("updating child didn't  take effect", child.Value)
+		}
+		if !reflect.DeepEqual(child.Value, strong.Value.Tags) {
+			t.Error("updating child didn't  take effect", child.Value)
+		}
+	}
+
+	v := strong.Value.SetTags(values[0].Tags)
+	if !reflect.DeepEqual(v.Tags, values[0].Tags) {
+		t.Error("Could not update", "SetTags")
+	}
+}
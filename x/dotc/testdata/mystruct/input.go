@@ -29,6 +29,13 @@ type MyStruct struct {
 	Count int32
 }
 
+// MyCounts is public
+type MyCounts struct {
+	Votes types.Counter64
+	Score types.FloatCounter
+	Tags  types.Set
+}
+
 type boolStream struct {
 	Stream streams.Stream
 	Value  *bool
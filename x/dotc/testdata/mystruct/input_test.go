@@ -98,3 +98,12 @@ func valuesForMyStructStream() []MyStruct {
 		},
 	}
 }
+
+func valuesForMyCountsStream() []MyCounts {
+	return []MyCounts{
+		{Votes: 1, Score: 1.5, Tags: types.Set{"one": true}},
+		{Votes: 2, Score: 2.5, Tags: types.Set{"two": true}},
+		{Votes: 3, Score: 3.5, Tags: types.Set{"three": true}},
+		{Votes: 4, Score: 4.5, Tags: types.Set{"four": true}},
+	}
+}
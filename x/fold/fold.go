@@ -11,13 +11,18 @@
 // folded changes).   Similarly,  upstream changes are pulled in, also
 // correctly transforming them.
 //
-// TODO: It is a bit tricky to remove folded changes. In an ideal
-// setup, removing folds should have no effect on any streams derived
-// from the fold while having the right effect upstream.  This is not
-// yet implemented.
+// Folds can be stacked (by folding a folded stream) and named (via
+// Named). A named fold can be removed (via Remove) or its folded
+// change replaced (via Replace).  Neither has any effect upstream:
+// streams derived from the fold instead see the revert of the
+// folded change (and the replacement, if any) via Next once they
+// have caught up with the latest changes of the base stream.  Remove
+// and Replace also notify the watchers of the fold (see Notify).
 package fold
 
 import (
+	"sync"
+
 	"github.com/dotchain/dot/changes"
 	"github.com/dotchain/dot/streams"
 )
@@ -30,10 +35,33 @@ import (
 // The folded change can be fetched back by calling Unfold on the
 // returned stream or any stream derived from it.
 func New(c changes.Change, base streams.Stream) streams.Stream {
-	return stream{c, base}
+	return Named("", c, base)
+}
+
+// Named is like New but the fold is named.  The name can be used
+// to unfold, remove or replace the fold when multiple folds are
+// stacked.
+func Named(name string, c changes.Change, base streams.Stream) streams.Stream {
+	return stream{&info{name: name, watchers: &watchers{}}, c, base}
+}
+
+// info is shared by all streams derived from a fold
+type info struct {
+	sync.Mutex
+	name     string
+	watchers *watchers
+
+	// done is set if the fold was removed or replaced. The
+	// replacement (if any) is relative to the base stream
+	// instance at.
+	done        bool
+	at          streams.Stream
+	replacement changes.Change
+	next        *info
 }
 
 type stream struct {
+	info *info
 	fold changes.Change
 	streams.Stream
 }
@@ -48,10 +76,10 @@ func (s stream) Append(c changes.Change) streams.Stream {
 	}
 
 	if c == nil {
-		return stream{fold, s.Stream}
+		return stream{s.info, fold, s.Stream}
 	}
 
-	return stream{fold, s.Stream.Append(c)}
+	return stream{s.info, fold, s.Stream.Append(c)}
 }
 
 func (s stream) ReverseAppend(c changes.Change) streams.Stream {
@@ -61,10 +89,54 @@ func (s stream) ReverseAppend(c changes.Change) streams.Stream {
 func (s stream) Next() (streams.Stream, changes.Change) {
 	base, c := s.Stream.Next()
 	if base == nil {
-		return nil, nil
+		return s.info.transition(s)
+	}
+	if c == nil || s.fold == nil {
+		return stream{s.info, s.fold, base}, c
 	}
 	fold, cx := c.Merge(s.fold)
-	return &stream{fold, base}, cx
+	return stream{s.info, fold, base}, cx
+}
+
+// Notify implements streams.Notifier. The function is also called
+// when the fold is removed or replaced.
+func (s stream) Notify(fn func()) (cancel func()) {
+	remove := s.info.watchers.add(fn)
+	if n, ok := s.Stream.(streams.Notifier); ok {
+		stop := n.Notify(fn)
+		return func() { remove(); stop() }
+	}
+	return remove
+}
+
+// transition returns the stream after the fold was removed or
+// replaced.  It is only called when s is at the latest base.
+func (i *info) transition(s stream) (streams.Stream, changes.Change) {
+	i.Lock()
+	defer i.Unlock()
+
+	if !i.done {
+		return nil, nil
+	}
+
+	// bring the replacement up to date with the base
+	for next, c := i.at.Next(); next != nil; next, c = next.Next() {
+		if c != nil && i.replacement != nil {
+			i.replacement, _ = c.Merge(i.replacement)
+		}
+		i.at = next
+	}
+
+	var revert changes.Change
+	if s.fold != nil {
+		revert = s.fold.Revert()
+	}
+	cx := changes.ChangeSet{revert, i.replacement}.Simplify()
+
+	if i.next == nil {
+		return s.Stream, cx
+	}
+	return stream{i.next, i.replacement, s.Stream}, cx
 }
 
 // Unfold takes any stream derived from a folded stream (created by
@@ -76,3 +148,101 @@ func Unfold(s streams.Stream) (changes.Change, streams.Stream) {
 	x := s.(stream)
 	return x.fold, x.Stream
 }
+
+// UnfoldNamed is like Unfold but returns the fold with the provided
+// name, looking through stacked folds.
+//
+// It returns nil if there is no such fold.
+func UnfoldNamed(s streams.Stream, name string) (changes.Change, streams.Stream) {
+	if x, ok := find(s, name); ok {
+		return x.fold, x.Stream
+	}
+	return nil, nil
+}
+
+// Remove removes the named fold from any stream derived from
+// it. It returns false if the fold was not found or was already
+// removed or replaced.
+func Remove(s streams.Stream, name string) bool {
+	return update(s, name, nil, false)
+}
+
+// Replace replaces the named folded change with the provided
+// change. The change should be relative to the latest state of the
+// base stream. It returns false if the fold was not found or was
+// already removed or replaced.
+//
+// The stream returned by Next after the replacement is still
+// a named fold and so can be further replaced or removed.
+func Replace(s streams.Stream, name string, c changes.Change) bool {
+	return update(s, name, c, true)
+}
+
+func update(s streams.Stream, name string, c changes.Change, replace bool) bool {
+	x, ok := find(s, name)
+	if !ok {
+		return false
+	}
+
+	x.info.Lock()
+	if x.info.done {
+		x.info.Unlock()
+		return false
+	}
+
+	x.info.done = true
+	x.info.at, _ = streams.Latest(x.Stream)
+	x.info.replacement = c
+	if replace {
+		x.info.next = &info{name: name, watchers: x.info.watchers}
+	}
+	x.info.Unlock()
+
+	x.info.watchers.notify()
+	return true
+}
+
+// watchers tracks the Notify functions of all streams derived
+// from a fold (including its replacements)
+type watchers struct {
+	sync.Mutex
+	fns map[*func()]bool
+}
+
+func (w *watchers) add(fn func()) func() {
+	w.Lock()
+	defer w.Unlock()
+	if w.fns == nil {
+		w.fns = map[*func()]bool{}
+	}
+	key := &fn
+	w.fns[key] = true
+	return func() {
+		w.Lock()
+		defer w.Unlock()
+		delete(w.fns, key)
+	}
+}
+
+func (w *watchers) notify() {
+	w.Lock()
+	fns := make([]func(), 0, len(w.fns))
+	for fn := range w.fns {
+		fns = append(fns, *fn)
+	}
+	w.Unlock()
+
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+func find(s streams.Stream, name string) (stream, bool) {
+	for {
+		x, ok := s.(stream)
+		if !ok || x.info.name == name {
+			return x, ok
+		}
+		s = x.Stream
+	}
+}
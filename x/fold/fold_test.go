@@ -0,0 +1,159 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package fold_test
+
+import (
+	"testing"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/streams"
+	"github.com/dotchain/dot/x/fold"
+)
+
+func TestRemove(t *testing.T) {
+	upstream := streams.New()
+	folded := fold.Named("x", insert(0, "hello "), upstream)
+	derived := folded
+
+	folded = folded.Append(insert(6, "world"))
+	upstream.Append(insert(0, "abc"))
+
+	if !fold.Remove(folded, "x") || fold.Remove(folded, "x") || fold.Remove(folded, "y") {
+		t.Fatal("Unexpected remove result")
+	}
+
+	// the derived stream sees the revert of the fold
+	if v := value(S("hello "), derived); v != S("worldabc") {
+		t.Fatal("Unexpected derived value", v)
+	}
+	if v := value(S(""), upstream); v != S("worldabc") {
+		t.Fatal("Unexpected upstream value", v)
+	}
+
+	// further changes on the derived stream go upstream
+	latest, _ := streams.Latest(derived)
+	latest.Append(insert(0, "!"))
+	if v := value(S(""), upstream); v != S("!worldabc") {
+		t.Fatal("Unexpected upstream value", v)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	upstream := streams.New()
+	folded := fold.Named("x", insert(0, "hello "), upstream)
+	derived := folded
+
+	if !fold.Replace(folded, "x", insert(0, "bye ")) {
+		t.Fatal("Unexpected replace failure")
+	}
+	upstream.Append(insert(0, "abc"))
+
+	if v := value(S("hello "), derived); v != S("abcbye ") {
+		t.Fatal("Unexpected derived value", v)
+	}
+
+	latest, _ := streams.Latest(derived)
+	if c, _ := fold.UnfoldNamed(latest, "x"); c != insert(3, "bye ") {
+		t.Fatal("Unexpected fold", c)
+	}
+
+	// replacement can be removed
+	if !fold.Remove(latest, "x") {
+		t.Fatal("Unexpected remove failure")
+	}
+	if v := value(S("abcbye "), latest); v != S("abc") {
+		t.Fatal("Unexpected derived value", v)
+	}
+}
+
+func TestStackedFolds(t *testing.T) {
+	upstream := streams.New()
+	inner := fold.Named("inner", insert(0, "a"), upstream)
+	outer := fold.Named("outer", insert(1, "b"), inner)
+
+	if c, _ := fold.UnfoldNamed(outer, "inner"); c != insert(0, "a") {
+		t.Fatal("Unexpected inner fold", c)
+	}
+	if c, _ := fold.UnfoldNamed(outer, "missing"); c != nil {
+		t.Fatal("Unexpected fold", c)
+	}
+
+	if !fold.Remove(outer.Append(insert(2, "c")), "inner") {
+		t.Fatal("Unexpected remove failure")
+	}
+
+	// upstream changes are ordered before the folds on ties
+	if v := value(S("ab"), outer); v != S("cb") {
+		t.Fatal("Unexpected value", v)
+	}
+	if v := value(S(""), upstream); v != S("c") {
+		t.Fatal("Unexpected upstream value", v)
+	}
+
+	latest, _ := streams.Latest(outer)
+	if c, _ := fold.UnfoldNamed(latest, "inner"); c != nil {
+		t.Fatal("Unexpected removed fold", c)
+	}
+	if c, _ := fold.Unfold(latest); c != insert(1, "b") {
+		t.Fatal("Unexpected outer fold", c)
+	}
+}
+
+func TestMergeOrder(t *testing.T) {
+	upstream := streams.New()
+	folded := fold.New(insert(0, "F"), upstream)
+	upstream.Append(insert(0, "U"))
+
+	if v := value(S("F"), folded); v != S("UF") {
+		t.Fatal("Unexpected value", v)
+	}
+	latest, _ := streams.Latest(folded)
+	if c, _ := fold.Unfold(latest); c != insert(1, "F") {
+		t.Fatal("Unexpected fold", c)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	upstream := streams.New()
+	folded := fold.Named("x", insert(0, "hello"), upstream)
+
+	var watched []changes.Change
+	cancel := streams.Watch(folded, func(c changes.Change) {
+		watched = append(watched, c)
+	})
+	defer cancel()
+
+	if !fold.Replace(folded, "x", insert(0, "bye")) {
+		t.Fatal("Unexpected replace failure")
+	}
+	if len(watched) != 1 {
+		t.Fatal("Unexpected changes", watched)
+	}
+
+	latest, _ := streams.Latest(folded)
+	if !fold.Remove(latest, "x") {
+		t.Fatal("Unexpected remove failure")
+	}
+	if len(watched) != 2 {
+		t.Fatal("Unexpected changes", watched)
+	}
+	if v := value(S("hello"), folded); v != S("") {
+		t.Fatal("Unexpected value", v)
+	}
+}
+
+type S = types.S8
+
+func insert(offset int, s string) changes.Change {
+	return changes.Splice{Offset: offset, Before: S(""), After: S(s)}
+}
+
+func value(v changes.Value, s streams.Stream) changes.Value {
+	for next, c := s.Next(); next != nil; next, c = next.Next() {
+		v = v.Apply(nil, c)
+	}
+	return v
+}
@@ -0,0 +1,338 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+// Package record implements recording and replaying of sessions
+// for debugging.
+//
+// A Recorder wraps a stream and a store, writing every Append,
+// ReverseAppend, Push, Pull, Undo and Redo on the stream as well as
+// all the operations sent to or fetched from the store.
+//
+// Replay reads the recording and re-runs it against fresh
+// instances (such as streams.New() or a sync stream on
+// testops.MemStore(nil)), reporting the first event where the
+// results diverge.
+package record
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/ops"
+	"github.com/dotchain/dot/ops/nw"
+	"github.com/dotchain/dot/streams"
+)
+
+// Event kinds
+const (
+	Append        = "Append"
+	ReverseAppend = "ReverseAppend"
+	Push          = "Push"
+	Pull          = "Pull"
+	Undo          = "Undo"
+	Redo          = "Redo"
+	StoreAppend   = "StoreAppend"
+	GetSince      = "GetSince"
+
+	// External is used for changes that appeared on the stream
+	// without going through the recorder (such as changes
+	// appended directly to the underlying stream).
+	External = "External"
+)
+
+// Ref identifies a stream instance: the instance is the one
+// reached after Steps non-nil changes from the instance with the
+// provided ID.  The ID of the stream passed to Recorder.Stream is
+// zero and each Append or ReverseAppend creates a new ID.
+type Ref struct {
+	ID, Steps int
+}
+
+// Event is a single recorded event.
+//
+// Stream events refer to the instance via Ref and Append and
+// ReverseAppend events hold the ID of the resulting instance. The
+// changes caused by Push, Pull, Undo or Redo (and External changes)
+// are recorded in Changes. If the recorder tracks values, Value is
+// the latest value of the stream after the event.
+//
+// Store events hold the ops appended or fetched along with the
+// Version and Limit of GetSince.
+type Event struct {
+	Time    time.Time
+	Kind    string
+	Ref     Ref
+	ID      int
+	Change  changes.Change
+	Changes []changes.Change
+	Value   changes.Value
+
+	Ops            []ops.Op
+	Version, Limit int
+	Error          string
+}
+
+// Recorder records events into Writer using Codec (which defaults
+// to the gob codec).  Now defaults to time.Now.
+//
+// A Recorder should only be used with a single stream and from a
+// single goroutine. Calls made while another call is being recorded
+// (such as from a notification) are not recorded separately: their
+// effects are attributed to the outer call.  Append and
+// ReverseAppend return the unwrapped stream in that case, so any
+// changes made later on those instances are recorded as External.
+type Recorder struct {
+	io.Writer
+	nw.Codec
+	Now func() time.Time
+
+	mu     sync.Mutex
+	ids    int
+	cursor streams.Stream
+	value  changes.Value
+	busy   bool
+	err    error
+}
+
+// Stream wraps the provided stream so that all changes made on it
+// are recorded.  If initial is not nil, the latest value of the
+// stream is recorded with every event.
+func (r *Recorder) Stream(s streams.Stream, initial changes.Value) streams.Stream {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cursor, r.value = s, initial
+	return stream{r, Ref{}, s}
+}
+
+// Store wraps the provided store so that all operations appended
+// or fetched are recorded.
+func (r *Recorder) Store(s ops.Store) ops.Store {
+	return store{r, s}
+}
+
+// Err returns the first error encountered when writing events.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// record calls fn and writes the stream event. Nested calls are
+// not recorded and fn is called with a nil event.
+func (r *Recorder) record(e Event, fn func(e *Event)) {
+	r.mu.Lock()
+	if r.busy {
+		r.mu.Unlock()
+		fn(nil)
+		return
+	}
+	r.busy = true
+	if changes := r.changesSince(); len(changes) > 0 {
+		r.write(Event{Kind: External, Changes: changes})
+	}
+	r.mu.Unlock()
+
+	fn(&e)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.busy = false
+	r.write(e)
+}
+
+// recordStore calls fn and writes the store event.  Unlike stream
+// events, these are recorded even when nested within other calls.
+func (r *Recorder) recordStore(e Event, fn func(e *Event)) {
+	fn(&e)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writeEvent(e)
+}
+
+// write updates the latest value and writes the stream event
+func (r *Recorder) write(e Event) {
+	if r.cursor != nil {
+		var c changes.Change
+		r.cursor, c = streams.Latest(r.cursor)
+		if r.value != nil && c != nil {
+			r.value = r.value.Apply(nil, c)
+		}
+		e.Value = r.value
+	}
+	r.writeEvent(e)
+}
+
+func (r *Recorder) writeEvent(e Event) {
+	if r.Now == nil {
+		e.Time = time.Now()
+	} else {
+		e.Time = r.Now()
+	}
+
+	if r.err == nil {
+		r.err = write(r.Writer, r.Codec, e)
+	}
+}
+
+// changesSince returns the changes made after the cursor.
+func (r *Recorder) changesSince() []changes.Change {
+	var result []changes.Change
+	if r.cursor == nil {
+		return nil
+	}
+	for next, c := r.cursor.Next(); next != nil; next, c = next.Next() {
+		if c != nil {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func (r *Recorder) nextID() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ids++
+	return r.ids
+}
+
+type stream struct {
+	r   *Recorder
+	ref Ref
+	streams.Stream
+}
+
+func (s stream) Append(c changes.Change) streams.Stream {
+	var result streams.Stream
+	s.r.record(Event{Kind: Append, Ref: s.ref, Change: c}, func(e *Event) {
+		result = s.Stream.Append(c)
+		if e != nil {
+			e.ID = s.r.nextID()
+			result = stream{s.r, Ref{e.ID, 0}, result}
+		}
+	})
+	return result
+}
+
+func (s stream) ReverseAppend(c changes.Change) streams.Stream {
+	var result streams.Stream
+	s.r.record(Event{Kind: ReverseAppend, Ref: s.ref, Change: c}, func(e *Event) {
+		result = s.Stream.ReverseAppend(c)
+		if e != nil {
+			e.ID = s.r.nextID()
+			result = stream{s.r, Ref{e.ID, 0}, result}
+		}
+	})
+	return result
+}
+
+func (s stream) Next() (streams.Stream, changes.Change) {
+	next, c := s.Stream.Next()
+	if next == nil {
+		return nil, nil
+	}
+	ref := s.ref
+	if c != nil {
+		ref.Steps++
+	}
+	return stream{s.r, ref, next}, c
+}
+
+func (s stream) Push() error {
+	return s.call(Push, s.Stream.Push)
+}
+
+func (s stream) Pull() error {
+	return s.call(Pull, s.Stream.Pull)
+}
+
+func (s stream) Undo() {
+	_ = s.call(Undo, func() error { s.Stream.Undo(); return nil })
+}
+
+func (s stream) Redo() {
+	_ = s.call(Redo, func() error { s.Stream.Redo(); return nil })
+}
+
+func (s stream) call(kind string, fn func() error) error {
+	var err error
+	s.r.record(Event{Kind: kind, Ref: s.ref}, func(e *Event) {
+		if err = fn(); e == nil {
+			return
+		}
+		if err != nil {
+			e.Error = err.Error()
+		}
+		s.r.mu.Lock()
+		e.Changes = s.r.changesSince()
+		s.r.mu.Unlock()
+	})
+	return err
+}
+
+// Notify implements streams.Notifier
+func (s stream) Notify(fn func()) (cancel func()) {
+	if n, ok := s.Stream.(streams.Notifier); ok {
+		return n.Notify(fn)
+	}
+	return func() {}
+}
+
+type store struct {
+	r *Recorder
+	ops.Store
+}
+
+func (s store) Append(ctx context.Context, ops []ops.Op) error {
+	var err error
+	s.r.recordStore(Event{Kind: StoreAppend, Ops: ops}, func(e *Event) {
+		if err = s.Store.Append(ctx, ops); err != nil {
+			e.Error = err.Error()
+		}
+	})
+	return err
+}
+
+func (s store) GetSince(ctx context.Context, version, limit int) ([]ops.Op, error) {
+	var result []ops.Op
+	var err error
+	e := Event{Kind: GetSince, Version: version, Limit: limit}
+	s.r.recordStore(e, func(e *Event) {
+		if result, err = s.Store.GetSince(ctx, version, limit); err != nil {
+			e.Error = err.Error()
+		}
+		e.Ops = result
+	})
+	return result, err
+}
+
+func write(w io.Writer, codec nw.Codec, e Event) error {
+	var buf bytes.Buffer
+	if err := codecOrDefault(codec).Encode(e, &buf); err != nil {
+		return err
+	}
+
+	var size [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(size[:], uint64(buf.Len()))
+	if _, err := w.Write(size[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func codecOrDefault(codec nw.Codec) nw.Codec {
+	if codec == nil {
+		return nw.DefaultCodecs["application/x-gob"]
+	}
+	return codec
+}
+
+func init() {
+	nw.Register(Event{})
+}
@@ -0,0 +1,305 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package record_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/changes/types"
+	"github.com/dotchain/dot/ops"
+	"github.com/dotchain/dot/ops/nw"
+	"github.com/dotchain/dot/ops/sync"
+	"github.com/dotchain/dot/streams"
+	"github.com/dotchain/dot/streams/undo"
+	"github.com/dotchain/dot/test/testops"
+	"github.com/dotchain/dot/x/record"
+)
+
+func TestRecordReplay(t *testing.T) {
+	for name, codec := range nw.DefaultCodecs {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			session(t, &record.Recorder{Writer: &buf, Codec: codec})
+
+			events, err := record.Read(&buf, codec)
+			if err != nil {
+				t.Fatal("Read failed", err)
+			}
+
+			kinds := ""
+			for _, e := range events {
+				kinds += e.Kind + " "
+			}
+			expected := "Append Append ReverseAppend StoreAppend Pull GetSince Undo External Append "
+			if kinds != expected {
+				t.Fatal("Unexpected events", kinds)
+			}
+
+			d := record.Replay(context.Background(), events, streams.New(), S(""), nil)
+			if d != nil {
+				t.Fatal("Unexpected divergence", d)
+			}
+
+			d = record.Replay(context.Background(), events, nil, nil, testops.MemStore(nil))
+			if d != nil {
+				t.Fatal("Unexpected divergence", d)
+			}
+
+			d = record.Replay(context.Background(), events, streams.New(), S("x"), nil)
+			if d == nil || d.Index != 0 || d.Error() == "" {
+				t.Fatal("Unexpected divergence", d)
+			}
+
+			store := testops.MemStore([]ops.Op{op("other")})
+			d = record.Replay(context.Background(), events, nil, nil, store)
+			if d == nil || d.Event.Kind != record.GetSince {
+				t.Fatal("Unexpected divergence", d)
+			}
+		})
+	}
+}
+
+func TestReplaySync(t *testing.T) {
+	var buf bytes.Buffer
+	r := &record.Recorder{Writer: &buf}
+	mem := testops.MemStore(nil)
+	s := r.Stream(undo.New(sync.Stream(r.Store(mem))), S(""))
+
+	s = s.Append(insert(0, "hello"))
+	if err := s.Push(); err != nil {
+		t.Fatal("Push failed", err)
+	}
+
+	// sync delivers ops in the background
+	for ops, _ := mem.GetSince(context.Background(), 0, 10); len(ops) == 0; {
+		time.Sleep(time.Millisecond)
+		ops, _ = mem.GetSince(context.Background(), 0, 10)
+	}
+	if err := mem.Append(context.Background(), []ops.Op{op("other")}); err != nil {
+		t.Fatal("Append failed", err)
+	}
+	if err := s.Pull(); err != nil {
+		t.Fatal("Pull failed", err)
+	}
+	s.Undo()
+
+	events, err := record.Read(&buf, nil)
+	if err != nil {
+		t.Fatal("Read failed", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	store := ops.Polled(testops.MemStore(nil))
+	replayed := undo.New(sync.Stream(store))
+	if d := record.Replay(ctx, events, replayed, S(""), store); d != nil {
+		t.Fatal("Unexpected divergence", d)
+	}
+	if ops, _ := store.GetSince(context.Background(), 0, 10); len(ops) != 2 {
+		t.Fatal("Unexpected store ops", ops)
+	}
+
+	// ops fetched by GetSince must match the recording
+	store = testops.MemStore([]ops.Op{op("bogus")})
+	replayed = undo.New(sync.Stream(store))
+	d := record.Replay(ctx, events, replayed, S(""), store)
+	if d == nil || d.Event.Kind != record.GetSince {
+		t.Fatal("Unexpected divergence", d)
+	}
+
+	short, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	d = record.Replay(short, events, streams.New(), S(""), testops.MemStore(nil))
+	if d == nil || d.Event.Kind != record.StoreAppend {
+		t.Fatal("Unexpected divergence", d)
+	}
+}
+
+func TestNestedAppend(t *testing.T) {
+	var buf bytes.Buffer
+	r := &record.Recorder{Writer: &buf}
+	up := streams.New()
+	s := r.Stream(streams.Branch(up), S(""))
+
+	var nested streams.Stream
+	called := false
+	cancel := s.(streams.Notifier).Notify(func() {
+		if !called {
+			called = true
+			latest, _ := streams.Latest(s)
+			nested = latest.Append(insert(0, "n"))
+		}
+	})
+	up.Append(insert(0, "up"))
+	if err := s.Pull(); err != nil {
+		t.Fatal("Pull failed", err)
+	}
+	cancel()
+
+	if nested == nil {
+		t.Fatal("Notification not called")
+	}
+	nested.Append(insert(0, "m"))
+	s.Append(insert(0, "s"))
+
+	events, err := record.Read(&buf, nil)
+	if err != nil {
+		t.Fatal("Read failed", err)
+	}
+	if d := record.Replay(context.Background(), events, streams.New(), S(""), nil); d != nil {
+		t.Fatal("Unexpected divergence", d)
+	}
+	if v := events[len(events)-1].Value; v != S("mnups") {
+		t.Fatal("Unexpected value", v)
+	}
+}
+
+func TestNilSteps(t *testing.T) {
+	var buf bytes.Buffer
+	r := &record.Recorder{Writer: &buf}
+	s := r.Stream(streams.New(), S(""))
+	s.Append(nil).Append(insert(0, "a"))
+	s, _ = streams.Latest(s)
+	s.Append(insert(1, "b"))
+
+	events, err := record.Read(&buf, nil)
+	if err != nil {
+		t.Fatal("Read failed", err)
+	}
+	if ref := events[len(events)-1].Ref; ref.Steps != 1 {
+		t.Fatal("Unexpected ref", ref)
+	}
+	if d := record.Replay(context.Background(), events, streams.New(), S(""), nil); d != nil {
+		t.Fatal("Unexpected divergence", d)
+	}
+}
+
+func TestUnknownStream(t *testing.T) {
+	var buf bytes.Buffer
+	r := &record.Recorder{Writer: &buf}
+	s := r.Stream(streams.New(), nil)
+	s.Append(insert(0, "a"))
+
+	events, err := record.Read(&buf, nil)
+	if err != nil {
+		t.Fatal("Read failed", err)
+	}
+	events[0].Ref.ID = 42
+	if d := record.Replay(context.Background(), events, streams.New(), nil, nil); d == nil {
+		t.Fatal("Unexpected success")
+	}
+}
+
+func TestRecorderErrors(t *testing.T) {
+	r := &record.Recorder{Writer: failWriter{}, Now: time.Now}
+	s := r.Stream(streams.New(), S(""))
+	s.Append(insert(0, "a"))
+	if r.Err() == nil {
+		t.Fatal("Unexpected success")
+	}
+
+	store := r.Store(errStore{})
+	if err := store.Append(context.Background(), nil); err == nil {
+		t.Fatal("Unexpected success")
+	}
+	if _, err := store.GetSince(context.Background(), 0, 10); err == nil {
+		t.Fatal("Unexpected success")
+	}
+
+	if _, err := record.Read(bytes.NewReader([]byte{10, 1}), nil); err == nil {
+		t.Fatal("Unexpected read success")
+	}
+	if _, err := record.Read(bytes.NewReader([]byte{1, 1}), nil); err == nil {
+		t.Fatal("Unexpected decode success")
+	}
+}
+
+func TestReplayStoreErrors(t *testing.T) {
+	var buf bytes.Buffer
+	r := &record.Recorder{Writer: &buf}
+	_, _ = r.Store(errStore{}).GetSince(context.Background(), 0, 10)
+	_, _ = r.Store(testops.MemStore(nil)).GetSince(context.Background(), 0, 10)
+
+	events, err := record.Read(&buf, nil)
+	if err != nil {
+		t.Fatal("Read failed", err)
+	}
+
+	if d := record.Replay(context.Background(), events[:1], nil, nil, testops.MemStore(nil)); d == nil {
+		t.Fatal("Unexpected success")
+	}
+	if d := record.Replay(context.Background(), events[1:], nil, nil, errStore{}); d == nil {
+		t.Fatal("Unexpected success")
+	}
+}
+
+// session exercises a recorded stream and store
+func session(t *testing.T, r *record.Recorder) {
+	up := streams.New()
+	down := streams.Branch(up)
+	s := r.Stream(undo.New(down), S(""))
+	store := r.Store(testops.MemStore(nil))
+
+	s1 := s.Append(insert(0, "hello"))
+	s1.Append(insert(5, " world"))
+	s.ReverseAppend(insert(0, "<"))
+
+	if err := store.Append(context.Background(), []ops.Op{op("one")}); err != nil {
+		t.Fatal("Append failed", err)
+	}
+
+	up.Append(insert(0, "up "))
+	if err := s.Pull(); err != nil {
+		t.Fatal("Pull failed", err)
+	}
+	if _, err := store.GetSince(context.Background(), 0, 10); err != nil {
+		t.Fatal("GetSince failed", err)
+	}
+
+	s.Undo()
+
+	latest, _ := streams.Latest(down)
+	latest.Append(insert(0, "!"))
+
+	latest, _ = streams.Latest(s)
+	latest.Append(insert(0, "?"))
+	if r.Err() != nil {
+		t.Fatal("Unexpected error", r.Err())
+	}
+}
+
+type S = types.S8
+
+func insert(offset int, s string) changes.Change {
+	return changes.Splice{Offset: offset, Before: S(""), After: S(s)}
+}
+
+func op(id string) ops.Op {
+	return ops.Operation{OpID: id, BasisID: -1, Change: insert(0, id)}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+type errStore struct{}
+
+func (errStore) Append(ctx context.Context, ops []ops.Op) error {
+	return errors.New("append failed")
+}
+
+func (errStore) GetSince(ctx context.Context, version, limit int) ([]ops.Op, error) {
+	return nil, errors.New("get failed")
+}
+
+func (errStore) Close() {}
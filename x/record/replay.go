@@ -0,0 +1,294 @@
+// Copyright (C) 2019 rameshvk. All rights reserved.
+// Use of this source code is governed by a MIT-style license
+// that can be found in the LICENSE file.
+
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/ops"
+	"github.com/dotchain/dot/ops/nw"
+	"github.com/dotchain/dot/streams"
+)
+
+// Divergence describes the first event where a replay did not
+// match the recording
+type Divergence struct {
+	// Index is the index of the event in the recording
+	Index int
+
+	// Event is the recorded event
+	Event Event
+
+	// Reason describes the mismatch
+	Reason string
+}
+
+func (d *Divergence) Error() string {
+	return fmt.Sprintf("event %d (%s): %s", d.Index, d.Event.Kind, d.Reason)
+}
+
+// Read reads all the events of a recording
+func Read(r io.Reader, codec nw.Codec) ([]Event, error) {
+	var result []Event
+	br := bufio.NewReader(r)
+	for {
+		size, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+
+		data := make([]byte, size)
+		if _, err = io.ReadFull(br, data); err != nil {
+			return result, err
+		}
+
+		var e Event
+		if err = codecOrDefault(codec).Decode(&e, bytes.NewReader(data)); err != nil {
+			return result, err
+		}
+		result = append(result, e)
+	}
+}
+
+// Replay re-runs the recorded events on the provided stream and
+// store, returning the first divergence (or nil if there is
+// none). Either of s or store can be nil, in which case the
+// corresponding events are skipped.
+//
+// If store is nil, the effects of Push, Pull, Undo and Redo are
+// replayed by appending the recorded changes, so a plain
+// streams.New() can be used to replay any session.
+//
+// If both are provided, s is expected to be synchronized with store
+// (such as one created by sync.Stream, wrapped with undo.New if the
+// session used Undo or Redo).  Push, Pull, Undo and Redo are then
+// re-run on s and store is expected to be used only by s.  Store
+// events are not replayed directly in this case: StoreAppend waits
+// for the re-run Push to deliver the same number of ops (which may
+// happen in the background) and the ops fetched by GetSince that are
+// missing in store are appended to it, so that the re-run Pull sees
+// the ops of other clients.
+//
+// The wait for StoreAppend ends when the ops are delivered or when
+// ctx is done, so ctx should have a deadline.  Stores that support
+// long polling (such as ops.Polled) are woken up by the delivery.
+// Re-run ops are compared without their IDs (which are generated
+// afresh).
+//
+// External changes are always replayed by appending them.
+//
+// If the recording has values, the value of the replayed stream
+// (starting with initial) is compared against it after every
+// stream event.  Ops fetched via GetSince are also compared against
+// the recording.
+func Replay(ctx context.Context, events []Event, s streams.Stream, initial changes.Value, store ops.Store) *Divergence {
+	r := &replay{
+		ctx:       ctx,
+		instances: map[int]streams.Stream{0: s},
+		cursor:    s,
+		value:     initial,
+		store:     store,
+		rerun:     s != nil && store != nil,
+	}
+	for kk, e := range events {
+		reason := ""
+		switch {
+		case e.Kind == StoreAppend || e.Kind == GetSince:
+			reason = r.replayStore(e)
+		case s != nil:
+			reason = r.replayStream(e)
+		}
+		if reason != "" {
+			return &Divergence{kk, e, reason}
+		}
+	}
+	return nil
+}
+
+type replay struct {
+	ctx       context.Context
+	instances map[int]streams.Stream
+	cursor    streams.Stream
+	value     changes.Value
+	store     ops.Store
+	rerun     bool
+	count     int
+}
+
+func (r *replay) replayStream(e Event) string {
+	switch e.Kind {
+	case Append, ReverseAppend:
+		s := r.resolve(e.Ref)
+		if s == nil {
+			return fmt.Sprintf("unknown stream %v", e.Ref)
+		}
+		if e.Kind == Append {
+			r.instances[e.ID] = s.Append(e.Change)
+		} else {
+			r.instances[e.ID] = s.ReverseAppend(e.Change)
+		}
+	case Push, Pull, Undo, Redo:
+		if r.rerun {
+			if reason := r.rerunStream(e); reason != "" {
+				return reason
+			}
+			break
+		}
+		fallthrough
+	default:
+		latest, _ := streams.Latest(r.cursor)
+		for _, c := range e.Changes {
+			latest = latest.Append(c)
+		}
+	}
+
+	var c changes.Change
+	r.cursor, c = streams.Latest(r.cursor)
+	if r.value != nil && c != nil {
+		r.value = r.value.Apply(nil, c)
+	}
+	if e.Value != nil && !reflect.DeepEqual(e.Value, r.value) {
+		return fmt.Sprintf("expected value %v, got %v", e.Value, r.value)
+	}
+	return ""
+}
+
+// rerunStream calls Push, Pull, Undo or Redo on the stream
+func (r *replay) rerunStream(e Event) string {
+	s := r.resolve(e.Ref)
+	if s == nil {
+		return fmt.Sprintf("unknown stream %v", e.Ref)
+	}
+
+	var err error
+	switch e.Kind {
+	case Push:
+		err = s.Push()
+	case Pull:
+		err = s.Pull()
+	case Undo:
+		s.Undo()
+	case Redo:
+		s.Redo()
+	}
+
+	switch {
+	case err != nil && e.Error == "":
+		return fmt.Sprintf("unexpected error %v", err)
+	case err == nil && e.Error != "":
+		return fmt.Sprintf("expected error %v", e.Error)
+	}
+	return ""
+}
+
+func (r *replay) replayStore(e Event) string {
+	store := r.store
+	if store == nil {
+		return ""
+	}
+
+	if r.rerun {
+		return r.rerunStore(e)
+	}
+
+	if e.Kind == StoreAppend {
+		_ = store.Append(r.ctx, e.Ops)
+		return ""
+	}
+
+	result, err := store.GetSince(r.ctx, e.Version, e.Limit)
+	switch {
+	case err != nil && e.Error == "":
+		return fmt.Sprintf("unexpected error %v", err)
+	case err == nil && e.Error != "":
+		return fmt.Sprintf("expected error %v", e.Error)
+	case len(result) != len(e.Ops) || len(result) > 0 && !reflect.DeepEqual(result, e.Ops):
+		return fmt.Sprintf("expected ops %v, got %v", e.Ops, result)
+	}
+	return ""
+}
+
+// rerunStore waits for the ops of StoreAppend to be delivered and
+// appends the ops of GetSince that are missing in the store
+func (r *replay) rerunStore(e Event) string {
+	if e.Error != "" {
+		return ""
+	}
+
+	if e.Kind == StoreAppend {
+		r.count += len(e.Ops)
+		for len(e.Ops) > 0 {
+			result, err := r.store.GetSince(r.ctx, r.count-1, 1)
+			switch {
+			case err != nil:
+				return fmt.Sprintf("unexpected error %v", err)
+			case len(result) > 0:
+				return ""
+			case r.ctx.Err() != nil:
+				return fmt.Sprintf("ops %v not delivered", e.Ops)
+			}
+			// stores without long polling return immediately
+			time.Sleep(time.Millisecond)
+		}
+		return ""
+	}
+
+	result, err := r.store.GetSince(r.ctx, e.Version, e.Limit)
+	if err != nil {
+		return fmt.Sprintf("unexpected error %v", err)
+	}
+	if len(result) > len(e.Ops) || !sameOps(result, e.Ops[:len(result)]) {
+		return fmt.Sprintf("expected ops %v, got %v", e.Ops, result)
+	}
+	if len(result) < len(e.Ops) {
+		if err = r.store.Append(r.ctx, e.Ops[len(result):]); err != nil {
+			return fmt.Sprintf("unexpected error %v", err)
+		}
+	}
+	if count := e.Version + len(e.Ops); count > r.count {
+		r.count = count
+	}
+	return ""
+}
+
+// sameOps compares ops ignoring their IDs
+func sameOps(ops1, ops2 []ops.Op) bool {
+	for kk, op := range ops1 {
+		other := ops2[kk]
+		switch {
+		case op.Version() != other.Version(), op.Basis() != other.Basis():
+			return false
+		case (op.Parent() == nil) != (other.Parent() == nil):
+			return false
+		case !reflect.DeepEqual(op.Changes(), other.Changes()):
+			return false
+		}
+	}
+	return true
+}
+
+// resolve returns the stream instance for the provided ref,
+// skipping over nil changes
+func (r *replay) resolve(ref Ref) streams.Stream {
+	s := r.instances[ref.ID]
+	for steps := ref.Steps; steps > 0 && s != nil; {
+		var c changes.Change
+		if s, c = s.Next(); c != nil {
+			steps--
+		}
+	}
+	return s
+}
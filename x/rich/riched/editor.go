@@ -71,12 +71,13 @@ func (e *Editor) applyText(ctx changes.Context, c changes.PathChange) changes.Va
 	clone.Text = clone.Text.Apply(ctx, inner).(*rich.Text)
 
 	// patch up focus and anchor based on edits to text
-	focus, _ := refs.Path(clone.Focus).Merge(inner)
-	fpath, _ := focus.(refs.Path)
-	clone.Focus = []interface{}(fpath)
-	anchor, _ := refs.Path(clone.Anchor).Merge(inner)
-	apath, _ := anchor.(refs.Path)
-	clone.Anchor = []interface{}(apath)
+	sel, _ := e.Selection().Merge(inner)
+	if sel, ok := sel.(refs.Selection); ok {
+		clone.Focus, clone.Anchor = caretPath(sel.Focus()), caretPath(sel.Anchor())
+	} else {
+		// both ends were invalidated
+		clone.Focus, clone.Anchor = nil, nil
+	}
 
 	return &clone
 }
@@ -113,6 +114,17 @@ func (e *Editor) SetSelection(focus, anchor []interface{}) changes.Change {
 	}
 }
 
+// Selection returns the current selection. Unlike refs.Range, the
+// ends of the selection can be in different embedded objects.
+func (e *Editor) Selection() refs.Selection {
+	return refs.NewSelection(pathCaret(e.Anchor), pathCaret(e.Focus))
+}
+
+// Select updates the selection state
+func (e *Editor) Select(s refs.Selection) changes.Change {
+	return e.SetSelection(caretPath(s.Focus()), caretPath(s.Anchor()))
+}
+
 // SetOverride update an override that is used for text insertion
 //
 // A negative override can be created by using NoAttribute{"name"} as
@@ -211,3 +223,21 @@ func (e *Editor) resolve(r *rich.Text, path []interface{}) (*rich.Text, int) {
 	// NYI
 	return r, path[0].(int)
 }
+
+// pathCaret converts a Focus or Anchor path into a caret.  Paths
+// that do not end in an index (such as those of a zero Editor) map to
+// the zero caret.
+func pathCaret(p []interface{}) refs.Caret {
+	if len(p) == 0 {
+		return refs.Caret{}
+	}
+	idx, ok := p[len(p)-1].(int)
+	if !ok {
+		return refs.Caret{}
+	}
+	return refs.Caret{Path: refs.Path(p[:len(p)-1]), Index: idx}
+}
+
+func caretPath(c refs.Caret) []interface{} {
+	return append(append([]interface{}(nil), c.Path...), c.Index)
+}
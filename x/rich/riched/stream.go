@@ -6,6 +6,7 @@ package riched
 
 import (
 	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/refs"
 	"github.com/dotchain/dot/streams"
 	"github.com/dotchain/dot/x/rich"
 )
@@ -40,6 +41,11 @@ func (s *Stream) SetSelection(focus, anchor []interface{}) *Stream {
 	return s.append(s.Editor.SetSelection(focus, anchor))
 }
 
+// Select updates the selection state
+func (s *Stream) Select(sel refs.Selection) *Stream {
+	return s.append(s.Editor.Select(sel))
+}
+
 // SetOverride update an override that is used for text insertion
 func (s *Stream) SetOverride(attr rich.Attr) *Stream {
 	return s.append(s.Editor.SetOverride(attr))
@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/dotchain/dot/changes"
+	"github.com/dotchain/dot/refs"
 	"github.com/dotchain/dot/x/rich"
 	"github.com/dotchain/dot/x/rich/data"
 	"github.com/dotchain/dot/x/rich/html"
@@ -107,3 +108,49 @@ func TestStreamOverrides(t *testing.T) {
 		}
 	})
 }
+
+func TestStreamSelect(t *testing.T) {
+	s := riched.NewStream(rich.NewText("Hello world", data.FontBold))
+	sel := refs.NewSelection(refs.Caret{Path: refs.Path{}, Index: 8}, refs.Caret{Path: refs.Path{2}, Index: 1})
+	s = s.Select(sel)
+	if !reflect.DeepEqual(s.Focus, []interface{}{2, 1}) || !reflect.DeepEqual(s.Anchor, []interface{}{8}) {
+		t.Fatal("Unexpected selection", s.Focus, s.Anchor)
+	}
+	if x := s.Selection(); !x.Equal(sel) {
+		t.Fatal("Unexpected selection", x)
+	}
+
+	// deleting the embed with the focus collapses the selection
+	s.Stream.Append(changes.PathChange{
+		Path: []interface{}{"Text"},
+		Change: changes.Splice{
+			Offset: 1,
+			Before: rich.NewText("ello", data.FontBold),
+			After:  &rich.Text{},
+		},
+	})
+	s = s.Next()
+	if !reflect.DeepEqual(s.Focus, []interface{}{4}) || !reflect.DeepEqual(s.Anchor, []interface{}{4}) {
+		t.Error("Unexpected selection", s.Focus, s.Anchor)
+	}
+
+	// replacing the text clears the selection
+	s.Stream.Append(changes.PathChange{
+		Path:   []interface{}{"Text"},
+		Change: changes.Replace{Before: s.Text, After: rich.NewText("Hi")},
+	})
+	s = s.Next()
+	if s.Focus != nil || s.Anchor != nil {
+		t.Error("Unexpected selection", s.Focus, s.Anchor)
+	}
+	if x := s.Selection(); !x.Equal(refs.NewSelection(refs.Caret{}, refs.Caret{})) {
+		t.Error("Unexpected selection", x)
+	}
+}
+
+func TestEditorZeroSelection(t *testing.T) {
+	var e riched.Editor
+	if x := e.Selection(); !x.Equal(refs.NewSelection(refs.Caret{}, refs.Caret{})) {
+		t.Error("Unexpected selection", x)
+	}
+}
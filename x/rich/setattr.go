@@ -61,6 +61,15 @@ func (s setattr) merge(o changes.Change, reverse bool) (ox, sx changes.Change) {
 }
 
 func (s setattr) mergeSplice(o changes.Splice) (ox, sx changes.Change) {
+	if o.Before.Count() == 0 && o.Offset > s.Offset && o.Offset < s.Offset+s.Before.count() {
+		// insertion within the range: the inserted text is
+		// not affected but the rest of the range is
+		left := s.slice(0, o.Offset-s.Offset)
+		right := s.slice(o.Offset-s.Offset, s.Before.count()-o.Offset+s.Offset).(setattr)
+		right.Offset += o.After.Count()
+		return o, changes.ChangeSet{left, right}
+	}
+
 	non, overlap := s.split(o.Offset, o.Offset+o.Before.Count())
 	if overlap == nil {
 		if s.Offset >= o.Offset {
@@ -74,7 +83,11 @@ func (s setattr) mergeSplice(o changes.Splice) (ox, sx changes.Change) {
 		return s.mergeSpliceWithin(x, non, o)
 	}
 
-	return changes.ChangeSet{overlap.Revert(), o}, non
+	// the deleted text should reflect the attribute change
+	x := overlap.(setattr)
+	x.Offset -= o.Offset
+	o.Before = o.Before.ApplyCollection(nil, x)
+	return o, non
 }
 
 func (s setattr) mergeSpliceWithin(x setattr, non changes.Change, o changes.Splice) (oxx, sxx changes.Change) {
@@ -139,6 +139,28 @@ func TestTextSetAttrMergeSpliceConflict(t *testing.T) {
 	}
 }
 
+func TestTextSetAttrMergeInsertWithin(t *testing.T) {
+	s := rich.NewText("hello world")
+	c1 := changes.Splice{Offset: 4, Before: rich.NewText(""), After: rich.NewText("---")}
+	c2 := s.SetAttribute(3, 5, data.FontBold)
+
+	result := testMerge(t, s, c1, c2)
+	testReverseMerge(t, s, c1, c2)
+
+	if x := html.Format(result); x != "hel<b>l</b>---<b>o wo</b>rld" {
+		t.Error("Unexpected", x)
+	}
+}
+
+func TestTextSetAttrMergeSpliceChangeSet(t *testing.T) {
+	s := rich.NewText("hello world")
+	c := changes.ChangeSet{
+		s.SetAttribute(2, 6, data.FontBold),
+		changes.Splice{Offset: 0, Before: rich.NewText("hel"), After: rich.NewText("x")},
+	}
+	testMerge(t, s, c, c)
+}
+
 func TestTextSetAttrMergePathNoConflict(t *testing.T) {
 	s := rich.NewText("hello world")
 	c1 := changes.PathChange{
@@ -131,6 +131,7 @@ func (t *Text) sliceAttr(offset, count int, name string) values {
 				s = append(s, run)
 			}
 		}
+		seen += x.Size
 	}
 	return s
 }
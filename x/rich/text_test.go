@@ -71,6 +71,19 @@ func TestTextSetAttr(t *testing.T) {
 	}
 }
 
+func TestTextRemoveAttrAcrossRuns(t *testing.T) {
+	s := rich.NewText("hello world")
+	s = s.Apply(nil, s.SetAttribute(2, 9, data.FontBold)).(*rich.Text)
+	c := s.RemoveAttribute(1, 5, "FontWeight")
+	s1 := s.Apply(nil, c).(*rich.Text)
+	if x := html.Format(s1); x != "hello <b>world</b>" {
+		t.Error("Unexpected", x)
+	}
+	if x := html.Format(s1.Apply(nil, c.Revert()).(*rich.Text)); x != html.Format(s) {
+		t.Error("Unexpected revert", x)
+	}
+}
+
 func TestTextApplyCollection(t *testing.T) {
 	s := rich.NewText("hello world")
 	c := changes.Move{Offset: 4, Count: 1, Distance: -4}